@@ -0,0 +1,486 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// e2e-shard runs test suite on several nested clusters at the same time.
+// Top-level tests are balanced between clusters by historical durations,
+// tests lost due to cluster failure are retried on a new cluster.
+//
+//	go run ./cmd/e2e-shard -shards 3 -- -hypervisorkconfig kube-hypervisor.config -sshhost user@10.20.30.40 -clustertype "Ubuntu 22 mini"
+//	go run ./cmd/e2e-shard -lease kube-a.config=user@10.0.0.1,kube-b.config=user@10.0.0.2
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	util "github.com/deckhouse/sds-e2e/util"
+)
+
+const defaultDuration = 10 * 60 // seconds, for tests without history
+
+var (
+	fs           = flag.NewFlagSet("e2e-shard", flag.ExitOnError)
+	shardsFlag   = fs.Int("shards", 2, "Count of clusters running tests at the same time")
+	testsFlag    = fs.String("tests", "./tests/", "Tests package path")
+	runFlag      = fs.String("run", "", "Run only top-level tests matching regexp")
+	everyFlag    = fs.String("every", "TestFinalizer", "Tests to run on each cluster (comma separated)")
+	leaseFlag    = fs.String("lease", "", "Use existing clusters (kube-a.config=user@10.0.0.1,...), spare ones replace failed")
+	nsFlag       = fs.String("namespace", "e2e-shard-"+time.Now().Format("0102-1504"), "Namespace prefix of shard clusters")
+	retriesFlag  = fs.Int("retries", 2, "Retries of tests lost due to cluster failure, each on a new cluster")
+	historyFlag  = fs.String("history", "data/durations.json", "Test durations for balancing, updated after run")
+	outFlag      = fs.String("out", "shards", "Directory for shard logs and reports")
+	jsonFlag     = fs.String("jsonreport", "report.json", "Merged JSON report")
+	junitFlag    = fs.String("junitreport", "", "Merged JUnit XML report")
+	basePortFlag = fs.Int("baseport", 17000, "First local port of cluster API tunnels (2 ports per shard)")
+	timeoutFlag  = fs.Duration("timeout", 6*time.Hour, "Timeout of each shard run")
+	keepFlag     = fs.Bool("keepclusters", false, "Don`t delete shard namespaces (nested clusters) after run")
+)
+
+type testEvent struct {
+	Action  string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+type lease struct {
+	kconfig string
+	sshHost string
+}
+
+// shardRun is one go test process on one cluster
+type shardRun struct {
+	name      string
+	slot      int
+	lease     *lease
+	tests     []string
+	every     []string
+	passArgs  []string
+	completed map[string]testEvent
+	report    string
+}
+
+type leasePool struct {
+	mx     sync.Mutex
+	leases []*lease
+}
+
+func (p *leasePool) take() *lease {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	if len(p.leases) == 0 {
+		return nil
+	}
+	l := p.leases[0]
+	p.leases = p.leases[1:]
+	return l
+}
+
+func (p *leasePool) put(l *lease) {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	p.leases = append(p.leases, l)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func listTests(path, run string, every []string) ([]string, error) {
+	out, err := exec.Command("go", "test", "-list", ".", path).Output()
+	if err != nil {
+		return nil, fmt.Errorf("list tests: %w", err)
+	}
+
+	re, err := regexp.Compile(run)
+	if err != nil {
+		return nil, err
+	}
+	var tests []string
+	for _, name := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(name, "Test") && re.MatchString(name) && !slices.Contains(every, name) {
+			tests = append(tests, name)
+		}
+	}
+	return tests, nil
+}
+
+func readHistory(path string) map[string]float64 {
+	history := map[string]float64{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return history
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		log.Printf("Invalid durations history %s: %s", path, err.Error())
+	}
+	return history
+}
+
+func writeHistory(path string, history map[string]float64) error {
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// balance splits tests into n shards: the longest test goes to the least loaded shard
+func balance(tests []string, history map[string]float64, n int) [][]string {
+	duration := func(name string) float64 {
+		if d, ok := history[name]; ok {
+			return d
+		}
+		return defaultDuration
+	}
+
+	sorted := slices.Clone(tests)
+	sort.SliceStable(sorted, func(i, j int) bool { return duration(sorted[i]) > duration(sorted[j]) })
+
+	shards := make([][]string, n)
+	load := make([]float64, n)
+	for _, name := range sorted {
+		i := slices.Index(load, slices.Min(load))
+		shards[i] = append(shards[i], name)
+		load[i] += duration(name)
+	}
+	for i := range shards {
+		// keep source order inside shard
+		sort.SliceStable(shards[i], func(a, b int) bool {
+			return slices.Index(tests, shards[i][a]) < slices.Index(tests, shards[i][b])
+		})
+		log.Printf("Shard %d: %.0fm %v", i, load[i]/60, shards[i])
+	}
+	return shards
+}
+
+func (r *shardRun) args() []string {
+	names := make([]string, 0, len(r.tests)+len(r.every))
+	for _, name := range append(slices.Clone(r.tests), r.every...) {
+		names = append(names, regexp.QuoteMeta(name))
+	}
+
+	args := []string{"test", "-json", "-count=1", "-timeout", timeoutFlag.String(),
+		"-run", "^(" + strings.Join(names, "|") + ")$", *testsFlag}
+
+	hvPort := strconv.Itoa(*basePortFlag + 2*r.slot)
+	nestedPort := strconv.Itoa(*basePortFlag + 2*r.slot + 1)
+	switch {
+	case r.lease != nil:
+		args = append(args, "-kconfig", r.lease.kconfig, "-sshhost", r.lease.sshHost, "-namespace", r.name)
+	case *keepFlag:
+		args = append(args, "-namespace", r.name, "-kconfig", "kube-nested-"+r.name+".config")
+	default:
+		args = append(args, "-namespacecleanup", r.name, "-kconfig", "kube-nested-"+r.name+".config")
+	}
+	args = append(args, "-hvport", hvPort, "-nestedport", nestedPort, "-jsonreport", r.report)
+	return append(args, r.passArgs...)
+}
+
+// exec runs shard tests, returns tests lost due to cluster (process) failure
+func (r *shardRun) exec() (lost []string, err error) {
+	logFile, err := os.Create(filepath.Join(*outFlag, r.name+".log"))
+	if err != nil {
+		return r.tests, err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command("go", r.args()...)
+	cmd.Stderr = logFile
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return r.tests, err
+	}
+	log.Printf("[%s] go %s", r.name, strings.Join(cmd.Args[1:], " "))
+	if err := cmd.Start(); err != nil {
+		return r.tests, err
+	}
+
+	started := map[string]bool{}
+	last, panicked := "", false
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		e := testEvent{}
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			_, _ = fmt.Fprintln(logFile, scanner.Text())
+			continue
+		}
+		_, _ = logFile.WriteString(e.Output)
+		panicked = panicked || strings.HasPrefix(e.Output, "panic: ")
+		if e.Test == "" || strings.Contains(e.Test, "/") {
+			continue
+		}
+		switch e.Action {
+		case "run":
+			started[e.Test], last = true, e.Test
+		case "pass", "fail", "skip":
+			r.completed[e.Test] = e
+			log.Printf("[%s] %s %s (%.0fs)", r.name, strings.ToUpper(e.Action), e.Test, e.Elapsed)
+		}
+	}
+	err = cmd.Wait()
+
+	for _, name := range r.tests {
+		if !started[name] {
+			lost = append(lost, name)
+		}
+	}
+	// process died mid-test (killed or panicked): the running test was interrupted by cluster failure
+	action := r.completed[last].Action
+	if err != nil && last != "" && !slices.Contains(r.every, last) && (action == "" || panicked && action == "fail") {
+		delete(r.completed, last)
+		lost = append([]string{last}, lost...)
+	}
+	return lost, err
+}
+
+// runSlot runs tests on cluster, tests lost due to cluster failure run on a new cluster
+func runSlot(slot int, tests, every, passArgs []string, pool *leasePool) (runs []*shardRun, lost []string) {
+	for attempt := 0; len(tests) > 0; attempt++ {
+		r := &shardRun{
+			name:      fmt.Sprintf("%s-%d", *nsFlag, slot),
+			slot:      slot,
+			tests:     tests,
+			every:     every,
+			passArgs:  passArgs,
+			completed: map[string]testEvent{},
+		}
+		if attempt > 0 {
+			r.name += fmt.Sprintf("-r%d", attempt)
+		}
+		if pool != nil {
+			if r.lease = pool.take(); r.lease == nil {
+				log.Printf("[%s] no free clusters", r.name)
+				return runs, tests
+			}
+		}
+		r.report, _ = filepath.Abs(filepath.Join(*outFlag, r.name+".json"))
+
+		lostTests, err := r.exec()
+		runs = append(runs, r)
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			log.Printf("[%s] %s", r.name, err.Error())
+		}
+		if len(lostTests) == 0 {
+			if r.lease != nil {
+				pool.put(r.lease)
+			}
+			return runs, nil
+		}
+		if attempt >= *retriesFlag {
+			log.Printf("[%s] %d tests not run: %v", r.name, len(lostTests), lostTests)
+			return runs, lostTests
+		}
+		log.Printf("[%s] cluster failed, %d tests will run on a new cluster: %v", r.name, len(lostTests), lostTests)
+		tests = lostTests
+	}
+	return runs, nil
+}
+
+func parseLeases(s string) (*leasePool, error) {
+	if s == "" {
+		return nil, nil
+	}
+	pool := &leasePool{}
+	for _, item := range splitList(s) {
+		kconfig, sshHost, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid lease %s, expected kconfig=user@host", item)
+		}
+		pool.leases = append(pool.leases, &lease{kconfig: kconfig, sshHost: sshHost})
+	}
+	return pool, nil
+}
+
+func statusOf(action string) string {
+	switch action {
+	case "pass":
+		return util.StatusPassed
+	case "skip":
+		return util.StatusSkipped
+	}
+	return util.StatusFailed
+}
+
+// mergeResults merges shard reports, tests without report case are added from go test events
+func mergeResults(runs []*shardRun, lost []string) (*util.Report, bool) {
+	var reports []*util.Report
+	for _, r := range runs {
+		rep, err := util.ReadReport(r.report)
+		if err != nil {
+			log.Printf("[%s] no report: %s", r.name, err.Error())
+			rep = &util.Report{Namespace: r.name}
+		}
+
+		inReport := map[string]bool{}
+		for _, c := range rep.Tests {
+			inReport[c.Name] = true
+		}
+		for _, name := range append(slices.Clone(r.tests), r.every...) {
+			if e, ok := r.completed[name]; ok && !inReport[name] {
+				rep.Tests = append(rep.Tests, &util.ReportCase{Name: name, Kind: "test", Status: statusOf(e.Action), Duration: e.Elapsed})
+			}
+		}
+		reports = append(reports, rep)
+	}
+
+	merged := util.MergeReports(reports...)
+	for _, name := range lost {
+		merged.Tests = append(merged.Tests, &util.ReportCase{
+			Name:     name,
+			Kind:     "test",
+			Status:   util.StatusFailed,
+			Failures: []string{"not run: cluster failure"},
+		})
+	}
+
+	ok := len(lost) == 0
+	for _, c := range merged.Tests {
+		ok = ok && c.Status != util.StatusFailed
+	}
+	return merged, ok
+}
+
+// passArg returns value of go test flag (-name value, -name=value) from passed args
+func passArg(args []string, name string) string {
+	for i, a := range args {
+		key, value, found := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		if key != name || !strings.HasPrefix(a, "-") {
+			continue
+		}
+		if found {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+func main() {
+	log.SetFlags(log.Ltime)
+	_ = fs.Parse(os.Args[1:])
+	passArgs := fs.Args()
+
+	pool, err := parseLeases(*leaseFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if pool == nil && passArg(passArgs, "hypervisorkconfig") == "" {
+		log.Fatal("-lease or -- -hypervisorkconfig required")
+	}
+	if pool != nil && len(pool.leases) < *shardsFlag {
+		*shardsFlag = len(pool.leases)
+	}
+
+	every := splitList(*everyFlag)
+	tests, err := listTests(*testsFlag, *runFlag, every)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(tests) < *shardsFlag {
+		*shardsFlag = len(tests)
+	}
+	if err := os.MkdirAll(*outFlag, 0755); err != nil {
+		log.Fatal(err)
+	}
+	if pool == nil {
+		// shards share key for VMs, generate it before parallel cluster creation
+		keyDir := filepath.Join(*testsFlag, util.KubePath)
+		if err := os.MkdirAll(keyDir, 0700); err != nil {
+			log.Fatal(err)
+		}
+		keyType := util.ClusterSshKeyType(passArg(passArgs, "clustertype"))
+		priv, pub := util.SshKeyNames(keyType)
+		if err := util.GenerateSSHKeys(keyType, filepath.Join(keyDir, priv), filepath.Join(keyDir, pub)); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	history := readHistory(*historyFlag)
+	shards := balance(tests, history, *shardsFlag)
+
+	var (
+		wg       sync.WaitGroup
+		mx       sync.Mutex
+		allRuns  []*shardRun
+		allLost  []string
+		startRun = time.Now()
+	)
+	for slot, shardTests := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runs, lost := runSlot(slot, shardTests, every, passArgs, pool)
+			mx.Lock()
+			defer mx.Unlock()
+			allRuns = append(allRuns, runs...)
+			allLost = append(allLost, lost...)
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range allRuns {
+		for name, e := range r.completed {
+			if !slices.Contains(every, name) {
+				history[name] = e.Elapsed
+			}
+		}
+	}
+	if err := writeHistory(*historyFlag, history); err != nil {
+		log.Printf("Can't write durations history: %s", err.Error())
+	}
+
+	merged, ok := mergeResults(allRuns, allLost)
+	if *jsonFlag != "" {
+		if err := merged.WriteJSON(*jsonFlag); err != nil {
+			log.Printf("Can't write JSON report: %s", err.Error())
+		}
+	}
+	if *junitFlag != "" {
+		if err := merged.WriteJUnit(*junitFlag); err != nil {
+			log.Printf("Can't write JUnit report: %s", err.Error())
+		}
+	}
+
+	log.Printf("%d tests on %d clusters in %s", len(tests), len(allRuns), time.Since(startRun).Round(time.Second))
+	if !ok {
+		os.Exit(1)
+	}
+}
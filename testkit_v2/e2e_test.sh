@@ -8,7 +8,7 @@ nc="\033[0m"
 
 DIR="$(cd "$(dirname "$0")" && pwd)"
 OPTIONS="hi:v"
-LONGOPTS="help,ssh-key:,ssh-host:,kconfig:,verbose,debug,tree,skip-optional,run:,skip:,ns:,namespace:,hypervisor-kconfig:,parallel:"
+LONGOPTS="help,ssh-key:,ssh-host:,kconfig:,verbose,debug,tree,skip-optional,run:,skip:,ns:,namespace:,hypervisor-kconfig:,parallel:,node-timeout:,retry:,soak:"
 
 function usage() {
   >&2 cat <<EOF
@@ -62,7 +62,16 @@ function usage() {
         Set test name space
 
     --parallel N:
-        Allow parallel execution of test functions
+        Allow parallel execution of test functions (N node tests at the same time)
+
+    --node-timeout 15m:
+        Timeout for each node test
+
+    --retry TestLvg=2:
+        Retries of failed node tests per test or node group
+
+    --soak 12h:
+        Repeat selected tests for the duration (stop at first failed iteration), go test timeout is disabled
 
   ${bold}Env:${normal}
     export licensekey=s6Cr6T
@@ -126,6 +135,7 @@ function main() {
   local verbose=false
   local debug=false
   local parallel=0
+  local soak=false
 
   case "$1" in
     Local) run_stand="lockal"; shift ;;
@@ -155,6 +165,9 @@ function main() {
       --tree) test_args+=(-tree); shift ;;
       --skip-optional) test_args+=(-skipoptional); shift ;;
       --parallel) parallel=$2; shift 2 ;;
+      --node-timeout) test_args+=(-nodetimeout "$2"); shift 2 ;;
+      --retry) test_args+=(-retry "$2"); shift 2 ;;
+      --soak) soak=true; test_args+=(-soak "$2"); shift 2 ;;
 
       -- ) shift; break ;;
       * ) break ;;
@@ -173,7 +186,8 @@ function main() {
 
   if [[ -z "$ssh_host" ]]; then echo -e "  ${red}No '--ssh-host' command line argument${nc}\n"; usage; exit 1; fi
   if [ $parallel -eq 1 ]; then test_flags+=(-parallel $parallel); test_args+=(-notparallel); fi
-  if [ $parallel -gt 1 ]; then test_flags+=(-parallel $parallel); fi
+  if [ $parallel -gt 1 ]; then test_flags+=(-parallel $parallel); test_args+=(-nodeparallel $parallel); fi
+  if $soak; then test_flags+=(-timeout 0); fi  # soak duration limits the run
 
   test_args+=(-stand "${run_stand}")
 
@@ -183,7 +197,7 @@ function main() {
       ;;
     metal)
       test_flags+=(-skip="TestFatal/ignore") # Fake example
-      test_flags+=(-timeout "30m")
+      if ! $soak; then test_flags+=(-timeout "30m"); fi
       run_bare_metal
       ;;
   esac
@@ -24,6 +24,7 @@ import (
 
 func TestNodeHealthCheck(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	util.Track(t)
 
 	nodeMap := cluster.MapLabelNodes(nil)
 	for label, nodes := range nodeMap {
@@ -34,3 +35,20 @@ func TestNodeHealthCheck(t *testing.T) {
 		}
 	}
 }
+
+// Audit of stand: BlockDevices match lsblk on nodes
+func TestBlockDeviceConsistency(t *testing.T) {
+	cluster := util.EnsureCluster("", "")
+	if !util.BdAudit {
+		t.Skip("stand audit, run with -bdaudit")
+	}
+	cluster.Require(t, util.Requirements{Modules: []string{util.SDSNodeConfiguratorModuleName}})
+
+	issues, err := cluster.AuditBlockDevices()
+	if err != nil {
+		t.Error(err.Error())
+	}
+	for _, issue := range issues {
+		t.Error(issue.String())
+	}
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package integration
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	util "github.com/deckhouse/sds-e2e/util"
 	snc "github.com/deckhouse/sds-node-configurator/api/v1alpha1"
@@ -36,26 +38,27 @@ func cleanup01() {
 
 func TestLvg(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{Modules: []string{util.SDSNodeConfiguratorModuleName}})
 	prepareClr()
 	t.Cleanup(cleanup01)
 
-	t.Run("create", func(t *testing.T) {
+	util.Step(t, "create", func(t *testing.T) {
 		cluster.RunTestGroupNodes(t, nil, directLVGCreate)
 		if err := cluster.WaitLVGsReady(util.LvgFilter{Name: util.WhereLike{testPrefix}}); err != nil {
 			t.Fatal(err.Error())
 		}
 	})
 
-	t.Run("resize", func(t *testing.T) {
+	util.Step(t, "resize", func(t *testing.T) {
 		cluster.RunTestGroupNodes(t, util.WhereNotLike{"Deb"}, directLVGResize)
-	})
+	}, "create")
 
-	t.Run("delete", directLVGDelete)
+	util.Step(t, "delete", directLVGDelete, "create")
 }
 
 func directLVGCreate(t *util.T) {
 	bdCount := (t.Node.Id % 3) + 1
-	cluster := util.EnsureCluster("", "")
+	cluster := util.EnsureCluster("", "").WithContext(t.Context())
 
 	lvgs, _ := cluster.ListLVG(util.LvgFilter{Name: util.WhereLike{testPrefix}, Node: util.WhereIn{t.Node.Name}})
 	if len(lvgs) > 0 {
@@ -71,14 +74,14 @@ func directLVGCreate(t *util.T) {
 			if err != nil {
 				t.Fatalf("Hypervisor CreateVMBD error: %s", err.Error())
 			}
-			util.Debugf("Attach VMBD %s", vmdName)
+			t.Debugf("Attach VMBD %s", vmdName)
 		}
 
 		_ = hypervisorClr.WaitVmbdAttached(util.VmBdFilter{NameSpace: util.TestNS, VmName: t.Node.Name})
 	}
 
 	var bds []snc.BlockDevice
-	if err := util.RetrySec(5, func() error {
+	if err := util.WaitFor(t.Context(), 5*time.Second, func(context.Context) error {
 		bds, _ = cluster.ListBD(util.BdFilter{Node: t.Node.Name, Consumable: true})
 		if len(bds) < bdCount {
 			return fmt.Errorf("%s: not enough Device to create LVG (%d < %d)", t.Node.Name, len(bds), bdCount)
@@ -93,18 +96,18 @@ func directLVGCreate(t *util.T) {
 		if err := cluster.CreateLVG(name, t.Node.Name, []string{bd.Name}); err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
-		util.Debugf("LVG %s created for BD %s", name, bd.Name)
+		t.Debugf("LVG %s created for BD %s", name, bd.Name)
 	}
 }
 
 func directLVGResize(t *util.T) {
-	cluster := util.EnsureCluster("", "")
+	cluster := util.EnsureCluster("", "").WithContext(t.Context())
 
 	if util.HypervisorKubeConfig != "" {
 		// create bd on VM
 		hypervisorClr := util.EnsureCluster(util.HypervisorKubeConfig, "")
 		vmdName := fmt.Sprintf("%s-data-%d", t.Node.Name, 21)
-		util.Debugf("Add VMBD %s", vmdName)
+		t.Debugf("Add VMBD %s", vmdName)
 		_ = hypervisorClr.CreateVMBD(t.Node.Name, vmdName, util.HvStorageClass, 8)
 
 		_ = hypervisorClr.WaitVmbdAttached(util.VmBdFilter{NameSpace: util.TestNS, VmName: t.Node.Name})
@@ -136,7 +139,7 @@ func directLVGResize(t *util.T) {
 		t.Fatalf("LVG updating: %s", err.Error())
 	}
 
-	if err := util.RetrySec(30, func() error {
+	if err := util.WaitFor(t.Context(), 30*time.Second, func(context.Context) error {
 		lvg, _ := cluster.GetLvg(lvg.Name)
 		if lvg.Status.VGSize.Value() > origSize {
 			return nil
@@ -153,7 +156,7 @@ func directLVGDelete(t *testing.T) {
 		t.Fatalf("LVG deleting error: %s", err.Error())
 	}
 
-	if err := util.RetrySec(10, func() error {
+	if err := util.WaitFor(t.Context(), 10*time.Second, func(context.Context) error {
 		lvgs, err := cluster.ListLVG(util.LvgFilter{Name: util.WhereLike{testPrefix}})
 		if err != nil {
 			return err
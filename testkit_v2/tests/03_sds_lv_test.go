@@ -19,6 +19,7 @@ package integration
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	util "github.com/deckhouse/sds-e2e/util"
 	coreapi "k8s.io/api/core/v1"
@@ -30,14 +31,18 @@ const (
 )
 
 func TestPVC(t *testing.T) {
-	t.Run("PVC creating", testPVCCreate)
-	t.Run("PVC resizing", testPVCResize)
-	t.Run("PVC deleting", testPVCDelete)
+	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{Modules: []string{util.SDSLocalVolumeModuleName}})
+	util.LocalStorageClassFixture(scName).Use(t)
+
+	util.Step(t, "PVC creating", testPVCCreate)
+	util.Step(t, "PVC resizing", testPVCResize, "PVC creating")
+	util.Step(t, "PVC resizing with CSI restart", testPVCResizeCsiRestart, "PVC resizing")
+	util.Step(t, "PVC deleting", testPVCDelete, "PVC creating")
 }
 
 func testPVCCreate(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
-	_, _ = cluster.CreateLocalThickStorageClass(scName)
 
 	pvc, err := cluster.CreatePVCInTestNS("test-pvc", scName, "1Gi")
 	if err != nil {
@@ -71,6 +76,36 @@ func testPVCResize(t *testing.T) {
 	}
 }
 
+func testPVCResizeCsiRestart(t *testing.T) {
+	cluster := util.EnsureCluster("", "") // reads flags
+	if !util.Disruptive {
+		t.Skip("disruptive step, run with -disruptive")
+	}
+
+	pvcList, err := cluster.ListPVC(util.TestNS)
+	if err != nil {
+		t.Fatal("PVC getting:", err)
+	}
+	names := []string{}
+	for _, pvc := range pvcList {
+		newSize := resource.MustParse("3Gi")
+		fault := cluster.InjectWhen(cluster.PvcResizingTrigger(pvc.Name), cluster.DeletePodsFault(util.CsiControllerPods, ""), time.Minute)
+		t.Cleanup(func() { _ = fault.Wait() })
+		pvc.Spec.Resources.Requests[coreapi.ResourceStorage] = newSize
+		if err := cluster.UpdatePVC(&pvc); err != nil {
+			t.Fatalf("PVC %s resizing to %s: %s", pvc.Name, newSize.String(), err.Error())
+		}
+		if err := fault.Wait(); err != nil {
+			t.Error(err.Error())
+		}
+		names = append(names, pvc.Name)
+	}
+
+	if err := cluster.WaitConverged(util.Convergence{Pvcs: names}, 300); err != nil {
+		t.Error(err.Error())
+	}
+}
+
 func testPVCDelete(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
 
@@ -1,7 +1,9 @@
 package integration
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -16,36 +18,37 @@ import (
 // 1 - Create LVMVolumeGroup. Check VG, PV auto creating
 func TestLvgThickCreateCascade(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{BdCount: 1, BdSize: 2})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		nName := t.Node.Name
-		cmdPrefix := "sudo " + lvmD8 + " "
+		lvmBin := lvmD8
 		if t.Node.Id%2 == 1 {
-			cmdPrefix = "sudo lvm "
+			lvmBin = "lvm"
 			if strings.Contains(t.Node.Raw.Status.NodeInfo.OSImage, "Debian") {
 				_, _, _ = cluster.ExecNode(nName, []string{"sudo", "apt", "-y", "install", "lvm2"})
 			}
 		}
 
-		lvg, err := directLvgCreate(nName, 2)
+		lvg, err := util.ThickLvgFixture(nName, 2).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := cluster.ExecNodeRespContains(nName, cmdPrefix+"vgdisplay --units B", []string{
-			"VG Name\\s+" + lvg.Name,
-			"VG Size\\s+21[45]\\d{7} B", //2.00 GiB(2147483648 B) +- 20 MiB
-			"Alloc PE / Size[\\s\\d]+ 0 / 0 B",
-		}); err != nil {
-			t.Error(err.Error())
+		lvm, err := cluster.GetNodeLvmWith(nName, lvmBin)
+		if err != nil {
+			t.Fatal(err.Error())
 		}
-		if err := cluster.ExecNodeRespContains(nName, cmdPrefix+"pvdisplay --units B", []string{
-			"VG Name\\s+" + lvg.Name,
-			"PV Size\\s+21[45]\\d{7} B  /", //2.00 GiB(2147483648 B) +- 20 MiB
-		}); err != nil {
-			t.Error(err.Error())
+		if vg := lvm.VG(lvg.Name); vg == nil {
+			t.Errorf("%s: no VG %s", nName, lvg.Name)
+		} else if !sizeNear(vg.Size, 2*gib, 20*mib) || vg.Allocated() != 0 {
+			t.Errorf("VG %s: size %d, allocated %d", lvg.Name, vg.Size, vg.Allocated())
+		}
+		if pvs := lvm.VgPVs(lvg.Name); len(pvs) != 1 || !sizeNear(pvs[0].Size, 2*gib, 20*mib) {
+			t.Errorf("VG %s PVs: %+v", lvg.Name, pvs)
 		}
 	})
 }
@@ -53,26 +56,21 @@ func TestLvgThickCreateCascade(t *testing.T) {
 // 2 - Delete LVMVolumeGroup. Check VG, PV auto deleting
 func TestLvgThickDeleteCascadeManually(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{BdCount: 1, BdSize: 3})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		nName := t.Node.Name
-		lvg, err := directLvgCreate(nName, 3)
+		lvg, err := util.ThickLvgFixture(nName, 3).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
 		_ = cluster.DeleteLvgAndWait(util.LvgFilter{Name: lvg.Name})
 
-		if err := cluster.ExecNodeRespNotContains(nName, vgdisplayCmd, []string{
-			"VG Name ",
-		}); err != nil {
-			t.Error(err.Error())
-		}
-		if err := cluster.ExecNodeRespNotContains(nName, pvdisplayCmd, []string{
-			"VG Name ",
-		}); err != nil {
+		if err := checkNodeNoLvm(nName); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -81,21 +79,21 @@ func TestLvgThickDeleteCascadeManually(t *testing.T) {
 // 3 - Increase BlockDevice size. Check LVG, PV, VG resizing
 func TestLvgThickDiskResize(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
-	if util.HypervisorKubeConfig == "" {
-		t.Fatal("No HypervisorKubeConfig to resize VD")
-	}
+	cluster.Require(t, util.Requirements{Hypervisor: true})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	hvCluster := util.EnsureCluster(util.HypervisorKubeConfig, "")
+	util.RetryNodes(t, 2)
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		nName := t.Node.Name
-		lvg, err := directLvgCreate(nName, 1)
+		lvg, err := util.ThickLvgFixture(nName, 1).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{1}, []string{"1.00g"}, "1.00g"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{1}, []int64{1024}, 1024); err != nil {
 			t.Error(err.Error())
 		}
 
@@ -110,8 +108,13 @@ func TestLvgThickDiskResize(t *testing.T) {
 			}
 		}
 
-		if err := util.RetrySec(10, func() error {
-			return checkNodeLvgSize(lvg.Name, []float32{2}, []string{"2.00g"}, "2.00g")
+		if err := util.WaitFor(t.Context(), 10*time.Second, func(context.Context) error {
+			return checkNodeLvgSize(cluster, lvg.Name, []float32{2}, []int64{2048}, 2048)
+		}); err != nil {
+			t.Error(err.Error())
+		}
+		if err := util.WaitFor(t.Context(), 30*time.Second, func(context.Context) error {
+			return cluster.CheckBlockDevices(nName)
 		}); err != nil {
 			t.Error(err.Error())
 		}
@@ -121,24 +124,23 @@ func TestLvgThickDiskResize(t *testing.T) {
 // 4 - Add second BlockDevice to LVG. Check LVG, PV, VG resizing
 func TestLvgThickAddBd(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
-	if util.HypervisorKubeConfig == "" {
-		t.Fatal("No HypervisorKubeConfig to add VD")
-	}
+	cluster.Require(t, util.Requirements{Hypervisor: true})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		nName := t.Node.Name
-		lvg, err := directLvgCreate(nName, 1)
+		lvg, err := util.ThickLvgFixture(nName, 1).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{1}, []string{"1.00g"}, "1.00g"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{1}, []int64{1024}, 1024); err != nil {
 			t.Error(err.Error())
 		}
 
-		bds, err := getOrCreateConsumableBlockDevices(nName, 2, 1)
+		bds, err := cluster.GetOrCreateConsumableBds(nName, 2, 1)
 		if err != nil {
 			t.Fatal(err.Error())
 		}
@@ -149,7 +151,7 @@ func TestLvgThickAddBd(t *testing.T) {
 			t.Fatalf("LVG updating: %s", err.Error())
 		}
 
-		if err := util.RetrySec(20, func() error {
+		if err := util.WaitFor(t.Context(), 20*time.Second, func(context.Context) error {
 			lvg, err := cluster.GetLvg(lvg.Name)
 			if err != nil {
 				return err
@@ -162,7 +164,7 @@ func TestLvgThickAddBd(t *testing.T) {
 			t.Fatal(err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{1, 2}, []string{"1.00g", "2.00g"}, "3.00g"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{1, 2}, []int64{1024, 2048}, 3072); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -171,21 +173,21 @@ func TestLvgThickAddBd(t *testing.T) {
 // 5 - Reconnect BlockDevice to another path. Check LVG no changes
 func TestLvgThickReconnectBd(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
-	if util.HypervisorKubeConfig == "" {
-		t.Fatal("No HypervisorKubeConfig to add VD")
-	}
+	cluster.Require(t, util.Requirements{Hypervisor: true})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	hvCluster := util.EnsureCluster(util.HypervisorKubeConfig, "")
+	util.RetryNodes(t, 2)
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		nName := t.Node.Name
-		lvg, err := directLvgCreate(nName, 1)
+		lvg, err := util.ThickLvgFixture(nName, 1).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{1}, []string{"1.00g"}, "1.00g"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{1}, []int64{1024}, 1024); err != nil {
 			t.Error(err.Error())
 		}
 
@@ -196,10 +198,15 @@ func TestLvgThickReconnectBd(t *testing.T) {
 		if err != nil {
 			t.Fatal(err.Error())
 		}
+		t.Cleanup(func() {
+			for _, vmbd := range vmbds {
+				_ = hvCluster.AttachVmbd(nName, vmbd.Name)
+			}
+		})
 		bdName := lvg.Spec.BlockDeviceSelector.MatchExpressions[0].Values[0]
 		_ = cluster.DeleteBd(util.BdFilter{Name: bdName})
 
-		_, _ = getOrCreateConsumableBlockDevices(nName, 2, 1)
+		_, _ = cluster.GetOrCreateConsumableBds(nName, 2, 1)
 
 		for _, vmbd := range vmbds {
 			_ = hvCluster.AttachVmbd(nName, vmbd.Name)
@@ -220,7 +227,7 @@ func TestLvgThickReconnectBd(t *testing.T) {
 		if lvg.Status.Phase != "Ready" {
 			t.Fatalf("LVG %s not Ready: %s", lvg.Name, lvg.Status.Phase)
 		}
-		if err := checkNodeLvgSize(lvg.Name, []float32{1}, []string{"1.00g"}, "1.00g"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{1}, []int64{1024}, 1024); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -229,42 +236,177 @@ func TestLvgThickReconnectBd(t *testing.T) {
 // 6 - Add new LV to empty VG. Check VG allocated size increase
 func TestVgThickAddLv(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{BdCount: 1, BdSize: 1})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		nName := t.Node.Name
 
 		vgName := "e2e-vg-" + util.RandString(4)
-		bds, err := getOrCreateConsumableBlockDevices(nName, 1, 1)
+		bds, err := cluster.GetOrCreateConsumableBds(nName, 1, 1)
 		if err != nil {
 			t.Fatal(err.Error())
 		}
 		stOut, stErr, err := cluster.ExecNode(nName, []string{"sudo", lvmD8, "vgcreate", vgName, bds[0].Status.Path})
 		if err != nil {
-			util.Debugf("vgcreate stOut: %s", stOut)
-			util.Debugf("vgcreate stErr: %s", stErr)
+			t.Debugf("vgcreate stOut: %s", stOut)
+			t.Debugf("vgcreate stErr: %s", stErr)
 			t.Fatal(err.Error())
 		}
 
-		if err := cluster.ExecNodeRespContains(nName, vgdisplayCmd, []string{
-			"VG Name\\s+" + vgName,
-			"Alloc PE / Size[\\s\\d]+ 0 / 0",
-		}); err != nil {
+		if err := checkNodeVgAllocated(nName, vgName, 0); err != nil {
 			t.Error(err.Error())
 		}
 
 		stOut, stErr, err = cluster.ExecNode(nName, []string{"sudo", lvmD8, "lvcreate", "-L", "500m", vgName})
 		if err != nil {
-			util.Debugf("vgcreate stOut: %s", stOut)
-			util.Debugf("vgcreate stErr: %s", stErr)
+			t.Debugf("vgcreate stOut: %s", stOut)
+			t.Debugf("vgcreate stErr: %s", stErr)
 			t.Fatal(err.Error())
 		}
 
-		if err := cluster.ExecNodeRespContains(nName, vgdisplayCmd, []string{
-			"VG Name\\s+" + vgName,
-			"Alloc PE / Size[\\s\\d]+ / 500.00 MiB",
-		}); err != nil {
+		if err := checkNodeVgAllocated(nName, vgName, 500*mib); err != nil {
+			t.Error(err.Error())
+		}
+	})
+}
+
+// 7 - Delete agent pod while LVG is Pending. Check LVG, BD converge
+func TestLvgThickAgentDeletePending(t *testing.T) {
+	cluster := util.EnsureCluster("", "") // reads flags
+	if !util.Disruptive {
+		t.Skip("disruptive test, run with -disruptive")
+	}
+	cluster.Require(t, util.Requirements{BdCount: 1, BdSize: 1})
+	prepareClr()
+	t.Cleanup(cleanup05)
+
+	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
+		nName := t.Node.Name
+		bds, err := cluster.GetOrCreateConsumableBds(nName, 1, 1)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		lvgName := "e2e-lvg-" + util.RandString(4)
+		t.Cleanup(func() {
+			_ = cluster.DeleteLvgAndWait(util.LvgFilter{Name: lvgName})
+		})
+		fault := cluster.InjectWhen(cluster.LvgPhaseTrigger(lvgName, "Pending"), cluster.DeletePodsFault(util.AgentPods, nName), time.Minute)
+		t.Cleanup(func() { _ = fault.Wait() })
+		if err := cluster.CreateLVG(lvgName, nName, []string{bds[0].Name}); err != nil {
+			t.Fatalf("LVG creating: %s", err.Error())
+		}
+		if err := fault.Wait(); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if err := cluster.WaitConverged(util.Convergence{Lvgs: []string{lvgName}, Nodes: []string{nName}}, 120); err != nil {
+			t.Error(err.Error())
+		}
+		if err := checkNodeLvgSize(cluster, lvgName, []float32{1}, []int64{1024}, 1024); err != nil {
+			t.Error(err.Error())
+		}
+	})
+}
+
+// 8 - Reboot node with LVG. Check LVG, BD converge
+func TestLvgThickNodeReboot(t *testing.T) {
+	cluster := util.EnsureCluster("", "") // reads flags
+	if !util.Disruptive {
+		t.Skip("disruptive test, run with -disruptive")
+	}
+	cluster.Require(t, util.Requirements{BdCount: 1, BdSize: 1})
+	prepareClr()
+	t.Cleanup(cleanup05)
+
+	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
+		if t.Node.Id > 0 {
+			t.Skip("one node of group is rebooted")
+		}
+		nName := t.Node.Name
+		lvg, err := util.ThickLvgFixture(nName, 1).Acquire(t)
+		if err != nil {
+			t.Fatalf("LVG creating: %s", err.Error())
+		}
+
+		if err := cluster.Inject(cluster.RebootNodeFault(nName)); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if err := cluster.WaitConverged(util.Convergence{Lvgs: []string{lvg.Name}, Nodes: []string{nName}}, 300); err != nil {
+			t.Error(err.Error())
+		}
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{1}, []int64{1024}, 1024); err != nil {
+			t.Error(err.Error())
+		}
+	})
+}
+
+// 9 - Remove static node with LVG and add it back. Check LVG, BD converge
+func TestLvgThickNodeRejoin(t *testing.T) {
+	cluster := util.EnsureCluster("", "") // reads flags
+	if !util.Disruptive {
+		t.Skip("disruptive test, run with -disruptive")
+	}
+	cluster.Require(t, util.Requirements{StaticNodes: true, BdCount: 1, BdSize: 1})
+	prepareClr()
+	t.Cleanup(cleanup05)
+
+	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
+		if t.Node.Id > 0 {
+			t.Skip("one node of group is removed")
+		}
+		nName := t.Node.Name
+		lvg, err := util.ThickLvgFixture(nName, 1).Acquire(t)
+		if err != nil {
+			t.Fatalf("LVG creating: %s", err.Error())
+		}
+		vgName := lvg.Spec.ActualVGNameOnTheNode
+		paths := []string{}
+		for _, d := range lvg.Status.Nodes[0].Devices {
+			paths = append(paths, d.Path)
+		}
+		if err := checkVgBds(cluster, nName, vgName, paths); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		removed, err := cluster.RemoveStaticNode(nName)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		removed.Devices = nil // keep VG on disks, LVG must be picked up after rejoin
+		// BlockDevices of removed node may stay, but its disks must not be offered for new LVGs
+		bds, err := cluster.ListBD(util.BdFilter{Node: nName})
+		if err != nil {
+			t.Error(err.Error())
+		}
+		for _, bd := range bds {
+			if bd.Status.Consumable {
+				t.Errorf("BlockDevice %s (%s) of removed node %s is consumable", bd.Name, bd.Status.Path, nName)
+			}
+		}
+
+		nName, err = cluster.ReAddStaticNode(removed)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if err := cluster.WaitConverged(util.Convergence{Lvgs: []string{lvg.Name}, Nodes: []string{nName}}, 300); err != nil {
+			t.Error(err.Error())
+		}
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{1}, []int64{1024}, 1024); err != nil {
+			t.Error(err.Error())
+		}
+		if err := checkVgBds(cluster, nName, vgName, paths); err != nil {
+			t.Error(err.Error())
+		}
+		if err := cluster.CheckBlockDevices(nName); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -275,44 +417,42 @@ func TestVgThickAddLv(t *testing.T) {
 // 1 - Create LVMVolumeGroup on ThinPools. Check VG, PV, LV auto creating
 func TestLvgThinCreateCascade(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{ThinProvisioning: true, BdCount: 1, BdSize: 4})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		nName := t.Node.Name
-		cmdPrefix := "sudo " + lvmD8 + " "
+		lvmBin := lvmD8
 		if t.Node.Id%2 == 1 {
-			cmdPrefix = "sudo lvm "
+			lvmBin = "lvm"
 			if strings.Contains(t.Node.Raw.Status.NodeInfo.OSImage, "Debian") {
 				_, _, _ = cluster.ExecNode(nName, []string{"sudo", "apt", "-y", "install", "lvm2"})
 			}
 		}
 
-		lvg, err := directLvgTpCreate(nName, 3.33)
+		lvg, err := thinLvgFixture(nName, 3.33).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := cluster.ExecNodeRespContains(nName, cmdPrefix+"vgdisplay --units B", []string{
-			"VG Name\\s+" + lvg.Name,
-			"VG Size\\s+4(27|28|29|30|31)\\d{7} B",         //4.00 GiB(4294967296 B) +- 20 MiB
-			"Alloc PE / Size\\s+\\d+ / 3(49|5\\d)\\d{7} B", //3.3 GiB(3543348019 B) +- 50 MiB
-		}); err != nil {
-			t.Error(err.Error())
+		lvm, err := cluster.GetNodeLvmWith(nName, lvmBin)
+		if err != nil {
+			t.Fatal(err.Error())
 		}
-		if err := cluster.ExecNodeRespContains(nName, cmdPrefix+"pvdisplay --units B", []string{
-			"VG Name\\s+" + lvg.Name,
-			"PV Size\\s+4(27|28|29|30|31)\\d{7} B  /", //4.00 GiB(4294967296 B) +- 20 MiB
-		}); err != nil {
-			t.Error(err.Error())
+		if vg := lvm.VG(lvg.Name); vg == nil {
+			t.Errorf("%s: no VG %s", nName, lvg.Name)
+		} else if !sizeNear(vg.Size, 4*gib, 20*mib) || !sizeNear(vg.Allocated(), 33*gib/10, 50*mib) {
+			t.Errorf("VG %s: size %d, allocated %d", lvg.Name, vg.Size, vg.Allocated())
 		}
-		if err := cluster.ExecNodeRespContains(nName, cmdPrefix+"lvdisplay", []string{
-			"LV Name\\s+thin-e2e-01",
-			"LV Name\\s+thin-e2e-02",
-			"LV Size\\s+1.00 GiB",
-			"LV Size\\s+2.33 GiB",
-		}); err != nil {
-			t.Error(err.Error())
+		if pvs := lvm.VgPVs(lvg.Name); len(pvs) != 1 || !sizeNear(pvs[0].Size, 4*gib, 20*mib) {
+			t.Errorf("VG %s PVs: %+v", lvg.Name, pvs)
+		}
+		for name, size := range map[string]float64{"thin-e2e-01": 1, "thin-e2e-02": 2.33} {
+			if tp := lvm.ThinPool(lvg.Name, name); tp == nil || !sizeNear(tp.Size, int64(size*float64(gib)), 10*mib) {
+				t.Errorf("%s thin pool %s: %+v", nName, name, tp)
+			}
 		}
 	})
 }
@@ -320,14 +460,16 @@ func TestLvgThinCreateCascade(t *testing.T) {
 // 2 - Delete LV before LVMVolumeGroup. Check VG, PV auto deleting
 func TestLvgThinDeleteCascadeManually(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{ThinProvisioning: true, BdCount: 1, BdSize: 2})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		var out string
 		nName := t.Node.Name
 
-		lvg, err := directLvgTpCreate(t.Node.Name, 1.8)
+		lvg, err := thinLvgFixture(t.Node.Name, 1.8).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
@@ -335,25 +477,13 @@ func TestLvgThinDeleteCascadeManually(t *testing.T) {
 		vgName := lvg.Spec.ActualVGNameOnTheNode
 		out, _, err = cluster.ExecNode(nName, []string{"sudo", lvmD8, "lvremove", "-y", "/dev/" + vgName + "/thin-e2e-01"})
 		if err != nil {
-			util.Debugf("lvremove output: %s", out)
+			t.Debugf("lvremove output: %s", out)
 			t.Fatal(err.Error())
 		}
 
 		_ = cluster.DeleteLvgAndWait(util.LvgFilter{Name: lvg.Name})
 
-		if err := cluster.ExecNodeRespNotContains(nName, vgdisplayCmd, []string{
-			"VG Name ",
-		}); err != nil {
-			t.Error(err.Error())
-		}
-		if err := cluster.ExecNodeRespNotContains(nName, pvdisplayCmd, []string{
-			"VG Name ",
-		}); err != nil {
-			t.Error(err.Error())
-		}
-		if err := cluster.ExecNodeRespNotContains(nName, lvdisplayCmd, []string{
-			"LV Name ",
-		}); err != nil {
+		if err := checkNodeNoLvm(nName); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -362,14 +492,16 @@ func TestLvgThinDeleteCascadeManually(t *testing.T) {
 // 3 - Delete LVMVolumeGroup. Check VG, PV still exist. Delete LV. Check VG, PV auto deleting
 func TestLvgThinDeleteCascadeK8s(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{ThinProvisioning: true, BdCount: 1, BdSize: 2})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		var out string
 		nName := t.Node.Name
 
-		lvg, err := directLvgTpCreate(nName, 1.6)
+		lvg, err := thinLvgFixture(nName, 1.6).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
@@ -378,42 +510,25 @@ func TestLvgThinDeleteCascadeK8s(t *testing.T) {
 		_ = cluster.DeleteLVG(util.LvgFilter{Name: lvg.Name})
 		time.Sleep(4 * time.Second)
 
-		if err := cluster.ExecNodeRespContains(nName, vgdisplayCmd, []string{
-			"VG Name\\s+" + lvg.Name,
-		}); err != nil {
-			t.Error(err.Error())
+		lvm, err := cluster.GetNodeLvm(nName)
+		if err != nil {
+			t.Fatal(err.Error())
 		}
-		if err := cluster.ExecNodeRespContains(nName, pvdisplayCmd, []string{
-			"VG Name\\s+" + lvg.Name,
-		}); err != nil {
-			t.Error(err.Error())
+		if lvm.VG(vgName) == nil || len(lvm.VgPVs(vgName)) == 0 {
+			t.Errorf("%s: VG %s or its PVs are deleted", nName, vgName)
 		}
-		if err := cluster.ExecNodeRespContains(nName, lvdisplayCmd, []string{
-			"LV Name\\s+thin-e2e-01",
-		}); err != nil {
-			t.Error(err.Error())
+		if lvm.LV(vgName, "thin-e2e-01") == nil {
+			t.Errorf("%s: LV thin-e2e-01 is deleted", nName)
 		}
 
 		out, _, err = cluster.ExecNode(nName, []string{"sudo", lvmD8, "lvremove", "-y", "/dev/" + vgName + "/thin-e2e-01"})
 		if err != nil {
-			util.Debugf("lvremove output: %s", out)
+			t.Debugf("lvremove output: %s", out)
 			t.Fatal(err.Error())
 		}
 		time.Sleep(3 * time.Second)
 
-		if err := cluster.ExecNodeRespNotContains(nName, vgdisplayCmd, []string{
-			"VG Name\\s+",
-		}); err != nil {
-			t.Error(err.Error())
-		}
-		if err := cluster.ExecNodeRespNotContains(nName, pvdisplayCmd, []string{
-			"VG Name\\s+",
-		}); err != nil {
-			t.Error(err.Error())
-		}
-		if err := cluster.ExecNodeRespNotContains(nName, lvdisplayCmd, []string{
-			"LV Name\\s+",
-		}); err != nil {
+		if err := checkNodeNoLvm(nName); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -422,23 +537,21 @@ func TestLvgThinDeleteCascadeK8s(t *testing.T) {
 // 4.1 - Increase BlockDevice size. Check LVG, PV, VG resizing. Check ThinPools no changes
 func TestLvgThinDiskResize(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
-	if util.HypervisorKubeConfig == "" {
-		t.Fatal("No HypervisorKubeConfig to resize VD")
-	}
+	cluster.Require(t, util.Requirements{Hypervisor: true, ThinProvisioning: true})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	hvCluster := util.EnsureCluster(util.HypervisorKubeConfig, "")
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
-		lvg, err := directLvgTpCreate(t.Node.Name, 2.34)
+		lvg, err := thinLvgFixture(t.Node.Name, 2.34).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{3}, []string{"660.00m"}, "660.00m"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{3}, []int64{660}, 660); err != nil {
 			t.Error(err.Error())
 		}
-		if err = thinPoolsCheck(lvg.Name, 1.0, 1.34); err != nil {
+		if err = thinPoolsCheck(cluster, lvg.Name, 1.0, 1.34); err != nil {
 			t.Error(err.Error())
 		}
 
@@ -453,12 +566,12 @@ func TestLvgThinDiskResize(t *testing.T) {
 			}
 		}
 
-		if err := util.RetrySec(10, func() error {
-			return checkNodeLvgSize(lvg.Name, []float32{4}, []string{"1.64g"}, "1.64g")
+		if err := util.WaitFor(t.Context(), 10*time.Second, func(context.Context) error {
+			return checkNodeLvgSize(cluster, lvg.Name, []float32{4}, []int64{1680}, 1680)
 		}); err != nil {
 			t.Error(err.Error())
 		}
-		if err = thinPoolsCheck(lvg.Name, 1.0, 1.34); err != nil {
+		if err = thinPoolsCheck(cluster, lvg.Name, 1.0, 1.34); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -467,19 +580,21 @@ func TestLvgThinDiskResize(t *testing.T) {
 // 4.2 - Increase ThinPool size. Check LVG, PV, VG, ThinPool resizing
 func TestLvgThinPoolResize(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{ThinProvisioning: true, BdCount: 1, BdSize: 3})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
-		lvg, err := directLvgTpCreate(t.Node.Name, 2.34)
+		cluster := cluster.WithContext(t.Context())
+		lvg, err := thinLvgFixture(t.Node.Name, 2.34).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{3}, []string{"660.00m"}, "660.00m"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{3}, []int64{660}, 660); err != nil {
 			t.Error(err.Error())
 		}
-		if err = thinPoolsCheck(lvg.Name, 1.0, 1.34); err != nil {
+		if err = thinPoolsCheck(cluster, lvg.Name, 1.0, 1.34); err != nil {
 			t.Error(err.Error())
 		}
 
@@ -491,10 +606,10 @@ func TestLvgThinPoolResize(t *testing.T) {
 			t.Fatalf("LVG updating: %s", err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{3}, []string{"444.00m"}, "444.00m"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{3}, []int64{444}, 444); err != nil {
 			t.Error(err.Error())
 		}
-		if err = thinPoolsCheck(lvg.Name, 1.21, 1.34); err != nil {
+		if err = thinPoolsCheck(cluster, lvg.Name, 1.21, 1.34); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -503,19 +618,21 @@ func TestLvgThinPoolResize(t *testing.T) {
 // 4.3 - Increase ThinPool size over VG. Check LVG, PV, VG, ThinPool no changes
 func TestLvgThinPoolOversize(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
+	cluster.Require(t, util.Requirements{ThinProvisioning: true, BdCount: 1, BdSize: 3})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
-		lvg, err := directLvgTpCreate(t.Node.Name, 2.34)
+		cluster := cluster.WithContext(t.Context())
+		lvg, err := thinLvgFixture(t.Node.Name, 2.34).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{3}, []string{"660.00m"}, "660.00m"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{3}, []int64{660}, 660); err != nil {
 			t.Error(err.Error())
 		}
-		if err = thinPoolsCheck(lvg.Name, 1.0, 1.34); err != nil {
+		if err = thinPoolsCheck(cluster, lvg.Name, 1.0, 1.34); err != nil {
 			t.Error(err.Error())
 		}
 
@@ -535,10 +652,10 @@ func TestLvgThinPoolOversize(t *testing.T) {
 		if lvg.Status.ConfigurationApplied != "False" {
 			t.Errorf("LVG ConfigurationApplied: %s", lvg.Status.ConfigurationApplied)
 		}
-		if err := checkNodeLvgSize(lvg.Name, []float32{3}, []string{"660.00m"}, "660.00m"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{3}, []int64{660}, 660); err != nil {
 			t.Error(err.Error())
 		}
-		if err = thinPoolsCheck(lvg.Name, 1.0, 1.34); err != nil {
+		if err = thinPoolsCheck(cluster, lvg.Name, 1.0, 1.34); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -547,28 +664,26 @@ func TestLvgThinPoolOversize(t *testing.T) {
 // 5 - Add second BlockDevice to LVG. Check LVG, PV, VG resizing. Check ThinPools no changes
 func TestLvgThinAddBd(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
-
-	if util.HypervisorKubeConfig == "" {
-		t.Fatal("No HypervisorKubeConfig to add VD")
-	}
+	cluster.Require(t, util.Requirements{Hypervisor: true, ThinProvisioning: true})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		nName := t.Node.Name
-		lvg, err := directLvgTpCreate(nName, 1.7)
+		lvg, err := thinLvgFixture(nName, 1.7).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{2}, []string{"296.00m"}, "296.00m"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{2}, []int64{296}, 296); err != nil {
 			t.Error(err.Error())
 		}
-		if err = thinPoolsCheck(lvg.Name, 1.7); err != nil {
+		if err = thinPoolsCheck(cluster, lvg.Name, 1.7); err != nil {
 			t.Error(err.Error())
 		}
 
-		bds, err := getOrCreateConsumableBlockDevices(nName, 1, 1)
+		bds, err := cluster.GetOrCreateConsumableBds(nName, 1, 1)
 		if err != nil {
 			t.Fatal(err.Error())
 		}
@@ -579,7 +694,7 @@ func TestLvgThinAddBd(t *testing.T) {
 			t.Fatalf("LVG updating: %s", err.Error())
 		}
 
-		if err := util.RetrySec(20, func() error {
+		if err := util.WaitFor(t.Context(), 20*time.Second, func(context.Context) error {
 			lvg, _ = cluster.GetLvg(lvg.Name)
 			if lvg.Status.VGSize.Value() != int64(3)*1024*1024*1024 {
 				return fmt.Errorf("VG %s size: %d != 3Gi", lvg.Name, lvg.Status.VGSize.Value())
@@ -589,10 +704,10 @@ func TestLvgThinAddBd(t *testing.T) {
 			t.Fatal(err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{2, 1}, []string{"296.00m", "1.00g"}, "<1.29g"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{2, 1}, []int64{296, 1024}, 1320); err != nil {
 			t.Error(err.Error())
 		}
-		if err = thinPoolsCheck(lvg.Name, 1.7); err != nil {
+		if err = thinPoolsCheck(cluster, lvg.Name, 1.7); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -601,21 +716,21 @@ func TestLvgThinAddBd(t *testing.T) {
 // 6 - Reconnect BlockDevice to another path. Check LVG no changes
 func TestLvgThinReconnectBd(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
-	if util.HypervisorKubeConfig == "" {
-		t.Fatal("No HypervisorKubeConfig to add VD")
-	}
+	cluster.Require(t, util.Requirements{Hypervisor: true, ThinProvisioning: true})
 	prepareClr()
 	t.Cleanup(cleanup05)
 
 	hvCluster := util.EnsureCluster(util.HypervisorKubeConfig, "")
+	util.RetryNodes(t, 2)
 	cluster.RunTestGroupNodes(t, nil, func(t *util.T) {
+		cluster := cluster.WithContext(t.Context())
 		nName := t.Node.Name
-		lvg, err := directLvgTpCreate(nName, 1.1)
+		lvg, err := thinLvgFixture(nName, 1.1).Acquire(t)
 		if err != nil {
 			t.Fatalf("LVG creating: %s", err.Error())
 		}
 
-		if err := checkNodeLvgSize(lvg.Name, []float32{2}, []string{"912.00m"}, "912.00m"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{2}, []int64{912}, 912); err != nil {
 			t.Error(err.Error())
 		}
 
@@ -626,10 +741,15 @@ func TestLvgThinReconnectBd(t *testing.T) {
 		if err != nil {
 			t.Fatal(err.Error())
 		}
+		t.Cleanup(func() {
+			for _, vmbd := range vmbds {
+				_ = hvCluster.AttachVmbd(nName, vmbd.Name)
+			}
+		})
 		bdName := lvg.Spec.BlockDeviceSelector.MatchExpressions[0].Values[0]
 		_ = cluster.DeleteBd(util.BdFilter{Name: bdName})
 
-		_, _ = getOrCreateConsumableBlockDevices(nName, 2, 1)
+		_, _ = cluster.GetOrCreateConsumableBds(nName, 2, 1)
 
 		for _, vmbd := range vmbds {
 			_ = hvCluster.AttachVmbd(nName, vmbd.Name)
@@ -648,7 +768,7 @@ func TestLvgThinReconnectBd(t *testing.T) {
 		if lvg.Status.Phase != "Ready" {
 			t.Fatalf("LVG %s not Ready: %s", lvg.Name, lvg.Status.Phase)
 		}
-		if err := checkNodeLvgSize(lvg.Name, []float32{2}, []string{"912.00m"}, "912.00m"); err != nil {
+		if err := checkNodeLvgSize(cluster, lvg.Name, []float32{2}, []int64{912}, 912); err != nil {
 			t.Error(err.Error())
 		}
 	})
@@ -662,9 +782,8 @@ func cleanup05() {
 	}
 }
 
-func checkNodeLvgSize(lvgName string, vSize []float32, vFree []string, vgFree string) error {
-	cluster := util.EnsureCluster("", "")
-
+// checkNodeLvgSize checks LVG status and node PVs, VG. vSize in GiB, free sizes in MiB
+func checkNodeLvgSize(cluster *util.KCluster, lvgName string, vSize []float32, vFreeMi []int64, vgFreeMi int64) error {
 	lvg, _ := cluster.GetLvg(lvgName)
 	if len(lvg.Status.Nodes[0].Devices) != len(vSize) {
 		return fmt.Errorf("LVG %s devices: %d != %d", lvgName, len(lvg.Status.Nodes[0].Devices), len(vSize))
@@ -672,9 +791,13 @@ func checkNodeLvgSize(lvgName string, vSize []float32, vFree []string, vgFree st
 
 	nName := lvg.Spec.Local.NodeName
 	vgName := lvg.Spec.ActualVGNameOnTheNode
-	nChecks := map[string][]string{
-		lsblkCmd: []string{},
-		pvsCmd:   []string{},
+	lvm, err := cluster.GetNodeLvm(nName)
+	if err != nil {
+		return err
+	}
+	vg := lvm.VG(vgName)
+	if vg == nil {
+		return fmt.Errorf("%s: no VG %s", nName, vgName)
 	}
 	vgSize := float32(0)
 	validDiff := int64(5) * 1024 * 1024
@@ -689,37 +812,43 @@ func checkNodeLvgSize(lvgName string, vSize []float32, vFree []string, vgFree st
 		}
 		vgSize += sizeG
 
-		nChecks[lsblkCmd] = append(nChecks[lsblkCmd],
-			fmt.Sprintf("%s [\\d\\s:]* %.0fG\\s+0\\s+disk\\s*\\n", path[len(path)-3:], sizeG))
-		nChecks[pvsCmd] = append(nChecks[pvsCmd],
-			fmt.Sprintf("%s\\s+%s [a-z\\d\\s-]+ %.2fg\\s+%s", path, lvg.Name, sizeG, vFree[devId]))
+		pv := lvm.PV(path)
+		if pv == nil || pv.VgName != vgName {
+			return fmt.Errorf("%s: no PV %s in VG %s", nName, path, vgName)
+		}
+		if !sizeNear(pv.DevSize, sizeB, validDiff) || !sizeNear(pv.Size, sizeB, validDiff) {
+			return fmt.Errorf("%s PV %s size != %.2fG: dev %d pv %d", nName, path, sizeG, pv.DevSize, pv.Size)
+		}
+		if !sizeNear(pv.Free, vFreeMi[devId]*mib, freeDiff) {
+			return fmt.Errorf("%s PV %s free: %d != %dMi", nName, path, pv.Free, vFreeMi[devId])
+		}
 	}
-	nChecks[vgsCmd] = []string{fmt.Sprintf("%s [a-z\\d\\s-]* %.2fg\\s+%s", vgName, vgSize, vgFree)}
-	nChecks[vgdisplayCmd+" "+vgName] = []string{fmt.Sprintf("VG Size\\s+%.2f GiB\\n", vgSize)}
 
 	vgSizeB := int64(vgSize) * 1024 * 1024 * 1024
 	if lvg.Status.VGSize.Value() < vgSizeB-validDiff || lvg.Status.VGSize.Value() > vgSizeB+validDiff {
 		return fmt.Errorf("%s VG size: %d != %.2fG", nName, lvg.Status.VGSize.Value(), vgSize)
 	}
-
-	for cmd, resp := range nChecks {
-		if err := cluster.ExecNodeRespContains(nName, cmd, resp); err != nil {
-			return err
-		}
+	if !sizeNear(vg.Size, vgSizeB, validDiff) || vg.PvCount != len(vSize) {
+		return fmt.Errorf("%s VG %s size: %d != %.2fG, PVs: %d", nName, vgName, vg.Size, vgSize, vg.PvCount)
+	}
+	if !sizeNear(vg.Free, vgFreeMi*mib, freeDiff) {
+		return fmt.Errorf("%s VG %s free: %d != %dMi", nName, vgName, vg.Free, vgFreeMi)
 	}
 
 	return nil
 }
 
-func thinPoolsCheck(lvgName string, sizes ...float32) error {
-	cluster := util.EnsureCluster("", "")
-
+func thinPoolsCheck(cluster *util.KCluster, lvgName string, sizes ...float32) error {
 	lvg, _ := cluster.GetLvg(lvgName)
 	tps := lvg.Status.ThinPools
 
 	if len(tps) != len(sizes) {
 		return fmt.Errorf("ThinPools size: %d != %d", len(tps), len(sizes))
 	}
+	lvm, err := cluster.GetNodeLvm(lvg.Spec.Local.NodeName)
+	if err != nil {
+		return err
+	}
 
 	for _, tp := range tps {
 		tpOk := false
@@ -737,67 +866,83 @@ func thinPoolsCheck(lvgName string, sizes ...float32) error {
 		if !tpOk {
 			return fmt.Errorf("ThinPool %s invalid ActualSize: %d", tp.Name, tp.ActualSize.Value())
 		}
+
+		pool := lvm.ThinPool(lvg.Spec.ActualVGNameOnTheNode, tp.Name)
+		if pool == nil {
+			return fmt.Errorf("%s: no thin pool %s", lvm.Node, tp.Name)
+		}
+		if !sizeNear(pool.Size, tp.ActualSize.Value(), freeDiff) || pool.Allocated != 0 {
+			return fmt.Errorf("%s thin pool %s: size %d, allocated %d", lvm.Node, tp.Name, pool.Size, pool.Allocated)
+		}
 	}
 
 	return nil
 }
 
-func directLvgCreate(nName string, size int64) (*snc.LVMVolumeGroup, error) {
-	cluster := util.EnsureCluster("", "")
-	bds, err := getOrCreateConsumableBlockDevices(nName, size, 1)
+// checkVgBds checks node has not consumable BlockDevices with VG for the paths
+func checkVgBds(cluster *util.KCluster, nName, vgName string, paths []string) error {
+	bds, err := cluster.ListBD(util.BdFilter{Node: nName})
 	if err != nil {
-		return nil, err
+		return err
 	}
+	for _, path := range paths {
+		i := slices.IndexFunc(bds, func(bd snc.BlockDevice) bool { return bd.Status.Path == path })
+		if i < 0 {
+			return fmt.Errorf("%s: no BlockDevice %s", nName, path)
+		}
+		if bd := bds[i]; bd.Status.ActualVGNameOnTheNode != vgName || bd.Status.Consumable {
+			return fmt.Errorf("%s BlockDevice %s (%s): VG '%s' != %s, consumable %t",
+				nName, bd.Name, path, bd.Status.ActualVGNameOnTheNode, vgName, bd.Status.Consumable)
+		}
+	}
+	return nil
+}
 
-	bd := bds[0]
-	lvgName := "e2e-lvg-" + bd.Name[len(bd.Name)-4:]
-	err = cluster.CreateLvgWithCheck(lvgName, nName, []string{bd.Name})
+// checkNodeNoLvm checks node has no PVs and VGs
+func checkNodeNoLvm(nName string) error {
+	lvm, err := util.EnsureCluster("", "").GetNodeLvm(nName)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return cluster.GetLvg(lvgName)
+	if len(lvm.PVs) != 0 || len(lvm.VGs) != 0 {
+		return fmt.Errorf("%s LVM is not empty: PVs %d, VGs %d, LVs %d", nName, len(lvm.PVs), len(lvm.VGs), len(lvm.LVs))
+	}
+	return nil
 }
 
-func directLvgTpCreate(nName string, size float32) (*snc.LVMVolumeGroup, error) {
-	cluster := util.EnsureCluster("", "")
-	bds, err := getOrCreateConsumableBlockDevices(nName, int64(size+0.9999), 1)
+func checkNodeVgAllocated(nName, vgName string, size int64) error {
+	lvm, err := util.EnsureCluster("", "").GetNodeLvm(nName)
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	bd := bds[0]
-	lvgName := "e2e-lvg-" + bd.Name[len(bd.Name)-4:]
-	if size >= 2 {
-		err = cluster.CreateLvgExt(lvgName, nName, map[string]any{
-			"bds": []string{bd.Name},
-			"thinpools": []snc.LVMVolumeGroupThinPoolSpec{{
-				Name:            "thin-e2e-01",
-				Size:            "1.0Gi",
-				AllocationLimit: "130%",
-			}, {
-				Name:            "thin-e2e-02",
-				Size:            fmt.Sprintf("%.2fGi", size-1),
-				AllocationLimit: "150%",
-			}},
-		})
-	} else {
-		err = cluster.CreateLvgExt(lvgName, nName, map[string]any{
-			"bds": []string{bd.Name},
-			"thinpools": []snc.LVMVolumeGroupThinPoolSpec{{
-				Name:            "thin-e2e-01",
-				Size:            fmt.Sprintf("%.1fGi", size),
-				AllocationLimit: "125%",
-			}},
-		})
+	vg := lvm.VG(vgName)
+	if vg == nil {
+		return fmt.Errorf("%s: no VG %s", nName, vgName)
 	}
-	if err != nil {
-		return nil, err
+	if vg.Allocated() != size {
+		return fmt.Errorf("%s VG %s allocated: %d != %d", nName, vgName, vg.Allocated(), size)
 	}
+	return nil
+}
 
-	if err := cluster.WaitLVGsReady(util.LvgFilter{Name: lvgName}); err != nil {
-		return nil, err
+// thinLvgFixture provides LVG with thin pools: 1Gi + rest for size >= 2Gi, one pool otherwise
+func thinLvgFixture(nName string, size float32) *util.Fixture[*snc.LVMVolumeGroup] {
+	thinPools := []snc.LVMVolumeGroupThinPoolSpec{{
+		Name:            "thin-e2e-01",
+		Size:            fmt.Sprintf("%.1fGi", size),
+		AllocationLimit: "125%",
+	}}
+	if size >= 2 {
+		thinPools = []snc.LVMVolumeGroupThinPoolSpec{{
+			Name:            "thin-e2e-01",
+			Size:            "1.0Gi",
+			AllocationLimit: "130%",
+		}, {
+			Name:            "thin-e2e-02",
+			Size:            fmt.Sprintf("%.2fGi", size-1),
+			AllocationLimit: "150%",
+		}}
 	}
 
-	return cluster.GetLvg(lvgName)
+	return util.ThinLvgFixture(nName, int64(size+0.9999), thinPools...)
 }
@@ -9,11 +9,13 @@ import (
 func TestFinalizer(t *testing.T) {
 	cluster := util.EnsureCluster("", "")
 	t.Cleanup(func() {
+		util.TeardownFixtures()
 		if util.TestNSCleanUp == "delete" {
 			util.Debugf("Dedeting namespace %s", util.TestNS)
 			if err := cluster.DeleteNs(util.NsFilter{Name: util.TestNS}); err != nil {
 				util.Errorf("Can't delete namespace %s", util.TestNS)
 			}
 		}
+		util.CloseSshPool()
 	})
 }
@@ -0,0 +1,172 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"strings"
+	"testing"
+
+	util "github.com/deckhouse/sds-e2e/util"
+	"github.com/deckhouse/sds-e2e/util/sim"
+	snc "github.com/deckhouse/sds-node-configurator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Node LVM checks of LVG tests against simulated node and LVG status reported by agent
+func TestSimLvgChecks(t *testing.T) {
+	const lvgName, vgName = "e2e-lvg-sim", "vg-e2e"
+	thinPools := []snc.LVMVolumeGroupThinPoolStatus{
+		{Name: "tp1", ActualSize: resource.MustParse("1Gi")},
+		{Name: "tp2", ActualSize: resource.MustParse("1376Mi")},
+	}
+
+	for _, c := range []struct {
+		name      string
+		disk      int64
+		cmds      []string // LVM commands on node
+		thinUsed  int64    // data written to thin LV thin1
+		devices   int      // LVG status devices
+		thinPools []snc.LVMVolumeGroupThinPoolStatus
+		check     func(cluster *util.KCluster) error
+		err       string // error substring, "" - passed
+	}{
+		{
+			name:    "thick",
+			disk:    gib,
+			cmds:    []string{"vgcreate vg-e2e /dev/sdb"},
+			devices: 1,
+			check: func(cluster *util.KCluster) error {
+				return checkNodeLvgSize(cluster, lvgName, []float32{1}, []int64{1024}, 1024)
+			},
+		},
+		{
+			name:    "thick VG space used on node",
+			disk:    gib,
+			cmds:    []string{"vgcreate vg-e2e /dev/sdb", "lvcreate -L 100m -n lv1 vg-e2e"},
+			devices: 1,
+			check: func(cluster *util.KCluster) error {
+				return checkNodeLvgSize(cluster, lvgName, []float32{1}, []int64{1024}, 1024)
+			},
+			err: "PV /dev/sdb free",
+		},
+		{
+			name:    "thick LVG devices",
+			disk:    gib,
+			cmds:    []string{"vgcreate vg-e2e /dev/sdb"},
+			devices: 2,
+			check: func(cluster *util.KCluster) error {
+				return checkNodeLvgSize(cluster, lvgName, []float32{1}, []int64{1024}, 1024)
+			},
+			err: "devices: 2 != 1",
+		},
+		{
+			name:    "no VG on node",
+			disk:    gib,
+			devices: 1,
+			check: func(cluster *util.KCluster) error {
+				return checkNodeLvgSize(cluster, lvgName, []float32{1}, []int64{1024}, 1024)
+			},
+			err: "no VG vg-e2e",
+		},
+		{
+			name:      "thin",
+			disk:      3 * gib,
+			cmds:      []string{"vgcreate vg-e2e /dev/sdb", "lvcreate -L 1G -T vg-e2e/tp1", "lvcreate -L 1.34G -T vg-e2e/tp2"},
+			devices:   1,
+			thinPools: thinPools,
+			check: func(cluster *util.KCluster) error {
+				if err := checkNodeLvgSize(cluster, lvgName, []float32{3}, []int64{660}, 660); err != nil {
+					return err
+				}
+				return thinPoolsCheck(cluster, lvgName, 1.0, 1.34)
+			},
+		},
+		{
+			name: "thin pool allocated on node",
+			disk: 3 * gib,
+			cmds: []string{"vgcreate vg-e2e /dev/sdb", "lvcreate -L 1G -T vg-e2e/tp1", "lvcreate -L 1.34G -T vg-e2e/tp2",
+				"lvcreate -V 1G -T vg-e2e/tp1 -n thin1"},
+			thinUsed:  256 * mib,
+			devices:   1,
+			thinPools: thinPools,
+			check: func(cluster *util.KCluster) error {
+				return thinPoolsCheck(cluster, lvgName, 1.0, 1.34)
+			},
+			err: "thin pool tp1: size 1073741824, allocated 268435456",
+		},
+		{
+			name:      "thin pool missing on node",
+			disk:      3 * gib,
+			cmds:      []string{"vgcreate vg-e2e /dev/sdb", "lvcreate -L 1G -T vg-e2e/tp1"},
+			devices:   1,
+			thinPools: thinPools,
+			check: func(cluster *util.KCluster) error {
+				return thinPoolsCheck(cluster, lvgName, 1.0, 1.34)
+			},
+			err: "no thin pool tp2",
+		},
+		{
+			name:      "thin pool size",
+			disk:      3 * gib,
+			cmds:      []string{"vgcreate vg-e2e /dev/sdb", "lvcreate -L 1G -T vg-e2e/tp1", "lvcreate -L 1.34G -T vg-e2e/tp2"},
+			devices:   1,
+			thinPools: thinPools,
+			check: func(cluster *util.KCluster) error {
+				return thinPoolsCheck(cluster, lvgName, 1.0, 1.5)
+			},
+			err: "ThinPool tp2 invalid ActualSize",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			node := sim.NewNode("worker-0")
+			node.AddDisk("sdb", c.disk)
+			for _, cmd := range c.cmds {
+				if _, stderr, code := node.Exec("sudo "+lvmD8+" "+cmd, ""); code != 0 {
+					t.Fatalf("%s: %s", cmd, stderr)
+				}
+			}
+			if c.thinUsed > 0 {
+				if err := node.SetLvUsage(vgName, "thin1", c.thinUsed); err != nil {
+					t.Fatal(err.Error())
+				}
+			}
+
+			lvg := &snc.LVMVolumeGroup{}
+			lvg.Name = lvgName
+			lvg.Spec = snc.LVMVolumeGroupSpec{ActualVGNameOnTheNode: vgName, Local: snc.LVMVolumeGroupLocalSpec{NodeName: node.Name}}
+			lvgNode := snc.LVMVolumeGroupNode{Name: node.Name}
+			for range c.devices {
+				lvgNode.Devices = append(lvgNode.Devices, snc.LVMVolumeGroupDevice{Path: "/dev/sdb",
+					DevSize: *resource.NewQuantity(c.disk, resource.BinarySI), PVSize: *resource.NewQuantity(c.disk, resource.BinarySI)})
+			}
+			lvg.Status = snc.LVMVolumeGroupStatus{
+				Phase:     "Ready",
+				Nodes:     []snc.LVMVolumeGroupNode{lvgNode},
+				VGSize:    *resource.NewQuantity(c.disk, resource.BinarySI),
+				ThinPools: c.thinPools,
+			}
+
+			err := c.check(sim.NewCluster("sim", []*sim.Node{node}, lvg))
+			switch {
+			case c.err == "" && err != nil:
+				t.Errorf("unexpected error: %s", err.Error())
+			case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+				t.Errorf("error %v, expected %q", err, c.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+const (
+	TransportPodExec  = "pod-exec"
+	TransportSsh      = "ssh"
+	TransportDebugPod = "debug-pod"
+
+	auditOutputLen = 64 * 1024
+)
+
+// CommandRecord is node command with its result in the run transcript
+type CommandRecord struct {
+	Node      string    `json:"node,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	Transport string    `json:"transport"`
+	Command   string    `json:"command"`
+	Args      []string  `json:"args,omitempty"`
+	Root      bool      `json:"root,omitempty"` // run as root by transport (pod exec), not as SSH user
+	Start     time.Time `json:"start"`
+	Duration  float64   `json:"duration"`
+	ExitCode  int       `json:"exitCode"`
+	Stdout    string    `json:"stdout,omitempty"`
+	Stderr    string    `json:"stderr,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Redacted  bool      `json:"redacted,omitempty"` // output is secret and not saved
+	cluster   string
+}
+
+type Transcript struct {
+	RunID     string           `json:"runId"`
+	Namespace string           `json:"namespace"`
+	Start     time.Time        `json:"start"`
+	Commands  []*CommandRecord `json:"commands"`
+}
+
+var (
+	transcript   []*CommandRecord
+	transcriptMx sync.Mutex
+)
+
+// exitCode returns remote command exit code, -1 if command was not run or killed by signal
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var sshErr *ssh.ExitError
+	if errors.As(err, &sshErr) {
+		return sshErr.ExitStatus()
+	}
+	var execErr utilexec.ExitError
+	if errors.As(err, &execErr) {
+		return execErr.ExitStatus()
+	}
+	return -1
+}
+
+func truncateOutput(out string) string {
+	if len(out) > auditOutputLen {
+		return out[:auditOutputLen] + "\n... truncated"
+	}
+	return out
+}
+
+// auditCommand adds finished node command to run transcript (-audit) and timeline
+func auditCommand(rec *CommandRecord, err error) {
+	rec.Duration = time.Since(rec.Start).Seconds()
+	rec.ExitCode = exitCode(err)
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	rec.Stdout, rec.Stderr = truncateOutput(rec.Stdout), truncateOutput(rec.Stderr)
+	if rec.Redacted {
+		rec.Stdout, rec.Stderr = "", ""
+	}
+
+	node := rec.Node
+	if node == "" {
+		node = rec.Host
+	}
+	RecordTimeline(TimelineRecord{
+		Time:     rec.Start,
+		Kind:     TimelineCommand,
+		Cluster:  rec.cluster,
+		Node:     node,
+		Action:   fmt.Sprintf("%s exit %d", rec.Transport, rec.ExitCode),
+		Object:   rec.Command,
+		Message:  rec.Stdout + rec.Stderr,
+		Duration: rec.Duration,
+		Error:    rec.Error,
+	})
+
+	if *auditFlag == "" {
+		return
+	}
+	transcriptMx.Lock()
+	defer transcriptMx.Unlock()
+	transcript = append(transcript, rec)
+}
+
+// shellQuote quotes string for POSIX shell
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@%+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// replayCommand returns shell command line of the record, commands run as root are run with sudo
+func (rec *CommandRecord) replayCommand() string {
+	cmd := rec.Command
+	if len(rec.Args) > 0 {
+		args := make([]string, len(rec.Args))
+		for i, arg := range rec.Args {
+			args[i] = shellQuote(arg)
+		}
+		cmd = strings.Join(args, " ")
+	}
+	if rec.Root {
+		return "sudo -n sh -c " + shellQuote(cmd)
+	}
+	return cmd
+}
+
+const replayHeader = `#!/usr/bin/env bash
+# Replay of node commands of run %s (namespace %s)
+# Usage: %s user@node-host [node]
+#   commands are run over SSH on user@node-host, only commands of [node] if set.
+#   Commands run as root on the node (pod exec) are run with sudo
+set -u
+TARGET=${1:?usage: $0 user@node-host [node]}
+NODE=${2:-}
+
+run() {
+  local node=$1 cmd=$2
+  if [ -n "$NODE" ] && [ "$node" != "$NODE" ]; then
+    return 0
+  fi
+  echo "+ [$node] $cmd" >&2
+  ssh "$TARGET" "$cmd"
+}
+
+`
+
+// WriteReplayScript writes transcript as shell script replaying commands on a node
+func (tr *Transcript) WriteReplayScript(path string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, replayHeader, tr.RunID, tr.Namespace, filepath.Base(path))
+	for _, rec := range tr.Commands {
+		node := rec.Node
+		if node == "" {
+			node = rec.Host
+		}
+		fmt.Fprintf(&sb, "# %s %s %s exit %d %.2fs\n", rec.Start.Format("15:04:05.000"), node, rec.Transport, rec.ExitCode, rec.Duration)
+		fmt.Fprintf(&sb, "run %s %s\n\n", shellQuote(node), shellQuote(rec.replayCommand()))
+	}
+	return os.WriteFile(path, []byte(sb.String()), 0755)
+}
+
+// GetTranscript returns node commands of the run
+func GetTranscript() *Transcript {
+	transcriptMx.Lock()
+	defer transcriptMx.Unlock()
+	return &Transcript{RunID: RunID, Namespace: TestNS, Start: startTime, Commands: append([]*CommandRecord{}, transcript...)}
+}
+
+// FlushTranscript writes run transcript to -audit JSON file and replay script next to it (.sh)
+func FlushTranscript() {
+	if *auditFlag == "" {
+		return
+	}
+
+	tr := GetTranscript()
+	data, err := json.MarshalIndent(tr, "", "  ")
+	if err == nil {
+		err = os.WriteFile(*auditFlag, data, 0644)
+	}
+	if err != nil {
+		Errorf("Can't write command transcript: %s", err.Error())
+	}
+
+	scriptPath := strings.TrimSuffix(*auditFlag, filepath.Ext(*auditFlag)) + ".sh"
+	if err := tr.WriteReplayScript(scriptPath); err != nil {
+		Errorf("Can't write replay script: %s", err.Error())
+	}
+}
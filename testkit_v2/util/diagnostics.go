@@ -0,0 +1,121 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const diagnosticsLogLines = 1000
+
+var diagnosticsNamespaces = []string{SDSNodeConfiguratorModuleNamespace, SDSLocalVolumeModuleNamespace}
+
+// NodeLogPaths are node log files collected by SaveNodeLogs (missing ones are skipped)
+var NodeLogPaths = []string{"/var/log/syslog", "/var/log/messages", "/var/log/kern.log", "/var/log/cloud-init-output.log"}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// SaveDiagnostics saves cluster state to dir: nodes, BlockDevices, LVGs, module pods with logs
+func (cluster *KCluster) SaveDiagnostics(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var errs []error
+	save := func(name string, v any, err error) {
+		if err == nil {
+			err = writeJSONFile(filepath.Join(dir, name), v)
+		}
+		errs = append(errs, err)
+	}
+
+	nodes, err := cluster.ListNode()
+	save("nodes.json", nodes, err)
+	bds, err := cluster.ListBD()
+	save("blockdevices.json", bds, err)
+	lvgs, err := cluster.ListLVG()
+	save("lvmvolumegroups.json", lvgs, err)
+
+	for _, ns := range diagnosticsNamespaces {
+		pods, err := cluster.ListPod(ns)
+		save(ns+"-pods.json", pods, err)
+		for _, pod := range pods {
+			logs, err := cluster.GetPodLogs(ns, pod.Name, diagnosticsLogLines)
+			if err == nil {
+				err = os.WriteFile(filepath.Join(dir, pod.Name+".log"), []byte(logs), 0644)
+			}
+			errs = append(errs, err)
+		}
+	}
+
+	for _, node := range nodes {
+		nDir := filepath.Join(dir, "nodes", node.Name)
+		if NestedSshClient.conn != nil {
+			errs = append(errs, cluster.SaveNodeLogs(node.Name, nDir))
+			continue
+		}
+		// no SSH: journal only, through any available node executor
+		out, _, err := cluster.ExecNode(node.Name, []string{"journalctl", "-b", "--no-pager", "-n", "20000"})
+		if err == nil {
+			if err = os.MkdirAll(nDir, 0755); err == nil {
+				err = os.WriteFile(filepath.Join(nDir, "journal.log"), []byte(out), 0644)
+			}
+		}
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// SaveNodeLogs downloads node log files (NodeLogPaths by default) and journal of current boot to dir over SSH
+func (cluster *KCluster) SaveNodeLogs(nName, dir string, paths ...string) error {
+	if len(paths) == 0 {
+		paths = NodeLogPaths
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	client, err := cluster.nodeSshClient(nName)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	members := make([]string, len(paths))
+	for i, p := range paths {
+		members[i] = strings.TrimPrefix(p, "/")
+	}
+	ctx := cluster.ctx
+	err = client.downloadTar(ctx, "/", members, dir, SyncOpts{Sudo: true, SkipVerify: true})
+
+	res, jErr := client.Run(ctx, "journalctl -b --no-pager -n 20000", ExecOpts{Sudo: true, Timeout: time.Minute})
+	if jErr == nil {
+		jErr = os.WriteFile(filepath.Join(dir, "journal.log"), []byte(res.Stdout), 0644)
+	}
+	return errors.Join(err, jErr)
+}
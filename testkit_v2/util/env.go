@@ -19,9 +19,9 @@ package integration
 import (
 	"flag"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -31,8 +31,6 @@ const (
 	KubePath      = "../../../sds-e2e-cfg"
 	RemoteAppPath = "/home/user"
 
-	PrivKeyName   = "id_rsa_test"
-	PubKeyName    = "id_rsa_test.pub"
 	ConfigName    = "config.yml"
 	ResourcesName = "resources.yml"
 
@@ -51,8 +49,19 @@ var (
 	registryDockerCfg = "e30="
 	Parallel          = false
 	TreeMode          = false
+	NodeParallel      = 0
+	GroupParallel     = map[string]int{}
+	NodeTimeout       = time.Duration(0)
+	NodeTimeoutGrace  = 5 * time.Minute // node slot stays busy while timed out test still runs
 	KeepState         = false
-	fileLogger        *log.Logger
+	Disruptive        = false // run tests breaking nodes (agent pods deletion, CSI controller restart, node reboot, static node rejoin)
+	BdAudit           = false // run standalone BlockDevice audit of all agent nodes
+	NonInteractive    = false // fail instead of asking ssh passwords and passphrases
+	SshAgent          = true  // use ssh-agent keys (SSH_AUTH_SOCK)
+	SshAgentForward   = false // forward ssh-agent to remote hosts
+
+	NestedKeyType           = KeyTypeRSA // key type of nested VMs (clusterType.SshKeyType)
+	PrivKeyName, PubKeyName = SshKeyNames(NestedKeyType)
 
 	ConfigTplName    = "config.yml.tpl"
 	ResourcesTplName = "resources.yml.tpl"
@@ -62,15 +71,19 @@ var (
 	HvSshUser            = ""
 	HvSshKey             = ""
 	HvK8sPort            = "6445"
+	HvLocalPort          = "" // local end of hypervisor API tunnel (default: HvK8sPort)
 	HvSshClient          sshClient
+	HvTunnel             *Tunnel
 	HvStorageClass       = "linstor-r1"
 
 	NestedHost                = "127.0.0.1"
 	NestedSshUser             = "user"
 	NestedSshKey              = ""
 	NestedK8sPort             = "6445"
+	NestedLocalPort           = "" // local end of test cluster API tunnel (default: NestedK8sPort)
 	NestedClusterKubeConfig   = "kube-nested.config"
 	NestedSshClient           sshClient
+	NestedTunnel              *Tunnel
 	NestedDefaultStorageClass = "linstor-r1"
 
 	verboseFlag            = flag.Bool("verbose", false, "Output with Info messages")
@@ -87,12 +100,37 @@ var (
 	nsCleanupFlag          = flag.String("namespacecleanup", "", "Test name space (delete after use)")
 	sshhostFlag            = flag.String("sshhost", "127.0.0.1", "Test ssh host")
 	sshkeyFlag             = flag.String("sshkey", os.Getenv("HOME")+"/.ssh/id_rsa", "Test ssh key")
+	sshAgentFlag           = flag.Bool("sshagent", true, "Use ssh-agent keys (SSH_AUTH_SOCK) for SSH authentication")
+	sshAgentForwardFlag    = flag.Bool("sshagentforward", false, "Forward ssh-agent to hypervisor, jump hosts and nodes")
+	nonInteractiveFlag     = flag.Bool("noninteractive", os.Getenv("CI") != "", "Fail instead of asking SSH passwords and passphrases (default: true if CI is set)")
+	hostKeysFlag           = flag.String("hostkeys", HostKeyTofu, "SSH host key verification: strict (known_hosts), tofu (trust on first use), insecure")
+	knownHostsFlag         = flag.String("knownhosts", KnownHosts, "SSH known_hosts file")
+	nodeExecFlag           = flag.String("nodeexec", strings.Join(NodeExecOrder, ","), "Node command backends in preference order: pod (sds-node-configurator nsenter), ssh, debug (privileged debug pod, opt-in)")
+	debugImageFlag         = flag.String("debugimage", DebugPodImage, "Image of node debug pods (nsenter required)")
+	hvPortFlag             = flag.String("hvport", "", "Local port of hypervisor API tunnel (default: 6445, 0 - ephemeral)")
+	nestedPortFlag         = flag.String("nestedport", "", "Local port of test cluster API tunnel (default: cluster API port, 0 - ephemeral)")
 	configTplFlag          = flag.String("nestedclusterconfigtemplate", ConfigTplName, "Test cluster config.yml template")
 	resourcesTplFlag       = flag.String("nestedclusterresourcestemplate", ResourcesTplName, "Test cluster resources.yml template")
 	skipOptionalFlag       = flag.Bool("skipoptional", false, "Skip optional tests (no required resources)")
 	notParallelFlag        = flag.Bool("notparallel", false, "Run test groups in single mode")
+	nodeParallelFlag       = flag.Int("nodeparallel", 0, "Max count of node tests running at the same time (0 - unlimited)")
+	groupParallelFlag      = flag.String("groupparallel", "", "Max count of node tests running at the same time per group (Ubu22=1,Deb11=2)")
+	nodeTimeoutFlag        = flag.Duration("nodetimeout", 0, "Timeout for each node test (0 - no timeout)")
+	retryFlag              = flag.String("retry", "", "Retries of failed node tests per test or group (TestLvg=2,TestPVC/Ubu22=1)")
+	quarantineFlag         = flag.String("quarantine", filepath.Join(DataPath, "quarantine.txt"), "File with known-flaky tests, their failures don't fail the run")
+	soakFlag               = flag.Duration("soak", 0, "Repeat selected tests for the duration (soak mode)")
+	soakIterationsFlag     = flag.Int("soakiterations", 0, "Repeat selected tests the number of times (soak mode)")
+	soakMaxFailuresFlag    = flag.Int("soakmaxfailures", 1, "Stop soak after the number of failed iterations")
+	soakGroupsFlag         = flag.String("soakgroups", "", "Node groups to repeat in soak mode (Ubu22,Deb11)")
+	soakDirFlag            = flag.String("soakdir", "soak", "Directory for soak statistics and diagnostics of failed iterations")
 	keepStateFlag          = flag.Bool("keepstate", false, "Don`t clean up after test finished")
+	disruptiveFlag         = flag.Bool("disruptive", false, "Run disruptive tests (agent pods deletion, CSI controller restart, node reboot, static node rejoin)")
+	bdAuditFlag            = flag.Bool("bdaudit", false, "Run BlockDevice audit of stand (TestBlockDeviceConsistency)")
 	logFileFlag            = flag.String("logfile", "", "Write extended logs to file")
+	jsonReportFlag         = flag.String("jsonreport", "", "Write JSON test report to file")
+	auditFlag              = flag.String("audit", "", "Write transcript of node commands to JSON file (and replay script next to it)")
+	timelineFlag           = flag.String("timeline", "", "Write timeline of test steps, API changes, node commands and events to JSON file (and HTML next to it)")
+	junitReportFlag        = flag.String("junitreport", "", "Write JUnit XML test report to file")
 
 	NodeRequired    = map[string]NodeFilter{}
 	VmCluster       = []VmConfig{}
@@ -102,6 +140,15 @@ var (
 type clusterType struct {
 	NodeRequired map[string]NodeFilter
 	VmCluster    []VmConfig
+	SshKeyType   string // key type of VMs: rsa (default), ed25519, ecdsa
+}
+
+// ClusterSshKeyType returns key type of VMs of the cluster type (-clustertype)
+func ClusterSshKeyType(name string) string {
+	if ct := clusterTypeMap[name]; ct.SshKeyType != "" {
+		return ct.SshKeyType
+	}
+	return KeyTypeRSA
 }
 
 var clusterTypeMap = map[string]clusterType{
@@ -309,6 +356,69 @@ func envInit() {
 	if !*notParallelFlag {
 		TreeMode, Parallel = true, true
 	}
+	NodeParallel = *nodeParallelFlag
+	for _, item := range strings.Split(*groupParallelFlag, ",") {
+		if item == "" {
+			continue
+		}
+		label, limit, ok := strings.Cut(item, "=")
+		n, err := strconv.Atoi(limit)
+		if !ok || err != nil {
+			Fatalf("invalid group parallel limit: %s", item)
+		}
+		GroupParallel[strings.TrimSpace(label)] = n
+	}
+	NodeTimeout = *nodeTimeoutFlag
+	initNodeSlots()
+
+	for _, item := range strings.Split(*retryFlag, ",") {
+		if item == "" {
+			continue
+		}
+		name, retries, ok := strings.Cut(item, "=")
+		n, err := strconv.Atoi(retries)
+		if !ok || err != nil {
+			Fatalf("invalid node test retries: %s", item)
+		}
+		NodeRetries[strings.TrimSpace(name)] = n
+	}
+	if *quarantineFlag != "" {
+		if err := loadQuarantine(*quarantineFlag); err != nil {
+			Fatalf("can't read quarantine list: %s", err.Error())
+		}
+	}
+
+	SshAgent, SshAgentForward, NonInteractive = *sshAgentFlag, *sshAgentForwardFlag, *nonInteractiveFlag
+
+	ct, ok := clusterTypeMap[*clusterTypeFlag]
+	if !ok {
+		Fatalf("invalid cluster type: %s", *clusterTypeFlag)
+	}
+	NodeRequired = ct.NodeRequired
+	VmCluster = ct.VmCluster
+	if ct.SshKeyType != "" {
+		NestedKeyType = ct.SshKeyType
+	}
+	PrivKeyName, PubKeyName = SshKeyNames(NestedKeyType)
+
+	switch *hostKeysFlag {
+	case HostKeyStrict, HostKeyTofu, HostKeyInsecure:
+		HostKeyMode = *hostKeysFlag
+	default:
+		Fatalf("invalid host key mode: %s", *hostKeysFlag)
+	}
+	KnownHosts = *knownHostsFlag
+
+	NodeExecOrder = nil
+	for _, name := range strings.Split(*nodeExecFlag, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case NodeExecPod, NodeExecSsh, NodeExecDebug:
+			NodeExecOrder = append(NodeExecOrder, name)
+		default:
+			Fatalf("invalid node executor: %s", name)
+		}
+	}
+	DebugPodImage = *debugImageFlag
 
 	sshList := strings.Split(*sshhostFlag, "@")
 	if *hypervisorkconfigFlag != "" {
@@ -336,6 +446,14 @@ func envInit() {
 		}
 		NestedSshKey = *sshkeyFlag
 	}
+	HvLocalPort, NestedLocalPort = HvK8sPort, NestedK8sPort
+	if *hvPortFlag != "" {
+		HvLocalPort = *hvPortFlag
+	}
+	if *nestedPortFlag != "" {
+		NestedLocalPort = *nestedPortFlag
+	}
+
 	if strings.HasPrefix(*kconfigFlag, "/") {
 		NestedClusterKubeConfig = *kconfigFlag
 	} else {
@@ -345,22 +463,17 @@ func envInit() {
 	HvStorageClass = *hvStorageClassFlag
 	NestedDefaultStorageClass = *nestedStorageClassFlag
 	KeepState = *keepStateFlag
+	Disruptive = *disruptiveFlag
+	BdAudit = *bdAuditFlag
 
 	if *logFileFlag != "" {
 		f, err := os.OpenFile(*logFileFlag, os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC, 0644)
 		if err != nil {
-			log.Fatalf("error opening file: %v", err)
+			Fatalf("error opening file: %v", err)
 		}
-		fileLogger = log.New(f, "", log.LstdFlags)
+		setLogFile(f)
 	}
 
 	ConfigTplName = *configTplFlag
 	ResourcesTplName = *resourcesTplFlag
-
-	ct, ok := clusterTypeMap[*clusterTypeFlag]
-	if !ok {
-		log.Fatalf("invalid cluster type: %s", *clusterTypeFlag)
-	}
-	NodeRequired = ct.NodeRequired
-	VmCluster = ct.VmCluster
 }
@@ -0,0 +1,465 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	snc "github.com/deckhouse/sds-node-configurator/api/v1alpha1"
+	virt "github.com/deckhouse/virtualization/api/core/v1alpha2"
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlrtclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+/*  Fault targets  */
+
+// ModulePods selects module pods for fault injection
+type ModulePods struct {
+	Namespace string
+	Name      any
+}
+
+var (
+	AgentPods                 = ModulePods{SDSNodeConfiguratorModuleNamespace, "%sds-node-configurator-%"}
+	CsiNodePods               = ModulePods{SDSLocalVolumeModuleNamespace, "%csi-node-%"}
+	CsiControllerPods         = ModulePods{SDSLocalVolumeModuleNamespace, "%csi-controller-%"}
+	LocalVolumeControllerPods = ModulePods{SDSLocalVolumeModuleNamespace, "%sds-local-volume-controller-%"}
+)
+
+const AgentProcess = "sds-node-configurator-agent"
+
+// DeleteModulePods deletes module pods on node (all nodes if nName is empty). Returns deleted pod names
+func (cluster *KCluster) DeleteModulePods(pods ModulePods, nName string) ([]string, error) {
+	filter := PodFilter{Name: pods.Name}
+	if nName != "" {
+		filter.Node = nName
+	}
+	list, err := cluster.ListPod(pods.Namespace, filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no pods %v in %s on node '%s'", pods.Name, pods.Namespace, nName)
+	}
+
+	names := []string{}
+	for _, pod := range list {
+		if err := cluster.DeletePod(pod.Namespace, pod.Name); err != nil {
+			return names, err
+		}
+		names = append(names, pod.Name)
+	}
+	cluster.Debugf("Pods deleted: %s", strings.Join(names, ", "))
+	return names, nil
+}
+
+// WaitModulePodsReady waits for module pods on node (all nodes if nName is empty) are running and ready, except old pods
+func (cluster *KCluster) WaitModulePodsReady(pods ModulePods, nName string, old []string, timeoutSec int) error {
+	filter := PodFilter{Name: pods.Name}
+	if nName != "" {
+		filter.Node = nName
+	}
+	return WaitFor(cluster.ctx, time.Duration(timeoutSec)*time.Second, func(context.Context) error {
+		list, err := cluster.ListPod(pods.Namespace, filter)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return fmt.Errorf("no pods %v in %s on node '%s'", pods.Name, pods.Namespace, nName)
+		}
+		for _, pod := range list {
+			if slices.Contains(old, pod.Name) {
+				return fmt.Errorf("pod %s not deleted", pod.Name)
+			}
+			if !podReady(&pod) {
+				return fmt.Errorf("pod %s not ready: %s", pod.Name, pod.Status.Phase)
+			}
+		}
+		return nil
+	})
+}
+
+func podReady(pod *coreapi.Pod) bool {
+	if pod.Status.Phase != coreapi.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == coreapi.PodReady {
+			return c.Status == coreapi.ConditionTrue
+		}
+	}
+	return false
+}
+
+// RestartModulePods deletes module pods on node and waits for new pods are ready
+func (cluster *KCluster) RestartModulePods(pods ModulePods, nName string, timeoutSec int) error {
+	old, err := cluster.DeleteModulePods(pods, nName)
+	if err != nil {
+		return err
+	}
+	return cluster.WaitModulePodsReady(pods, nName, old, timeoutSec)
+}
+
+// signalScript signals processes matching pattern ($2) except the script and its ancestors:
+// command lines of exec wrappers (nsenter, sudo, sh) contain the pattern too
+const signalScript = `ppid() { sed -n 's/^PPid:[[:space:]]*//p' /proc/$1/status 2>/dev/null; }
+self=$$; skip=" "; p=$self
+while [ "${p:-0}" -gt 1 ]; do skip="$skip$p "; p=$(ppid $p); done
+found=""; rc=0
+for p in $(pgrep -f -- "$2"); do
+  case "$skip" in *" $p "*) continue ;; esac
+  [ "$(ppid $p)" = "$self" ] && continue
+  found=1; kill -"$1" "$p" || rc=1
+done
+[ -n "$found" ] || exit 1
+exit $rc`
+
+// SignalProcess sends signal (STOP, CONT, KILL, ...) to node processes matching pattern (pgrep -f)
+func (cluster *KCluster) SignalProcess(nName, pattern, signal string) error {
+	out, errOut, err := cluster.ExecNode(nName, []string{"sh", "-c", signalScript, "sh", signal, pattern})
+	if err != nil {
+		return fmt.Errorf("signal %s to %s on %s: %s %s", signal, pattern, nName, out, errOut)
+	}
+	return nil
+}
+
+func (cluster *KCluster) nodeBootId(nName string) (string, error) {
+	node, err := cluster.GetNode(nName)
+	if err != nil {
+		return "", err
+	}
+	if node.Name == "" {
+		return "", fmt.Errorf("no node %s", nName)
+	}
+	return node.Status.NodeInfo.BootID, nil
+}
+
+// RebootNode reboots node: restarts hypervisor VM of nested cluster node or runs reboot over SSH
+func (cluster *KCluster) RebootNode(nName string) error {
+	if HypervisorKubeConfig != "" {
+		hvCluster := EnsureCluster(HypervisorKubeConfig, "")
+		vmop := &virt.VirtualMachineOperation{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: nName + "-restart-", Namespace: TestNS},
+			Spec:       virt.VirtualMachineOperationSpec{Type: virt.VMOPTypeRestart, VirtualMachine: nName, Force: true},
+		}
+		return hvCluster.controllerRuntimeClient.Create(hvCluster.ctx, vmop)
+	}
+
+	_, err := cluster.ExecNodeSsh(nName, "sudo systemd-run --on-active=2 systemctl reboot")
+	return err
+}
+
+// WaitNodeRebooted waits for node with new boot ID is Ready
+func (cluster *KCluster) WaitNodeRebooted(nName, bootId string, timeoutSec int) error {
+	return WaitFor(cluster.ctx, time.Duration(timeoutSec)*time.Second, func(context.Context) error {
+		node, err := cluster.GetNode(nName)
+		if err != nil {
+			return err
+		}
+		if node.Status.NodeInfo.BootID == bootId {
+			return fmt.Errorf("node %s not rebooted", nName)
+		}
+		for _, c := range node.Status.Conditions {
+			if c.Type == coreapi.NodeReady && c.Status == coreapi.ConditionTrue {
+				return nil
+			}
+		}
+		return fmt.Errorf("node %s not Ready", nName)
+	})
+}
+
+/*  Faults  */
+
+const faultRecoverTimeout = 600
+
+// Fault is injected by Inject, Recover (optional) runs right after injection and waits for fault end
+type Fault struct {
+	Name    string
+	Inject  func() error
+	Recover func() error
+}
+
+// DeletePodsFault deletes module pods on node (all nodes if nName is empty) and waits for new pods
+func (cluster *KCluster) DeletePodsFault(pods ModulePods, nName string) Fault {
+	var old []string
+	return Fault{
+		Name: fmt.Sprintf("delete pods %v on '%s'", pods.Name, nName),
+		Inject: func() (err error) {
+			old, err = cluster.DeleteModulePods(pods, nName)
+			return err
+		},
+		Recover: func() error {
+			return cluster.WaitModulePodsReady(pods, nName, old, faultRecoverTimeout)
+		},
+	}
+}
+
+// RebootNodeFault reboots node and waits for it is Ready
+func (cluster *KCluster) RebootNodeFault(nName string) Fault {
+	var bootId string
+	return Fault{
+		Name: "reboot " + nName,
+		Inject: func() (err error) {
+			if bootId, err = cluster.nodeBootId(nName); err != nil {
+				return err
+			}
+			return cluster.RebootNode(nName)
+		},
+		Recover: func() error {
+			return cluster.WaitNodeRebooted(nName, bootId, faultRecoverTimeout)
+		},
+	}
+}
+
+// PauseProcessFault stops node processes matching pattern for the duration
+func (cluster *KCluster) PauseProcessFault(nName, pattern string, d time.Duration) Fault {
+	return Fault{
+		Name:   fmt.Sprintf("pause %s on %s for %s", pattern, nName, d),
+		Inject: func() error { return cluster.SignalProcess(nName, pattern, "STOP") },
+		Recover: func() error {
+			time.Sleep(d)
+			return cluster.SignalProcess(nName, pattern, "CONT")
+		},
+	}
+}
+
+// KillProcessFault kills node processes matching pattern
+func (cluster *KCluster) KillProcessFault(nName, pattern string) Fault {
+	return Fault{
+		Name:   fmt.Sprintf("kill %s on %s", pattern, nName),
+		Inject: func() error { return cluster.SignalProcess(nName, pattern, "KILL") },
+	}
+}
+
+/*  Triggers  */
+
+// FaultTrigger reports whether it is time to inject fault
+type FaultTrigger func() (bool, error)
+
+// Immediately fires trigger at once
+func Immediately() FaultTrigger {
+	return func() (bool, error) { return true, nil }
+}
+
+// LvgPhaseTrigger fires when LVG phase matches (Pending, !Ready)
+func (cluster *KCluster) LvgPhaseTrigger(name string, phase any) FaultTrigger {
+	return func() (bool, error) {
+		lvgs, err := cluster.ListLVG(LvgFilter{Name: name, Phase: phase})
+		if err != nil {
+			return false, err
+		}
+		return len(lvgs) > 0, nil
+	}
+}
+
+// PvcResizingTrigger fires when PVC in test namespace is resizing
+func (cluster *KCluster) PvcResizingTrigger(name string) FaultTrigger {
+	return func() (bool, error) {
+		pvc := coreapi.PersistentVolumeClaim{}
+		if err := cluster.controllerRuntimeClient.Get(cluster.ctx, ctrlrtclient.ObjectKey{Namespace: TestNS, Name: name}, &pvc); err != nil {
+			return false, err
+		}
+		return pvcResizing(&pvc), nil
+	}
+}
+
+func pvcResizing(pvc *coreapi.PersistentVolumeClaim) bool {
+	for _, c := range pvc.Status.Conditions {
+		if c.Type == coreapi.PersistentVolumeClaimResizing || c.Type == coreapi.PersistentVolumeClaimFileSystemResizePending {
+			return true
+		}
+	}
+	req, capacity := pvc.Spec.Resources.Requests[coreapi.ResourceStorage], pvc.Status.Capacity[coreapi.ResourceStorage]
+	return capacity.Cmp(req) < 0
+}
+
+// FaultRun is fault injection waiting for trigger in background
+type FaultRun struct {
+	fault Fault
+	fired bool
+	err   error
+	done  chan struct{}
+}
+
+// InjectWhen injects fault as soon as trigger fires (checked every second during timeout)
+//
+//	run := cluster.InjectWhen(cluster.LvgPhaseTrigger(name, "Pending"), cluster.DeletePodsFault(util.AgentPods, nName), time.Minute)
+//	_ = cluster.CreateLVG(name, nName, bds)
+//	if err := run.Wait(); err != nil { ... }
+func (cluster *KCluster) InjectWhen(trigger FaultTrigger, fault Fault, timeout time.Duration) *FaultRun {
+	r := &FaultRun{fault: fault, done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		wait := Wait{Timeout: timeout, Backoff: Backoff{Initial: time.Second, Factor: 1}}
+		_, err := wait.Until(cluster.ctx, func(context.Context) error {
+			ok, err := trigger()
+			if ok {
+				return nil
+			}
+			if err == nil {
+				err = errors.New("not fired")
+			}
+			return err
+		})
+		if err != nil {
+			r.err = fmt.Errorf("fault '%s' not triggered: %w", fault.Name, err)
+			return
+		}
+
+		r.fired = true
+		cluster.Infof("Inject fault: %s", fault.Name)
+		if err := fault.Inject(); err != nil {
+			r.err = fmt.Errorf("fault '%s' injection: %w", fault.Name, err)
+			return
+		}
+		if fault.Recover != nil {
+			if err := fault.Recover(); err != nil {
+				r.err = fmt.Errorf("fault '%s' recovery: %w", fault.Name, err)
+			}
+		}
+	}()
+	return r
+}
+
+// Inject injects fault and waits for its recovery
+func (cluster *KCluster) Inject(fault Fault) error {
+	return cluster.InjectWhen(Immediately(), fault, 0).Wait()
+}
+
+// Wait waits for fault injection and recovery
+func (r *FaultRun) Wait() error {
+	<-r.done
+	return r.err
+}
+
+// Fired reports whether fault was injected
+func (r *FaultRun) Fired() bool {
+	<-r.done
+	return r.fired
+}
+
+/*  Convergence  */
+
+// Convergence describes state expected after faults
+type Convergence struct {
+	Lvgs  []string // LVGs are Ready with all conditions True
+	Nodes []string // BlockDevices of nodes are consistent with LVGs
+	Pvcs  []string // PVCs of test namespace are Bound with requested capacity
+}
+
+// WaitConverged waits for LVG, BD and PVC state converged after faults
+func (cluster *KCluster) WaitConverged(c Convergence, timeoutSec int) error {
+	return WaitFor(cluster.ctx, time.Duration(timeoutSec)*time.Second, func(context.Context) error {
+		return errors.Join(cluster.checkLvgsConverged(c.Lvgs), cluster.checkBdsConverged(c.Nodes), cluster.checkPvcsConverged(c.Pvcs))
+	})
+}
+
+func (cluster *KCluster) checkLvgsConverged(names []string) error {
+	for _, name := range names {
+		lvg, err := cluster.GetLvg(name)
+		if err != nil {
+			return err
+		}
+		if lvg.Status.Phase != "Ready" {
+			return fmt.Errorf("LVG %s not Ready: %s", name, lvg.Status.Phase)
+		}
+		for _, c := range lvg.Status.Conditions {
+			if c.Status != metav1.ConditionTrue {
+				return fmt.Errorf("LVG %s condition %s: %s", name, c.Type, c.Message)
+			}
+		}
+		if len(lvg.Status.Nodes) == 0 {
+			return fmt.Errorf("no nodes in LVG %s status", name)
+		}
+	}
+	return nil
+}
+
+func (cluster *KCluster) checkBdsConverged(nNames []string) error {
+	if len(nNames) == 0 {
+		return nil
+	}
+	lvgs, err := cluster.ListLVG()
+	if err != nil {
+		return err
+	}
+	lvgMap := map[string]snc.LVMVolumeGroup{}
+	for _, lvg := range lvgs {
+		lvgMap[lvg.Name] = lvg
+	}
+
+	for _, nName := range nNames {
+		bds, err := cluster.ListBD(BdFilter{Node: nName})
+		if err != nil {
+			return err
+		}
+		paths, bdNames := map[string]string{}, map[string]bool{}
+		for _, bd := range bds {
+			bdNames[bd.Name] = true
+			if other, ok := paths[bd.Status.Path]; ok {
+				return fmt.Errorf("BDs %s and %s have the same path %s", other, bd.Name, bd.Status.Path)
+			}
+			paths[bd.Status.Path] = bd.Name
+			if bd.Status.LVMVolumeGroupName == "" {
+				continue
+			}
+			if bd.Status.Consumable {
+				return fmt.Errorf("BD %s of LVG %s is consumable", bd.Name, bd.Status.LVMVolumeGroupName)
+			}
+			if _, ok := lvgMap[bd.Status.LVMVolumeGroupName]; !ok {
+				return fmt.Errorf("BD %s refers to missing LVG %s", bd.Name, bd.Status.LVMVolumeGroupName)
+			}
+		}
+
+		for _, lvg := range lvgs {
+			if lvg.Spec.Local.NodeName != nName {
+				continue
+			}
+			for _, node := range lvg.Status.Nodes {
+				for _, dev := range node.Devices {
+					if !bdNames[dev.BlockDevice] {
+						return fmt.Errorf("LVG %s device %s has no BD", lvg.Name, dev.BlockDevice)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (cluster *KCluster) checkPvcsConverged(names []string) error {
+	for _, name := range names {
+		pvc := coreapi.PersistentVolumeClaim{}
+		if err := cluster.controllerRuntimeClient.Get(cluster.ctx, ctrlrtclient.ObjectKey{Namespace: TestNS, Name: name}, &pvc); err != nil {
+			return err
+		}
+		if pvc.Status.Phase != coreapi.ClaimBound {
+			return fmt.Errorf("PVC %s not Bound: %s", name, pvc.Status.Phase)
+		}
+		if pvcResizing(&pvc) {
+			capacity := pvc.Status.Capacity[coreapi.ResourceStorage]
+			return fmt.Errorf("PVC %s is resizing: %s", name, capacity.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,344 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"testing"
+
+	snc "github.com/deckhouse/sds-node-configurator/api/v1alpha1"
+	coreapi "k8s.io/api/core/v1"
+	storapi "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type FixtureScope int
+
+const (
+	// ScopeTest fixture belongs to the test (node test attempt) and is torn down when it finishes
+	ScopeTest FixtureScope = iota
+	// ScopeGroup fixture is shared by node tests of the group (test running RunTestGroupNodes)
+	ScopeGroup
+	// ScopeSuite fixture is shared by all tests and torn down by TeardownFixtures (TestFinalizer)
+	ScopeSuite
+)
+
+func (s FixtureScope) String() string {
+	return [...]string{"test", "group", "suite"}[s]
+}
+
+// ErrFixtureExists is returned by Setup with usable value of the object existed before.
+// Fixture has not created the object, so it is not torn down
+var ErrFixtureExists = errors.New("fixture object exists")
+
+// Fixture is named parameterised test setup. Fixtures with the same name and scope owner
+// are set up once and shared by parallel tests, teardown runs after the last user released it.
+// Setup gets cluster with context and logger of the test acquired the fixture first
+//
+//	bd := util.ConsumableBdFixture(nName, 2).Use(t)
+type Fixture[V any] struct {
+	Name     string
+	Scope    FixtureScope
+	Setup    func(cluster *KCluster) (V, error)
+	Teardown func(V) error
+
+	group string
+}
+
+type fixtureEntry struct {
+	name     string
+	scope    FixtureScope
+	owner    string
+	seq      int
+	refs     int
+	suiteRef bool // suite holds a reference until TeardownFixtures
+	value    any
+	err      error
+	ready    chan struct{}
+	teardown func() error
+}
+
+var (
+	fixtures   = map[string]*fixtureEntry{}
+	fixtureSeq = 0
+	fixturesMx sync.Mutex
+)
+
+// scopeOwner returns name of the test the fixture is shared in
+func (f *Fixture[V]) scopeOwner(t testing.TB) string {
+	switch f.Scope {
+	case ScopeTest:
+		return t.Name()
+	case ScopeGroup:
+		if f.group != "" {
+			return f.group
+		}
+		if tt, ok := t.(*T); ok && tt.group != "" {
+			return tt.group
+		}
+		return t.Name()
+	}
+	return ""
+}
+
+// In returns copy of the fixture with scope
+//
+//	util.BoundPvcFixture("test-pvc", scName, "1Gi").In(util.ScopeGroup).Use(t)
+func (f *Fixture[V]) In(scope FixtureScope) *Fixture[V] {
+	c := *f
+	c.Scope = scope
+	return &c
+}
+
+// InGroup returns copy of the fixture shared by tests of the group
+//
+//	util.LocalStorageClassFixture(scName).InGroup(t).Use(t) // in group test, before Step or RunTestGroupNodes
+func (f *Fixture[V]) InGroup(group testing.TB) *Fixture[V] {
+	c := *f
+	c.Scope = ScopeGroup
+	c.group = group.Name()
+	return &c
+}
+
+// Use sets up the fixture or takes the shared one. Test fails if setup failed
+func (f *Fixture[V]) Use(t testing.TB) V {
+	t.Helper()
+	v, err := f.Acquire(t)
+	if err != nil {
+		t.Fatalf("fixture %s: %s", f.Name, err.Error())
+	}
+	return v
+}
+
+// Acquire sets up the fixture or takes the shared one, the reference is released by t.Cleanup
+func (f *Fixture[V]) Acquire(t testing.TB) (V, error) {
+	owner := f.scopeOwner(t)
+	key := f.Scope.String() + ":" + owner + ":" + f.Name
+
+	fixturesMx.Lock()
+	e, ok := fixtures[key]
+	if !ok {
+		fixtureSeq++
+		e = &fixtureEntry{name: f.Name, scope: f.Scope, owner: owner, seq: fixtureSeq, ready: make(chan struct{})}
+		if f.Scope == ScopeSuite {
+			e.suiteRef = true
+			e.refs++
+		}
+		fixtures[key] = e
+	}
+	e.refs++
+	fixturesMx.Unlock()
+	t.Cleanup(func() { releaseFixture(key) })
+
+	if !ok {
+		logf(TestLogger(t), slog.LevelDebug, "Fixture %s setup (%s scope %s)", f.Name, f.Scope, owner)
+		v, err := f.Setup(EnsureCluster("", "").WithContext(WithLogger(t.Context(), TestLogger(t))))
+		created := err == nil
+		if errors.Is(err, ErrFixtureExists) {
+			logf(TestLogger(t), slog.LevelDebug, "Fixture %s exists, no teardown", f.Name)
+			err = nil
+		}
+		fixturesMx.Lock()
+		e.value, e.err = v, err
+		if created && f.Teardown != nil {
+			e.teardown = func() error { return f.Teardown(v) }
+		}
+		if err != nil && e.suiteRef {
+			// failed setup is not kept for the suite, users release it
+			e.suiteRef = false
+			e.refs--
+		}
+		fixturesMx.Unlock()
+		close(e.ready)
+	}
+	<-e.ready
+
+	if e.err != nil {
+		var empty V
+		return empty, e.err
+	}
+	return e.value.(V), nil
+}
+
+func (e *fixtureEntry) tearDown() {
+	if e.teardown == nil || KeepState {
+		return
+	}
+	Debugf("Fixture %s teardown (%s scope %s)", e.name, e.scope, e.owner)
+	if err := e.teardown(); err != nil {
+		Errorf("Fixture %s teardown: %s", e.name, err.Error())
+	}
+}
+
+// releaseFixture drops the reference, the last user tears the fixture down.
+// Failed setup is not cached either, next user tries again
+func releaseFixture(key string) {
+	fixturesMx.Lock()
+	e := fixtures[key]
+	e.refs--
+	if e.refs > 0 {
+		fixturesMx.Unlock()
+		return
+	}
+	delete(fixtures, key)
+	last := *e
+	fixturesMx.Unlock()
+
+	last.tearDown()
+}
+
+// TeardownFixtures releases suite fixtures and tears down fixtures left by tests (not released),
+// in reverse setup order. Called by the last test (TestFinalizer)
+func TeardownFixtures() {
+	fixturesMx.Lock()
+	var entries []fixtureEntry
+	for key, e := range fixtures {
+		if e.suiteRef {
+			e.suiteRef = false
+			e.refs--
+			if e.refs == 0 {
+				delete(fixtures, key)
+			}
+		}
+		if e.teardown != nil {
+			entries = append(entries, *e)
+		}
+		// entry stays until its users release it, but is torn down only once
+		e.teardown = nil
+	}
+	fixturesMx.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq > entries[j].seq })
+	for i := range entries {
+		entries[i].tearDown()
+	}
+}
+
+/*  Storage fixtures  */
+
+// ConsumableBdFixture provides consumable BlockDevice with size (Gi) on node.
+// VirtualDisk is attached to node VM if hypervisor is available
+func ConsumableBdFixture(nName string, size int64) *Fixture[snc.BlockDevice] {
+	return &Fixture[snc.BlockDevice]{
+		Name: fmt.Sprintf("bd/%s/%dGi", nName, size),
+		Setup: func(cluster *KCluster) (snc.BlockDevice, error) {
+			bds, err := cluster.GetOrCreateConsumableBds(nName, size, 1)
+			if err != nil {
+				return snc.BlockDevice{}, err
+			}
+			return bds[0], nil
+		},
+	}
+}
+
+// ThickLvgFixture provides Ready LVG over new consumable BlockDevice with size (Gi) on node
+func ThickLvgFixture(nName string, size int64) *Fixture[*snc.LVMVolumeGroup] {
+	return lvgFixture(nName, size, nil)
+}
+
+// ThinLvgFixture provides Ready LVG with thin pools over new consumable BlockDevice with size (Gi) on node
+//
+//	util.ThinLvgFixture(nName, 3, snc.LVMVolumeGroupThinPoolSpec{Name: "tp1", Size: "1Gi"}, ...)
+func ThinLvgFixture(nName string, size int64, thinPools ...snc.LVMVolumeGroupThinPoolSpec) *Fixture[*snc.LVMVolumeGroup] {
+	return lvgFixture(nName, size, thinPools)
+}
+
+func lvgFixture(nName string, size int64, thinPools []snc.LVMVolumeGroupThinPoolSpec) *Fixture[*snc.LVMVolumeGroup] {
+	name := fmt.Sprintf("lvg/%s/%dGi", nName, size)
+	for _, tp := range thinPools {
+		name += fmt.Sprintf("/%s:%s:%s", tp.Name, tp.Size, tp.AllocationLimit)
+	}
+
+	return &Fixture[*snc.LVMVolumeGroup]{
+		Name: name,
+		Setup: func(cluster *KCluster) (*snc.LVMVolumeGroup, error) {
+			bds, err := cluster.GetOrCreateConsumableBds(nName, size, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			bd := bds[0]
+			lvgName := "e2e-lvg-" + bd.Name[len(bd.Name)-4:]
+			ext := map[string]any{"bds": []string{bd.Name}}
+			if len(thinPools) > 0 {
+				ext["thinpools"] = thinPools
+			}
+			if err := cluster.CreateLvgExt(lvgName, nName, ext); err != nil {
+				return nil, err
+			}
+			if err := cluster.WaitLVGsReady(LvgFilter{Name: lvgName}); err != nil {
+				return nil, err
+			}
+			return cluster.GetLvg(lvgName)
+		},
+		Teardown: func(lvg *snc.LVMVolumeGroup) error {
+			cluster := EnsureCluster("", "")
+			_, _, _ = cluster.ExecNode(nName, []string{"sudo", lvmStatic, "lvremove", "-y", lvg.Spec.ActualVGNameOnTheNode})
+			return cluster.DeleteLvgAndWait(LvgFilter{Name: lvg.Name})
+		},
+	}
+}
+
+// LocalStorageClassFixture provides local StorageClass over LVGs
+func LocalStorageClassFixture(name string, lvgs ...string) *Fixture[*storapi.StorageClass] {
+	return &Fixture[*storapi.StorageClass]{
+		Name:  "sc/" + name,
+		Scope: ScopeSuite,
+		Setup: func(cluster *KCluster) (*storapi.StorageClass, error) {
+			sc, err := cluster.CreateLocalThickStorageClass(name, lvgs...)
+			if apierrors.IsAlreadyExists(err) {
+				return &storapi.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: name}}, ErrFixtureExists
+			}
+			return sc, err
+		},
+		Teardown: func(sc *storapi.StorageClass) error {
+			return EnsureCluster("", "").DeleteStorageClass(sc.Name)
+		},
+	}
+}
+
+// BoundPvcFixture provides PVC in test namespace bound to volume
+func BoundPvcFixture(name, scName, size string) *Fixture[*coreapi.PersistentVolumeClaim] {
+	return &Fixture[*coreapi.PersistentVolumeClaim]{
+		Name: fmt.Sprintf("pvc/%s/%s/%s", name, scName, size),
+		Setup: func(cluster *KCluster) (*coreapi.PersistentVolumeClaim, error) {
+			pvc, err := cluster.CreatePVCInTestNS(name, scName, size)
+			if err != nil {
+				return nil, err
+			}
+			status, err := cluster.WaitPVCStatus(pvc.Name)
+			if err != nil {
+				return nil, err
+			}
+			if status != string(coreapi.ClaimBound) {
+				return nil, fmt.Errorf("PVC %s not bound: %s", pvc.Name, status)
+			}
+			return pvc, nil
+		},
+		Teardown: func(pvc *coreapi.PersistentVolumeClaim) error {
+			if err := EnsureCluster("", "").DeletePVC(pvc.Name); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	// NodeRetries maps test, group ("TestLvg/Ubu22") or node test name to count of retries
+	NodeRetries = map[string]int{}
+	// Quarantine lists known-flaky tests. Their failures are reported but don't fail the run
+	Quarantine = []string{}
+	flakyMx    sync.Mutex
+)
+
+// RetryNodes sets count of retries for failed node tests of the test or group.
+// Cleanups registered with T.Cleanup run before each retry
+//
+//	util.RetryNodes(t, 2)
+func RetryNodes(t *testing.T, retries int) {
+	flakyMx.Lock()
+	defer flakyMx.Unlock()
+	if _, ok := NodeRetries[t.Name()]; !ok {
+		NodeRetries[t.Name()] = retries
+	}
+}
+
+// retriesFor returns retries of the nearest parent test with retry policy
+func retriesFor(name string) int {
+	flakyMx.Lock()
+	defer flakyMx.Unlock()
+
+	for {
+		if n, ok := NodeRetries[name]; ok {
+			return n
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return 0
+		}
+		name = name[:i]
+	}
+}
+
+func isQuarantined(name string) bool {
+	flakyMx.Lock()
+	defer flakyMx.Unlock()
+
+	for _, q := range Quarantine {
+		if name == q || strings.HasPrefix(name, q+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// loadQuarantine reads test names from file (one per line, # for comments)
+func loadQuarantine(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			Quarantine = append(Quarantine, line)
+		}
+	}
+	return scanner.Err()
+}
@@ -19,6 +19,10 @@ package integration
 import (
 	"context"
 	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
 
 	logr "github.com/go-logr/logr"
 	"k8s.io/client-go/dynamic"
@@ -37,6 +41,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
 
+	v1alpha1nfs "github.com/deckhouse/csi-nfs/api/v1alpha1"
 	snc "github.com/deckhouse/sds-node-configurator/api/v1alpha1"
 	srv "github.com/deckhouse/sds-replicated-volume/api/v1alpha1"
 	virt "github.com/deckhouse/virtualization/api/core/v1alpha2"
@@ -44,11 +49,14 @@ import (
 
 type KCluster struct {
 	name                    string
+	label                   string
+	log                     *slog.Logger
 	ctx                     context.Context
 	restCfg                 *rest.Config
 	controllerRuntimeClient ctrlrtclient.Client
 	goClient                *kubernetes.Clientset
 	dyClient                *dynamic.DynamicClient
+	nodeExec                NodeExecutor // node command backend set for cluster (simulated), NodeExecOrder otherwise
 }
 
 /*  Config  */
@@ -79,8 +87,8 @@ func NewRestConfig(configPath, clusterName string) (*rest.Config, error) {
 
 /*  Kuber Client  */
 
-func NewKubeRTClient(cfg *rest.Config) (ctrlrtclient.Client, error) {
-	// Add options
+// NewScheme returns scheme of resources used by tests
+func NewScheme() (*apiruntime.Scheme, error) {
 	var resourcesSchemeFuncs = []func(*apiruntime.Scheme) error{
 		virt.AddToScheme,
 		srv.AddToScheme,
@@ -90,6 +98,7 @@ func NewKubeRTClient(cfg *rest.Config) (ctrlrtclient.Client, error) {
 		coreapi.AddToScheme,
 		storapi.AddToScheme,
 		D8SchemeBuilder.AddToScheme,
+		v1alpha1nfs.AddToScheme,
 	}
 
 	scheme := apiruntime.NewScheme()
@@ -99,13 +108,21 @@ func NewKubeRTClient(cfg *rest.Config) (ctrlrtclient.Client, error) {
 			return nil, err
 		}
 	}
+	return scheme, nil
+}
+
+func NewKubeRTClient(cfg *rest.Config) (ctrlrtclient.WithWatch, error) {
+	scheme, err := NewScheme()
+	if err != nil {
+		return nil, err
+	}
 	clientOpts := ctrlrtclient.Options{
 		Scheme: scheme,
 	}
 
 	// Init client
 	ctrlrtlog.SetLogger(logr.FromContextOrDiscard(context.Background()))
-	cl, err := ctrlrtclient.New(cfg, clientOpts)
+	cl, err := ctrlrtclient.NewWithWatch(cfg, clientOpts)
 	if err != nil {
 		return nil, err
 	}
@@ -133,6 +150,18 @@ func NewKubeDyClient(cfg *rest.Config) (*dynamic.DynamicClient, error) {
 
 /*  Kuber Cluster object  */
 
+// NewOfflineKCluster returns cluster without API server: resources are served by rtClient
+// (fake client in unit tests), node commands by executor set with SetNodeExecutor
+func NewOfflineKCluster(name string, rtClient ctrlrtclient.Client) *KCluster {
+	return &KCluster{
+		name:                    name,
+		label:                   name,
+		log:                     logger.With("cluster", name),
+		ctx:                     context.Background(),
+		controllerRuntimeClient: rtClient,
+	}
+}
+
 func InitKCluster(configPath, clusterName string) (*KCluster, error) {
 	if clusterName == "" {
 		clusterName = *clusterNameFlag
@@ -146,6 +175,24 @@ func InitKCluster(configPath, clusterName string) (*KCluster, error) {
 		Critf("Can't connect cluster %s", clusterName)
 		return nil, err
 	}
+	// API is available via ssh tunnel with custom local port
+	switch {
+	case configPath == HypervisorKubeConfig && HvLocalPort != "" && HvLocalPort != HvK8sPort:
+		restCfg.Host = strings.Replace(restCfg.Host, "127.0.0.1:"+HvK8sPort, "127.0.0.1:"+HvLocalPort, 1)
+	case configPath == NestedClusterKubeConfig && NestedLocalPort != "" && NestedLocalPort != NestedK8sPort:
+		restCfg.Host = strings.Replace(restCfg.Host, "127.0.0.1:"+NestedK8sPort, "127.0.0.1:"+NestedLocalPort, 1)
+	}
+
+	label := clusterName
+	switch {
+	case label != "":
+	case configPath == HypervisorKubeConfig:
+		label = "hypervisor"
+	case configPath == NestedClusterKubeConfig:
+		label = "nested"
+	default:
+		label = filepath.Base(configPath)
+	}
 
 	rcl, err := NewKubeRTClient(restCfg)
 	if err != nil {
@@ -153,13 +200,15 @@ func InitKCluster(configPath, clusterName string) (*KCluster, error) {
 		return nil, err
 	}
 
-	gcl, err := NewKubeGoClient(restCfg)
+	// go and dynamic clients (and pod exec) record changes in timeline by transport
+	apiCfg := timelineConfig(restCfg, label)
+	gcl, err := NewKubeGoClient(apiCfg)
 	if err != nil {
 		Critf("Can't connect cluster %s", clusterName)
 		return nil, err
 	}
 
-	dcl, err := NewKubeDyClient(restCfg)
+	dcl, err := NewKubeDyClient(apiCfg)
 	if err != nil {
 		Critf("Can't connect cluster %s", clusterName)
 		return nil, err
@@ -167,9 +216,11 @@ func InitKCluster(configPath, clusterName string) (*KCluster, error) {
 
 	cluster := KCluster{
 		name:                    clusterName,
+		label:                   label,
+		log:                     logger.With("cluster", label),
 		ctx:                     context.Background(),
-		restCfg:                 restCfg,
-		controllerRuntimeClient: rcl,
+		restCfg:                 apiCfg,
+		controllerRuntimeClient: timelineClient(rcl, label),
 		goClient:                gcl,
 		dyClient:                dcl,
 	}
@@ -202,7 +253,7 @@ func (cluster *KCluster) ListNs(filters ...NsFilter) ([]nsType, error) {
 	opts := ctrlrtclient.ListOption(&ctrlrtclient.ListOptions{})
 	err := cluster.controllerRuntimeClient.List(cluster.ctx, &nsList, opts)
 	if err != nil {
-		Warnf("Can't get namespaces: %s", err.Error())
+		cluster.Warnf("Can't get namespaces: %s", err.Error())
 		return nil, err
 	}
 
@@ -230,7 +281,7 @@ func (cluster *KCluster) CreateNs(nsName string) error {
 		return nil
 	}
 
-	Errorf("Can't create namespace %s", nsName)
+	cluster.Errorf("Can't create namespace %s", nsName)
 	return err
 }
 
@@ -260,7 +311,7 @@ func (cluster *KCluster) DeleteNsAndWait(filters ...NsFilter) error {
 	if err := cluster.DeleteNs(filters...); err != nil {
 		return err
 	}
-	return RetrySec(20, func() error {
+	return WaitFor(cluster.ctx, 20*time.Second, func(context.Context) error {
 		nsList, err := cluster.ListNs(filters...)
 		if err != nil {
 			return err
@@ -269,7 +320,7 @@ func (cluster *KCluster) DeleteNsAndWait(filters ...NsFilter) error {
 		if len(nsList) > 0 {
 			return fmt.Errorf("Can't delete %d namespaces: %s, ...", len(nsList), nsList[0].Name)
 		}
-		Debugf("Namespaces deleted")
+		cluster.Debugf("Namespaces deleted")
 		return nil
 	})
 }
@@ -293,23 +344,23 @@ func (cluster *KCluster) CheckDeploymentReady(nsName, deploymentName string) err
 }
 
 func (cluster *KCluster) WaitUntilDeploymentReady(nsName, deploymentName string, timeoutSec int) error {
-	Debugf("Waiting for deployment %s in namespace %s to be ready for %d seconds...", deploymentName, nsName, timeoutSec)
-	return RetrySec(timeoutSec, func() error {
+	cluster.Debugf("Waiting for deployment %s in namespace %s to be ready for %d seconds...", deploymentName, nsName, timeoutSec)
+	return WaitFor(cluster.ctx, time.Duration(timeoutSec)*time.Second, func(context.Context) error {
 		if err := cluster.CheckDeploymentReady(nsName, deploymentName); err != nil {
 			return err
 		}
-		Debugf("Deployment %s in namespace %s is ready", deploymentName, nsName)
+		cluster.Debugf("Deployment %s in namespace %s is ready", deploymentName, nsName)
 		return nil
 	})
 }
 
 func (cluster *KCluster) WaitUntilDaemonSetReady(nsName, dsName string, timeoutSec int) error {
-	Debugf("Waiting for daemonset %s in namespace %s to be ready for %d seconds...", dsName, nsName, timeoutSec)
-	return RetrySec(timeoutSec, func() error {
+	cluster.Debugf("Waiting for daemonset %s in namespace %s to be ready for %d seconds...", dsName, nsName, timeoutSec)
+	return WaitFor(cluster.ctx, time.Duration(timeoutSec)*time.Second, func(context.Context) error {
 		if err := cluster.CheckDaemonSetReady(nsName, dsName); err != nil {
 			return err
 		}
-		Debugf("DaemonSet %s in namespace %s is ready", dsName, nsName)
+		cluster.Debugf("DaemonSet %s in namespace %s is ready", dsName, nsName)
 		return nil
 	})
 }
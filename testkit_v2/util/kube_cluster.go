@@ -17,7 +17,9 @@ limitations under the License.
 package integration
 
 import (
+	"context"
 	"sync"
+	"time"
 )
 
 var clrCache = map[string]*KCluster{}
@@ -34,7 +36,10 @@ func EnsureCluster(configPath, clusterName string) *KCluster {
 			ClusterCreate()
 		} else {
 			NestedSshClient = GetSshClient(NestedSshUser, NestedHost+":22", NestedSshKey)
-			go NestedSshClient.NewTunnel("127.0.0.1:"+NestedK8sPort, "127.0.0.1:"+NestedK8sPort)
+			NestedTunnel = startApiTunnel(NestedSshClient, &NestedLocalPort, "127.0.0.1:"+NestedK8sPort)
+			if err := NestedTunnel.Ready(context.Background(), time.Minute); err != nil {
+				Fatalf("%s", err.Error())
+			}
 		}
 	}
 
@@ -45,6 +50,12 @@ func EnsureCluster(configPath, clusterName string) *KCluster {
 			Fatalf("Kubeclient '%s' problem: %s", k, err.Error())
 		}
 		_ = cluster.CreateNs(TestNS)
+		if configPath == "" {
+			setReportCluster(cluster)
+			if timelineEnabled() {
+				go cluster.watchEvents()
+			}
+		}
 		clrCache[k] = cluster
 	}
 
@@ -110,8 +110,29 @@ func (cluster *KCluster) EnsureModuleEnabled(moduleConfig *v1alpha1nfs.ModuleCon
 	return nil
 }
 
+func (cluster *KCluster) GetModuleConfig(name string) (*v1alpha1nfs.ModuleConfig, error) {
+	moduleConfig := &v1alpha1nfs.ModuleConfig{}
+	err := cluster.controllerRuntimeClient.Get(cluster.ctx, ctrlrtclient.ObjectKey{Name: name}, moduleConfig)
+	if err != nil {
+		return nil, err
+	}
+	return moduleConfig, nil
+}
+
+// IsModuleEnabled checks module config exists and enabled
+func (cluster *KCluster) IsModuleEnabled(name string) (bool, error) {
+	moduleConfig, err := cluster.GetModuleConfig(name)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return moduleConfig.Spec.Enabled != nil && *moduleConfig.Spec.Enabled, nil
+}
+
 func (cluster *KCluster) WaitUntilSDSReplicatedVolumeModuleReady() error {
-	Debugf("Waiting for SDS Replicated Volume module to get ready...")
+	cluster.Debugf("Waiting for SDS Replicated Volume module to get ready...")
 
 	return cluster.WaitUntilDeploymentReady(SDSReplicatedVolumeModuleNamespace, SDSReplicatedVolumeControllerDeploymentName, ModuleReadyTimeout)
 }
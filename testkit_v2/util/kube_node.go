@@ -17,14 +17,11 @@ limitations under the License.
 package integration
 
 import (
-	"bytes"
-	"context"
 	"encoding/base64"
 	"fmt"
 	"os"
 	"regexp"
 	"strings"
-	"time"
 
 	coreapi "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -32,8 +29,6 @@ import (
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	apirtschema "k8s.io/apimachinery/pkg/runtime/schema"
 	apitypes "k8s.io/apimachinery/pkg/types"
-	kubescheme "k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/tools/remotecommand"
 	ctrlrtclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -82,7 +77,7 @@ func (cluster *KCluster) GetNode(name string) (*nodeType, error) {
 func (cluster *KCluster) ListNode(filters ...NodeFilter) ([]nodeType, error) {
 	nodeList, err := (*cluster.goClient).CoreV1().Nodes().List(cluster.ctx, metav1.ListOptions{})
 	if err != nil {
-		Warnf("Can't get Nodes: %s", err.Error())
+		cluster.Warnf("Can't get Nodes: %s", err.Error())
 		return nil, err
 	}
 
@@ -94,78 +89,57 @@ func (cluster *KCluster) ListNode(filters ...NodeFilter) ([]nodeType, error) {
 	return resp, nil
 }
 
-func (cluster *KCluster) ExecNodeSsh(name, cmd string) (string, error) {
+// nodeSshClient returns ssh client of node (through NestedSshClient), close it after use
+func (cluster *KCluster) nodeSshClient(name string) (sshClient, error) {
 	node, err := cluster.GetNode(name)
 	if err != nil {
-		return "", err
+		return sshClient{}, err
 	}
 	for _, addr := range node.Status.Addresses {
 		if addr.Type == "InternalIP" {
-			client := NestedSshClient.GetFwdClient(NestedSshUser, addr.Address+":22", NestedSshKey)
-			return client.Exec(cmd)
+			client, err := NestedSshClient.getFwdClient(NestedSshUser, addr.Address+":22", NestedSshKey, nil)
+			if err != nil {
+				return sshClient{}, err
+			}
+			client.node, client.cluster = name, cluster.label
+			return client, nil
 		}
 	}
 
-	return "", fmt.Errorf("no node InternalIP")
+	return sshClient{}, fmt.Errorf("no node InternalIP")
 }
 
-func (cluster *KCluster) ExecNode(name string, cmd []string) (string, string, error) {
-	nsName := "d8-sds-node-configurator"
-	pods, err := cluster.ListPod(nsName, PodFilter{Name: "%sds-node-configurator-%", Node: name})
-	if err != nil {
-		return "", "", err
-	}
-	if len(pods) == 0 {
-		return "", "", fmt.Errorf("no sds-node-configurator for node %s", name)
-	}
-
-	nsCmd := append([]string{"/opt/deckhouse/sds/bin/nsenter.static", "-m", "-u", "-i", "-p", "-t", "1", "--"}, cmd...)
-	req := cluster.goClient.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(pods[0].ObjectMeta.Name).
-		Namespace(nsName).
-		SubResource("exec").
-		Timeout(5 * time.Second)
-	req = req.VersionedParams(&coreapi.PodExecOptions{
-		Container: pods[0].Spec.Containers[0].Name,
-		Command:   nsCmd,
-		Stdin:     false,
-		Stdout:    true,
-		Stderr:    true,
-	}, kubescheme.ParameterCodec)
-
-	exec, err := remotecommand.NewSPDYExecutor(cluster.restCfg, "POST", req.URL())
-	//NewSPDYExecutor connects to the provided server and upgrades the connection to multiplexed bidirectional streams
+func (cluster *KCluster) ExecNodeSsh(name, cmd string) (string, error) {
+	client, err := cluster.nodeSshClient(name)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
+	defer client.Close()
+	return client.Exec(cmd)
+}
 
-	var stdout, stderr bytes.Buffer
-	streamOps := remotecommand.StreamOptions{
-		Stdout: &stdout,
-		Stderr: &stderr,
-	}
-	err = exec.StreamWithContext(context.Background(), streamOps)
+// ExecNode runs cmd on node with first available NodeExecutor (NodeExecOrder)
+func (cluster *KCluster) ExecNode(name string, cmd []string) (string, string, error) {
+	e, err := cluster.NodeExecutor(name)
 	if err != nil {
-		return stdout.String(), stderr.String(), fmt.Errorf("Exec %s %v: %s", name, cmd, err.Error())
+		return "", "", err
 	}
-
-	return stdout.String(), stderr.String(), nil
+	return e.Exec(name, cmd)
 }
 
 // Execue cmd on node and check output using expressions
 func (cluster *KCluster) ExecNodeRespContains(nName, cmd string, resp []string) error {
 	stOut, stErr, err := cluster.ExecNode(nName, strings.Split(cmd, " "))
 	if err != nil {
-		Debugf("Exec %s: %s", nName, cmd)
-		Debugf("  StdErr: %s", stErr)
+		cluster.Debugf("Exec %s: %s", nName, cmd)
+		cluster.Debugf("  StdErr: %s", stErr)
 		return err
 	}
 	for _, r := range resp {
 		if match, _ := regexp.MatchString(r, stOut); !match {
-			Debugf("Exec %s: %s", nName, cmd)
-			Debugf("  Don`t contains: '%s'", r)
-			Debugf("  Out:\n%s", stOut)
+			cluster.Debugf("Exec %s: %s", nName, cmd)
+			cluster.Debugf("  Don`t contains: '%s'", r)
+			cluster.Debugf("  Out:\n%s", stOut)
 			return fmt.Errorf("exec %s `%s` wrong output", nName, cmd)
 		}
 	}
@@ -177,15 +151,15 @@ func (cluster *KCluster) ExecNodeRespContains(nName, cmd string, resp []string)
 func (cluster *KCluster) ExecNodeRespNotContains(nName, cmd string, resp []string) error {
 	stOut, stErr, err := cluster.ExecNode(nName, strings.Split(cmd, " "))
 	if err != nil {
-		Debugf("Exec %s: %s", nName, cmd)
-		Debugf("  StdErr: %s", stErr)
+		cluster.Debugf("Exec %s: %s", nName, cmd)
+		cluster.Debugf("  StdErr: %s", stErr)
 		return err
 	}
 	for _, r := range resp {
 		if match, _ := regexp.MatchString(r, stOut); match {
-			Debugf("Exec %s: %s", nName, cmd)
-			Debugf("  Not contains: '%s'", r)
-			Debugf("  Out:\n%s", stOut)
+			cluster.Debugf("Exec %s: %s", nName, cmd)
+			cluster.Debugf("  Not contains: '%s'", r)
+			cluster.Debugf("  Out:\n%s", stOut)
 			return fmt.Errorf("exec %s `%s` wrong output", nName, cmd)
 		}
 	}
@@ -231,12 +205,12 @@ func (cluster *KCluster) CreateNodeGroup(data map[string]interface{}) error {
 	_, err := cluster.dyClient.Resource(nodeGroupResource).
 		Create(cluster.ctx, &obj, metav1.CreateOptions{})
 	if err == nil {
-		Infof("NodeGroup %q created", name)
+		cluster.Infof("NodeGroup %q created", name)
 		return nil
 	}
 
 	if apierrors.IsAlreadyExists(err) {
-		Infof("NodeGroup %q updating ...", name)
+		cluster.Infof("NodeGroup %q updating ...", name)
 		content, err := obj.MarshalJSON()
 		if err != nil {
 			return err
@@ -340,13 +314,13 @@ func (cluster *KCluster) EnsureStaticInstance(name, role, ip, credentials string
 	if err != nil {
 		if apierrors.IsNotFound(err) {
 			if err := cluster.controllerRuntimeClient.Create(cluster.ctx, si); err != nil {
-				Errorf("Can't create StaticInstance %s", name)
+				cluster.Errorf("Can't create StaticInstance %s", name)
 				return err
 			}
 			return nil
 		}
 
-		Errorf("Can't get StaticInstance %s: %s", name, err.Error())
+		cluster.Errorf("Can't get StaticInstance %s: %s", name, err.Error())
 		return err
 	}
 
@@ -358,7 +332,7 @@ func (cluster *KCluster) EnsureStaticInstance(name, role, ip, credentials string
 		}
 
 		if err := cluster.controllerRuntimeClient.Update(cluster.ctx, existing); err != nil {
-			Errorf("Can't update StaticInstance %s: %s", name, err.Error())
+			cluster.Errorf("Can't update StaticInstance %s: %s", name, err.Error())
 			return err
 		}
 	}
@@ -380,14 +354,14 @@ func (cluster *KCluster) DeleteStaticInstance(name string) error {
 func (cluster *KCluster) createSSHCredentials(name, user string) (string, error) {
 	privSshKey, err := os.ReadFile(NestedSshKey)
 	if err != nil {
-		Errorf("Read %s: %s", NestedSshKey, err.Error())
+		cluster.Errorf("Read %s: %s", NestedSshKey, err.Error())
 		return "", err
 	}
 	b64SshKey := base64.StdEncoding.EncodeToString(privSshKey)
 
 	credentialName := name + "rsa"
 	if err = cluster.CreateOrUpdSSHCredential(credentialName, user, b64SshKey); err != nil {
-		Errorf("Create SSHCredential: %s", err.Error())
+		cluster.Errorf("Create SSHCredential: %s", err.Error())
 		return "", err
 	}
 
@@ -399,7 +373,7 @@ func (cluster *KCluster) createStaticInstances(name, role string, ips []string,
 		siName := fmt.Sprintf("si-%s-%s", name, hashMd5(ip)[:8])
 		err := cluster.EnsureStaticInstance(siName, role, ip, credentialName)
 		if err != nil {
-			Errorf("Create StaticInstance %s: %s", siName, err.Error())
+			cluster.Errorf("Create StaticInstance %s: %s", siName, err.Error())
 			return err
 		}
 	}
@@ -408,7 +382,7 @@ func (cluster *KCluster) createStaticInstances(name, role string, ips []string,
 
 func (cluster *KCluster) createNodeGroupForStatic(role string, count int) error {
 	if err := cluster.CreateNodeGroupStatic(role, role, count); err != nil {
-		Errorf("Create NodeGroup: %s", err.Error())
+		cluster.Errorf("Create NodeGroup: %s", err.Error())
 		return err
 	}
 	return nil
@@ -465,7 +439,7 @@ func (cluster *KCluster) GetPod(nsName, pName string) (*coreapi.Pod, error) {
 func (cluster *KCluster) ListPod(nsName string, filters ...PodFilter) ([]coreapi.Pod, error) {
 	pods, err := cluster.goClient.CoreV1().Pods(nsName).List(cluster.ctx, metav1.ListOptions{})
 	if err != nil {
-		Errorf("Can't get Pods: %s", err.Error())
+		cluster.Errorf("Can't get Pods: %s", err.Error())
 		return nil, err
 	}
 
@@ -477,6 +451,19 @@ func (cluster *KCluster) ListPod(nsName string, filters ...PodFilter) ([]coreapi
 	return resp, nil
 }
 
+// GetPodLogs returns last lines of pod logs (all lines if tail is 0)
+func (cluster *KCluster) GetPodLogs(nsName, pName string, tail int64) (string, error) {
+	opts := &coreapi.PodLogOptions{}
+	if tail > 0 {
+		opts.TailLines = &tail
+	}
+	data, err := cluster.goClient.CoreV1().Pods(nsName).GetLogs(pName, opts).Do(cluster.ctx).Raw()
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 func (cluster *KCluster) CreatePod(nsName, pName string) error {
 	if nsName == "" {
 		nsName = TestNS
@@ -28,7 +28,7 @@ func (cluster *KCluster) GetSSHCredential(name string) (*SSHCredentials, error)
 	sshcredential := &SSHCredentials{}
 	err := cluster.controllerRuntimeClient.Get(cluster.ctx, ctrlrtclient.ObjectKey{Name: name}, sshcredential)
 	if err != nil {
-		Debugf("Can't get SSHCredential %s: %s", name, err.Error())
+		cluster.Debugf("Can't get SSHCredential %s: %s", name, err.Error())
 		return nil, err
 	}
 	return sshcredential, nil
@@ -59,7 +59,7 @@ func (cluster *KCluster) CreateSSHCredential(name, user, privSshKey string) erro
 	if err == nil || apierrors.IsAlreadyExists(err) {
 		return nil
 	}
-	Debugf("Can't create SSHCredential %s: %s", name, err.Error())
+	cluster.Debugf("Can't create SSHCredential %s: %s", name, err.Error())
 	return err
 }
 
@@ -77,7 +77,7 @@ func (cluster *KCluster) CreateOrUpdSSHCredential(name, user, privSshKey string)
 		PrivateSSHKey: privSshKey,
 	}
 	if err = cluster.controllerRuntimeClient.Update(cluster.ctx, sshcredential); err != nil {
-		Warnf("Can't update SSHCredential %s: %s", name, err.Error())
+		cluster.Warnf("Can't update SSHCredential %s: %s", name, err.Error())
 		return err
 	}
 	return nil
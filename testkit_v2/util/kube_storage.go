@@ -17,7 +17,9 @@ limitations under the License.
 package integration
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	snc "github.com/deckhouse/sds-node-configurator/api/v1alpha1"
@@ -33,6 +35,8 @@ const (
 	DefaultLVMVolumeGroupNamePrefix = "default-lvg-"
 	DefaultLVMVolumeGroupSize       = "10Gi"
 	DefaultVGNameOnTheNode          = "vg-default"
+
+	lvmStatic = "/opt/deckhouse/sds/bin/lvm.static"
 )
 
 /*  Block Device  */
@@ -72,7 +76,7 @@ func (cluster *KCluster) ListBD(filters ...BdFilter) ([]snc.BlockDevice, error)
 	bdList := &snc.BlockDeviceList{}
 	err := cluster.controllerRuntimeClient.List(cluster.ctx, bdList)
 	if err != nil {
-		Warnf("Can't get BDs: %s", err.Error())
+		cluster.Warnf("Can't get BDs: %s", err.Error())
 		return nil, err
 	}
 
@@ -102,7 +106,7 @@ func (cluster *KCluster) DeleteBdAndWait(filters ...BdFilter) error {
 	if err := cluster.DeleteBd(filters...); err != nil {
 		return err
 	}
-	return RetrySec(20, func() error {
+	return WaitFor(cluster.ctx, 20*time.Second, func(context.Context) error {
 		bds, err := cluster.ListBD(filters...)
 		if err != nil {
 			return err
@@ -111,11 +115,43 @@ func (cluster *KCluster) DeleteBdAndWait(filters ...BdFilter) error {
 		if len(bds) > 0 {
 			return fmt.Errorf("not deleted BDs: %d (%s, ...)", len(bds), bds[0].Name)
 		}
-		Debugf("BDs deleted")
+		cluster.Debugf("BDs deleted")
 		return nil
 	})
 }
 
+// GetOrCreateConsumableBds provides count consumable BlockDevices with size (Gi) on node.
+// Missing devices are created as VirtualDisks attached to node VM (hypervisor required)
+func (cluster *KCluster) GetOrCreateConsumableBds(nName string, size int64, count int) ([]snc.BlockDevice, error) {
+	bds, _ := cluster.ListBD(BdFilter{Node: nName, Consumable: true, Size: float32(size)})
+	if len(bds) >= count {
+		return bds, nil
+	}
+
+	if HypervisorKubeConfig == "" {
+		return nil, fmt.Errorf("Not enough bds on %s: %d of %d", nName, len(bds), count)
+	}
+	hvCluster := EnsureCluster(HypervisorKubeConfig, "")
+	for i := len(bds); i < count; i++ {
+		err := hvCluster.CreateVMBD(nName, nName+"-data-"+RandString(4), HvStorageClass, size)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := WaitFor(cluster.ctx, 30*time.Second, func(context.Context) error {
+		bds, _ := cluster.ListBD(BdFilter{Node: nName, Consumable: true, Size: float32(size)})
+		if len(bds) < count {
+			return fmt.Errorf("Not enough bds on %s: %d of %d", nName, len(bds), count)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return cluster.ListBD(BdFilter{Node: nName, Consumable: true, Size: float32(size)})
+}
+
 /*  LVM Volume Group  */
 
 type LvgFilter struct {
@@ -162,7 +198,7 @@ func (cluster *KCluster) GetLvg(lvgName string) (*snc.LVMVolumeGroup, error) {
 func (cluster *KCluster) ListLVG(filters ...LvgFilter) ([]snc.LVMVolumeGroup, error) {
 	lvgList := &snc.LVMVolumeGroupList{}
 	if err := cluster.controllerRuntimeClient.List(cluster.ctx, lvgList); err != nil {
-		Warnf("Can't get LVGs: %s", err.Error())
+		cluster.Warnf("Can't get LVGs: %s", err.Error())
 		return nil, err
 	}
 
@@ -176,7 +212,7 @@ func (cluster *KCluster) ListLVG(filters ...LvgFilter) ([]snc.LVMVolumeGroup, er
 
 func (cluster *KCluster) WaitLVGsReady(filters ...LvgFilter) error {
 	filtersNotReady := append(filters, LvgFilter{Phase: "!Ready"})
-	if err := RetrySec(35, func() error {
+	if err := WaitFor(cluster.ctx, 35*time.Second, func(context.Context) error {
 		lvgs, err := cluster.ListLVG(filtersNotReady...)
 		if err != nil {
 			return err
@@ -193,7 +229,7 @@ func (cluster *KCluster) WaitLVGsReady(filters ...LvgFilter) error {
 	if err != nil {
 		return err
 	}
-	Debugf("LVGs is ready: %d", len(lvgs))
+	cluster.Debugf("LVGs is ready: %d", len(lvgs))
 	for _, lvg := range lvgs {
 		if len(lvg.Status.Nodes) == 0 {
 			return fmt.Errorf("no nodes in LVG %s status", lvg.Name)
@@ -204,7 +240,7 @@ func (cluster *KCluster) WaitLVGsReady(filters ...LvgFilter) error {
 }
 
 func (cluster *KCluster) CreateLVG(name, nodeName string, bds []string) error {
-	Debugf("Creating LVG %s (node %s, bds %v)", name, nodeName, bds)
+	cluster.Debugf("Creating LVG %s (node %s, bds %v)", name, nodeName, bds)
 	lvmVolumeGroup := &snc.LVMVolumeGroup{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: name,
@@ -222,7 +258,7 @@ func (cluster *KCluster) CreateLVG(name, nodeName string, bds []string) error {
 	}
 	err := cluster.controllerRuntimeClient.Create(cluster.ctx, lvmVolumeGroup)
 	if err != nil {
-		Errorf("Can't create LVG %s (node %s, bds %v)", name, nodeName, bds)
+		cluster.Errorf("Can't create LVG %s (node %s, bds %v)", name, nodeName, bds)
 		return err
 	}
 	return nil
@@ -260,7 +296,7 @@ func (cluster *KCluster) CreateLvgExt(name, nodeName string, ext map[string]any)
 	}
 	err := cluster.controllerRuntimeClient.Create(cluster.ctx, lvg)
 	if err != nil {
-		Errorf("Can't create LVG %s/%s", nodeName, name)
+		cluster.Errorf("Can't create LVG %s/%s", nodeName, name)
 		return err
 	}
 	return nil
@@ -269,7 +305,7 @@ func (cluster *KCluster) CreateLvgExt(name, nodeName string, ext map[string]any)
 func (cluster *KCluster) UpdateLVG(lvg *snc.LVMVolumeGroup) error {
 	err := cluster.controllerRuntimeClient.Update(cluster.ctx, lvg)
 	if err != nil {
-		Errorf("Can't update LVG %s", lvg.Name)
+		cluster.Errorf("Can't update LVG %s", lvg.Name)
 		return err
 	}
 
@@ -293,7 +329,7 @@ func (cluster *KCluster) DeleteLvgAndWait(filters ...LvgFilter) error {
 		return err
 	}
 
-	return RetrySec(15, func() error {
+	return WaitFor(cluster.ctx, 15*time.Second, func(context.Context) error {
 		lvgs, err := cluster.ListLVG(filters...)
 		if err != nil {
 			return err
@@ -301,16 +337,20 @@ func (cluster *KCluster) DeleteLvgAndWait(filters ...LvgFilter) error {
 		if len(lvgs) > 0 {
 			return fmt.Errorf("LVGs not deleted: %d", len(lvgs))
 		}
-		Debugf("LVGs deleted")
+		cluster.Debugf("LVGs deleted")
 		return nil
 	})
 }
 
 /*  Storage Class  */
 
-func (cluster *KCluster) CreateLocalThickStorageClass(name string) (*storapi.StorageClass, error) {
+// CreateLocalThickStorageClass creates local StorageClass over LVGs (default: vg-w1, vg-w2)
+func (cluster *KCluster) CreateLocalThickStorageClass(name string, lvgs ...string) (*storapi.StorageClass, error) {
 	lvmType := "Thick"
-	lvmVolGroups := "- name: vg-w1\n- name: vg-w2"
+	if len(lvgs) == 0 {
+		lvgs = []string{"vg-w1", "vg-w2"}
+	}
+	lvmVolGroups := "- name: " + strings.Join(lvgs, "\n- name: ")
 
 	volBindingMode := storapi.VolumeBindingImmediate
 
@@ -340,12 +380,21 @@ func (cluster *KCluster) CreateLocalThickStorageClass(name string) (*storapi.Sto
 	}
 
 	if err := cluster.controllerRuntimeClient.Create(cluster.ctx, sc); err != nil {
-		Errorf("Can't create SC %s", sc.Name)
+		cluster.Errorf("Can't create SC %s", sc.Name)
 		return nil, err
 	}
 	return sc, nil
 }
 
+func (cluster *KCluster) DeleteStorageClass(name string) error {
+	sc := &storapi.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if err := cluster.controllerRuntimeClient.Delete(cluster.ctx, sc); err != nil && !apierrors.IsNotFound(err) {
+		cluster.Errorf("Can't delete SC %s", name)
+		return err
+	}
+	return nil
+}
+
 func (cluster *KCluster) CreateDefaultStorageClass(name string) (*storapi.StorageClass, error) {
 	enableThinProvisioning := true
 	err := cluster.EnsureSDSReplicatedVolumeModuleEnabled(enableThinProvisioning)
@@ -394,7 +443,7 @@ func (cluster *KCluster) EnsureStorageClass(storageClassName string) (*storapi.S
 func (cluster *KCluster) ListPVC(nsName string) ([]coreapi.PersistentVolumeClaim, error) {
 	pvcList, err := (*cluster.goClient).CoreV1().PersistentVolumeClaims(nsName).List(cluster.ctx, metav1.ListOptions{})
 	if err != nil {
-		Debugf("Can't get '%s' PVCs: %s", nsName, err.Error())
+		cluster.Debugf("Can't get '%s' PVCs: %s", nsName, err.Error())
 		return nil, err
 	}
 
@@ -446,26 +495,27 @@ func (cluster *KCluster) CreatePVCInTestNS(name, scName, size string) (*coreapi.
 
 func (cluster *KCluster) WaitPVCStatus(name string) (string, error) {
 	pvc := coreapi.PersistentVolumeClaim{}
-	for i := 0; i < pvcWaitIterationCount; i++ {
-		err := cluster.controllerRuntimeClient.Get(cluster.ctx, ctrlrtclient.ObjectKey{
+	timeout := pvcWaitInterval * pvcWaitIterationCount * time.Second
+	_, err := Wait{Timeout: timeout, Backoff: Backoff{Initial: pvcWaitInterval * time.Second, Factor: 1}}.Until(cluster.ctx, func(ctx context.Context) error {
+		err := cluster.controllerRuntimeClient.Get(ctx, ctrlrtclient.ObjectKey{
 			Name:      name,
 			Namespace: TestNS,
 		}, &pvc)
 		if err != nil {
-			Debugf("Get PVC error: %v", err)
-		}
-		if pvc.Status.Phase == coreapi.ClaimBound {
-			return string(pvc.Status.Phase), nil
+			cluster.Debugf("Get PVC error: %v", err)
 		}
-
-		if len(pvc.Status.Phase) == 0 {
-			return "Deleted", nil
+		if pvc.Status.Phase == coreapi.ClaimBound || len(pvc.Status.Phase) == 0 {
+			return nil
 		}
-
-		time.Sleep(pvcWaitInterval * time.Second)
+		return fmt.Errorf("PVC %s phase %s", name, pvc.Status.Phase)
+	})
+	if err != nil {
+		return string(pvc.Status.Phase), fmt.Errorf("the waiting time %s or the pvc to be ready has expired", timeout)
+	}
+	if len(pvc.Status.Phase) == 0 {
+		return "Deleted", nil
 	}
-	return string(pvc.Status.Phase), fmt.Errorf("the waiting time %d or the pvc to be ready has expired",
-		pvcWaitInterval*pvcWaitIterationCount)
+	return string(pvc.Status.Phase), nil
 }
 
 func (cluster *KCluster) DeletePVC(name string) error {
@@ -489,7 +539,7 @@ func (cluster *KCluster) DeletePVC(name string) error {
 func (cluster *KCluster) UpdatePVC(pvc *coreapi.PersistentVolumeClaim) error {
 	err := cluster.controllerRuntimeClient.Update(cluster.ctx, pvc)
 	if err != nil {
-		Warnf("Can't update PVC %s", pvc.Name)
+		cluster.Warnf("Can't update PVC %s", pvc.Name)
 		return err
 	}
 
@@ -520,17 +570,17 @@ func (cluster *KCluster) EnsureEveryNodeHasLVMVolumeGroup(lvmVolumeGroupSize str
 		}
 
 		if !lvgExists {
-			Debugf("Creating LVM Volume Group %s for node %s", lvgName, nodeName)
+			cluster.Debugf("Creating LVM Volume Group %s for node %s", lvgName, nodeName)
 			err = cluster.CreateLvgWithCheck(lvgName, nodeName, []string{})
 			if err != nil {
 				return fmt.Errorf("failed to create LVM Volume Group %s for node %s: %w", lvgName, nodeName, err)
 			}
 		} else {
-			Debugf("LVM Volume Group %s already exists for node %s", lvgName, nodeName)
+			cluster.Debugf("LVM Volume Group %s already exists for node %s", lvgName, nodeName)
 		}
 	}
 
-	Debugf("All nodes have LVM Volume Groups")
+	cluster.Debugf("All nodes have LVM Volume Groups")
 
 	return nil
 }
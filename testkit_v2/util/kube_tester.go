@@ -17,7 +17,14 @@ limitations under the License.
 package integration
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	coreapi "k8s.io/api/core/v1"
 )
@@ -32,41 +39,419 @@ type TestNode struct {
 type T struct {
 	*testing.T
 	Node *TestNode
+
+	group    string // name of the test running node tests of the group
+	ctx      context.Context
+	log      *slog.Logger
+	logOnce  sync.Once
+	rc       *ReportCase
+	attempt  int
+	soft     bool // failures are collected and logged, testing.T is not failed (retry, quarantine)
+	async    bool // function runs out of test goroutine, failures are reported by report()
+	mx       sync.Mutex
+	expired  bool
+	failures []string
+	skipped  string
+	cleanups []func()
 }
 
-func (t *T) Skip(args ...any) {
-	if SkipOptional {
-		Warn(args...)
-		t.T.Skip(args...)
+// failure records node test failure. After timeout the test function has returned
+// and testing.T must not be used, so late failures are only logged. Out of test goroutine
+// testing.T can't be failed, failures are collected and reported by report()
+func (t *T) failure(msg string, fatal bool) {
+	t.T.Helper()
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	switch {
+	case t.expired:
+		logf(withSink(t.Logger(), nil), slog.LevelWarn, "%s (after timeout): %s", t.T.Name(), msg)
+	case t.soft:
+		t.failures = append(t.failures, msg)
+		t.T.Logf("attempt %d: %s", t.attempt, msg)
+	case t.async:
+		t.failures = append(t.failures, msg)
+		t.T.Log(msg)
+	case fatal:
+		t.failures = append(t.failures, msg)
+		t.T.Fatal(msg)
+	default:
+		t.failures = append(t.failures, msg)
+		t.T.Error(msg)
+	}
+	if fatal {
+		runtime.Goexit()
+	}
+}
+
+func (t *T) skipNow(msg string) {
+	t.T.Helper()
+	if !SkipOptional {
+		t.failure(msg, true)
+	}
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	switch {
+	case t.expired:
+		logf(withSink(t.Logger(), nil), slog.LevelWarn, "%s (after timeout): %s", t.T.Name(), msg)
+	case t.async:
+		t.skipped = msg
+		t.rc.addOutput(msg)
+		t.T.Log(msg)
+	default:
+		t.skipped = msg
+		t.rc.addOutput(msg)
+		t.T.Skip(msg)
+	}
+	runtime.Goexit()
+}
+
+func (t *T) timeout(msg string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.expired = true
+	t.failures = append(t.failures, msg)
+	t.T.Logf("attempt %d: %s", t.attempt, msg)
+}
+
+func (t *T) timedOut() bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.expired
+}
+
+// report fails or skips testing.T with results of async attempt, must run in test goroutine
+func (t *T) report() {
+	if !t.async || t.soft {
+		return
+	}
+	failures, skipped := t.result()
+	for _, msg := range failures {
+		t.T.Error(msg)
+	}
+	if skipped != "" && len(failures) == 0 && TreeMode {
+		t.T.Skip(skipped)
+	}
+}
+
+func (t *T) result() (failures []string, skipped string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return append([]string{}, t.failures...), t.skipped
+}
+
+// Cleanup registers function to call when the node test attempt finishes
+func (t *T) Cleanup(f func()) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.cleanups = append(t.cleanups, f)
+}
+
+func (t *T) runCleanups() {
+	t.mx.Lock()
+	cleanups := t.cleanups
+	t.cleanups = nil
+	t.mx.Unlock()
+
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+}
+
+// Context is canceled when node subtest timeout exceeded. It carries node test logger (LoggerFrom)
+func (t *T) Context() context.Context {
+	ctx := t.ctx
+	if ctx == nil {
+		ctx = t.T.Context()
 	}
-	t.Fatal(args...)
+	return WithLogger(ctx, t.Logger())
+}
+
+func (t *T) Log(args ...any) {
+	t.T.Helper()
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.expired {
+		logf(withSink(t.Logger(), nil), slog.LevelWarn, "%s (after timeout): %s", t.T.Name(), fmt.Sprint(args...))
+		return
+	}
+	t.rc.addOutput(fmt.Sprint(args...))
+	t.T.Log(args...)
+}
+
+// logLine writes logger output under the node test. After timeout it goes to terminal
+func (t *T) logLine(line string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.rc.addOutput(line)
+	if t.expired {
+		_ = termOut.writeLine(line)
+		return
+	}
+	t.T.Log(line)
+}
+
+func (t *T) Logf(format string, args ...any) {
+	t.T.Helper()
+	t.Log(fmt.Sprintf(format, args...))
+}
+
+func (t *T) Error(args ...any) {
+	t.T.Helper()
+	t.failure(fmt.Sprint(args...), false)
+}
+
+func (t *T) Errorf(format string, args ...any) {
+	t.T.Helper()
+	t.failure(fmt.Sprintf(format, args...), false)
+}
+
+func (t *T) Fatal(args ...any) {
+	t.T.Helper()
+	t.failure(fmt.Sprint(args...), true)
+}
+
+func (t *T) Fatalf(format string, args ...any) {
+	t.T.Helper()
+	t.failure(fmt.Sprintf(format, args...), true)
+}
+
+// Failed reports failures of the attempt, in soft and async mode testing.T is not failed
+func (t *T) Failed() bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return len(t.failures) > 0 || t.T.Failed()
+}
+
+func (t *T) Skipped() bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.skipped != "" || t.T.Skipped()
+}
+
+func (t *T) Fail() {
+	t.T.Helper()
+	t.failure("test failed", false)
+}
+
+func (t *T) FailNow() {
+	t.T.Helper()
+	t.failure("test failed", true)
+}
+
+func (t *T) SkipNow() {
+	t.T.Helper()
+	t.skipNow("test skipped")
+}
+
+func (t *T) Skip(args ...any) {
+	t.T.Helper()
+	t.skipNow(fmt.Sprint(args...))
 }
 
 func (t *T) Skipf(format string, args ...any) {
-	if SkipOptional {
-		Warnf(format, args...)
-		t.T.Skipf(format, args...)
+	t.T.Helper()
+	t.skipNow(fmt.Sprintf(format, args...))
+}
+
+/*  Node slots  */
+
+var (
+	nodeSlots  chan struct{}
+	groupSlots = map[string]chan struct{}{}
+	slotsMx    sync.Mutex
+)
+
+func initNodeSlots() {
+	if NodeParallel > 0 {
+		nodeSlots = make(chan struct{}, NodeParallel)
+	}
+	for label, limit := range GroupParallel {
+		if limit > 0 {
+			groupSlots[label] = make(chan struct{}, limit)
+		}
+	}
+}
+
+// acquireNodeSlot blocks until node subtest of the group is allowed to run
+func acquireNodeSlot(group string) (release func()) {
+	slotsMx.Lock()
+	gSlots := groupSlots[group]
+	slotsMx.Unlock()
+
+	if gSlots != nil {
+		gSlots <- struct{}{}
+	}
+	if nodeSlots != nil {
+		nodeSlots <- struct{}{}
+	}
+
+	return func() {
+		if nodeSlots != nil {
+			<-nodeSlots
+		}
+		if gSlots != nil {
+			<-gSlots
+		}
 	}
-	t.Fatalf(format, args...)
+}
+
+/*  Run  */
+
+func (cluster *KCluster) nodeDiagnostic(name string) string {
+	node, err := cluster.GetNode(name)
+	if err != nil {
+		return fmt.Sprintf("can't get node: %s", err.Error())
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type == coreapi.NodeReady {
+			return fmt.Sprintf("node Ready=%s since %s (%s)", c.Status, c.LastTransitionTime.Format(time.RFC3339), c.Message)
+		}
+	}
+	return "node has no Ready condition"
+}
+
+// runNode runs node test with concurrency limits, NodeTimeout, retries and quarantine
+func (cluster *KCluster) runNode(t *testing.T, group string, tn *TestNode, f func(t *T)) {
+	name := t.Name()
+	if !TreeMode {
+		name += "/" + tn.GroupName + "/" + tn.Name
+	}
+	rc := trackNode(name, tn)
+	log := cluster.Logger().With("test", t.Name(), "group", tn.GroupName, "node", tn.Name)
+	tlog := withSink(log, tbSink{t})
+	quarantined := isQuarantined(name)
+	attempts := 1 + retriesFor(name)
+
+	var tt *T
+	defer func() {
+		if tt != nil {
+			failures, skipped := tt.result()
+			rc.setResult(failures, skipped, tt.attempt, quarantined)
+		}
+		// without own subtest node status is set by setResult
+		rc.finish(TreeMode && t.Failed(), TreeMode && t.Skipped())
+	}()
+
+	if req, ok := requirementsFor(t.Name()); ok {
+		if missing := cluster.missingNodeRequirements(req, tn.Name); len(missing) > 0 {
+			unmet(t, rc, tn.Name, missing)
+			return
+		}
+	}
+
+	if deps, skip := nodePrerequisites(name); len(deps) > 0 {
+		rc.setDependsOn(deps)
+		if skip != "" {
+			logf(tlog, slog.LevelWarn, "%s skipped: %s", name, skip)
+			rc.setSkipped(skip)
+			if TreeMode {
+				t.Skip(skip)
+			}
+			return
+		}
+	}
+
+	release := acquireNodeSlot(tn.GroupName)
+	var done <-chan struct{}
+	defer func() {
+		// timed out function may still work with the node, the slot is busy until it returns
+		go func() {
+			select {
+			case <-done:
+			case <-time.After(NodeTimeoutGrace):
+				logf(tlog, slog.LevelWarn, "%s still runs %s after timeout, node slot released", name, NodeTimeoutGrace)
+			}
+			release()
+		}()
+	}()
+
+	for attempt := 1; ; attempt++ {
+		tt = &T{T: t, Node: tn, group: group, rc: rc, attempt: attempt, soft: quarantined || attempt < attempts}
+		tt.log = withSink(log, tt)
+		done = cluster.runAttempt(tt, f)
+		tt.report()
+
+		failures, skipped := tt.result()
+		if len(failures) == 0 || skipped != "" || attempt == attempts {
+			break
+		}
+		if tt.timedOut() {
+			// previous attempt may still run on the node, don't start another one
+			logf(tlog, slog.LevelWarn, "%s attempt %d/%d timed out, no retry", name, attempt, attempts)
+			if tt.soft && !quarantined {
+				for _, msg := range failures {
+					t.Error(msg)
+				}
+			}
+			break
+		}
+		rc.addRetry(failures)
+		logf(tlog, slog.LevelWarn, "%s attempt %d/%d failed, retry: %s", name, attempt, attempts, strings.Join(failures, "; "))
+	}
+
+	if failures, _ := tt.result(); len(failures) > 0 && quarantined {
+		logf(tlog, slog.LevelWarn, "%s failed (quarantined): %s", name, strings.Join(failures, "; "))
+	}
+}
+
+// runAttempt runs node test function once. Function runs in own goroutine when its failures
+// must not stop the caller (soft mode) or it may exceed NodeTimeout. Returned channel is closed
+// when the function returns
+func (cluster *KCluster) runAttempt(tt *T, f func(t *T)) <-chan struct{} {
+	done := make(chan struct{})
+	if NodeTimeout == 0 && !tt.soft {
+		defer close(done)
+		defer tt.runCleanups()
+		f(tt)
+		return done
+	}
+
+	ctx, cancel := tt.T.Context(), context.CancelFunc(func() {})
+	if NodeTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, NodeTimeout)
+	}
+	defer cancel()
+
+	tt.ctx = ctx
+	tt.async = true
+	go func() {
+		defer close(done)
+		defer tt.runCleanups()
+		f(tt)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		tn := tt.Node
+		tt.timeout(fmt.Sprintf("%s/%s timed out after %s: %s", tn.GroupName, tn.Name, NodeTimeout, cluster.nodeDiagnostic(tn.Name)))
+	}
+	return done
 }
 
 func (cluster *KCluster) RunTestGroupNodes(t *testing.T, label any, f func(t *T), filters ...NodeFilter) {
+	Track(t)
 	if TreeMode {
 		cluster.RunTestTreeGroupNodes(t, label, f, filters...)
 		return
 	}
 
 	for label, nodes := range cluster.MapLabelNodes(label, filters...) {
-		Infof("%d Nodes for label '%s'", len(nodes), label)
+		if !soakGroupSelected(label) {
+			continue
+		}
+		logf(TestLogger(t), slog.LevelInfo, "%d Nodes for label '%s'", len(nodes), label)
 		if len(nodes) == 0 && !SkipOptional {
 			t.Errorf("no Nodes for label '%s'", label)
 			continue
 		}
 
 		for i, node := range nodes {
-			Debugf("Run %s/%s test", label, node.Name)
+			logf(TestLogger(t), slog.LevelDebug, "Run %s/%s test", label, node.Name)
 			tn := TestNode{Id: i, Name: node.Name, GroupName: label, Raw: &node}
-			f(&T{t, &tn})
+			cluster.runNode(t, t.Name(), &tn, f)
 		}
 		t.Logf("'%s' tests count: %d", label, len(nodes))
 	}
@@ -74,11 +459,16 @@ func (cluster *KCluster) RunTestGroupNodes(t *testing.T, label any, f func(t *T)
 
 func (cluster *KCluster) RunTestTreeGroupNodes(t *testing.T, label any, f func(t *T), filters ...NodeFilter) {
 	for label, nodes := range cluster.MapLabelNodes(label, filters...) {
+		if !soakGroupSelected(label) {
+			continue
+		}
 		t.Run(label, func(t *testing.T) {
 			if Parallel {
 				t.Parallel()
 			}
-			Infof("%d Nodes for label '%s'", len(nodes), label)
+			Track(t)
+			group := t.Name()
+			logf(TestLogger(t), slog.LevelInfo, "%d Nodes for label '%s'", len(nodes), label)
 			if len(nodes) == 0 {
 				if SkipOptional {
 					t.SkipNow()
@@ -92,7 +482,7 @@ func (cluster *KCluster) RunTestTreeGroupNodes(t *testing.T, label any, f func(t
 						t.Parallel()
 					}
 					tn := TestNode{Id: i, Name: node.Name, GroupName: label, Raw: &node}
-					f(&T{t, &tn})
+					cluster.runNode(t, group, &tn, f)
 				})
 			}
 		})
@@ -17,8 +17,10 @@ limitations under the License.
 package integration
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	virt "github.com/deckhouse/virtualization/api/core/v1alpha2"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -304,7 +306,7 @@ func (cluster *KCluster) ListVD(filters ...VdFilter) ([]vdType, error) {
 
 	err := cluster.controllerRuntimeClient.List(cluster.ctx, &vds, opts)
 	if err != nil {
-		Debugf("Can't get VDs: %s", err.Error())
+		cluster.Debugf("Can't get VDs: %s", err.Error())
 		return nil, err
 	}
 
@@ -346,7 +348,7 @@ func (cluster *KCluster) CreateVD(nsName string, name string, storageClass strin
 func (cluster *KCluster) UpdateVd(vd *vdType) error {
 	err := cluster.controllerRuntimeClient.Update(cluster.ctx, vd)
 	if err != nil {
-		Errorf("Can't update VD %s", vd.Name)
+		cluster.Errorf("Can't update VD %s", vd.Name)
 		return err
 	}
 
@@ -374,7 +376,7 @@ func (cluster *KCluster) DeleteVdAndWait(filters ...VdFilter) error {
 		return err
 	}
 
-	return RetrySec(15, func() error {
+	return WaitFor(cluster.ctx, 15*time.Second, func(context.Context) error {
 		vds, err := cluster.ListVD(filters...)
 		if err != nil {
 			return err
@@ -382,7 +384,7 @@ func (cluster *KCluster) DeleteVdAndWait(filters ...VdFilter) error {
 		if len(vds) > 0 {
 			return fmt.Errorf("VDs not deleted: %d", len(vds))
 		}
-		Debugf("VDs deleted")
+		cluster.Debugf("VDs deleted")
 		return nil
 	})
 }
@@ -474,7 +476,7 @@ func (cluster *KCluster) ListVMBD(filters ...VmBdFilter) ([]virt.VirtualMachineB
 }
 
 func (cluster *KCluster) WaitVmbdAttached(filters ...VmBdFilter) error {
-	return RetrySec(25, func() error {
+	return WaitFor(cluster.ctx, 25*time.Second, func(context.Context) error {
 		filters = append(filters, VmBdFilter{Phase: "!Attached"})
 		vmbds, err := cluster.ListVMBD(filters...)
 		if err != nil {
@@ -483,7 +485,7 @@ func (cluster *KCluster) WaitVmbdAttached(filters ...VmBdFilter) error {
 		if len(vmbds) > 0 {
 			return fmt.Errorf("VMBDs not Attached: %d (%s, ...)", len(vmbds), vmbds[0].Name)
 		}
-		Debugf("VMBDs attached")
+		cluster.Debugf("VMBDs attached")
 		return nil
 	})
 }
@@ -560,7 +562,7 @@ func (cluster *KCluster) DeleteVmbdAndWait(filters ...VmBdFilter) error {
 		return err
 	}
 
-	return RetrySec(15, func() error {
+	return WaitFor(cluster.ctx, 15*time.Second, func(context.Context) error {
 		vmbds, err := cluster.ListVMBD(filters...)
 		if err != nil {
 			return err
@@ -568,7 +570,7 @@ func (cluster *KCluster) DeleteVmbdAndWait(filters ...VmBdFilter) error {
 		if len(vmbds) > 0 {
 			return fmt.Errorf("VMBDs not deleted: %d", len(vmbds))
 		}
-		Debugf("VMBDs deleted")
+		cluster.Debugf("VMBDs deleted")
 		return nil
 	})
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package integration
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -31,9 +32,9 @@ import (
 const (
 	DhDevImg                  = "dev-registry.deckhouse.io/sys/deckhouse-oss/install:main"
 	DhCeImg                   = "registry.deckhouse.io/deckhouse/ce/install:stable"
-	DhInstallCommand          = "docker run --network=host -t -v '/home/user/config.yml:/config.yml' -v '/home/user/:/tmp/' %s dhctl bootstrap --ssh-user=user --ssh-host=%s --ssh-agent-private-keys=/tmp/id_rsa_test --config=/config.yml"
-	DhResourcesInstallCommand = "docker run --network=host -t -v '/home/user/resources.yml:/resources.yml' -v '/home/user/:/tmp/' %s dhctl bootstrap-phase create-resources --ssh-user=user --ssh-host=%s --ssh-agent-private-keys=/tmp/id_rsa_test --resources=/resources.yml"
-	RegistryLoginCmd          = "sudo docker login -u license-token -p %s dev-registry.deckhouse.io"
+	DhInstallCommand          = "docker run --network=host -t -v '/home/user/config.yml:/config.yml' -v '/home/user/:/tmp/' %s dhctl bootstrap --ssh-user=user --ssh-host=%s --ssh-agent-private-keys=/tmp/%s --config=/config.yml"
+	DhResourcesInstallCommand = "docker run --network=host -t -v '/home/user/resources.yml:/resources.yml' -v '/home/user/:/tmp/' %s dhctl bootstrap-phase create-resources --ssh-user=user --ssh-host=%s --ssh-agent-private-keys=/tmp/%s --resources=/resources.yml"
+	RegistryLoginCmd          = "docker login -u license-token --password-stdin dev-registry.deckhouse.io"
 
 	NodesReadyTimeout = 600 // Timeout for nodes to be ready (in seconds) - 10*60
 )
@@ -49,24 +50,28 @@ type VmConfig struct {
 }
 
 func vmCreate(cluster *KCluster, vms []VmConfig, nsName string) {
-	sshPubKeyString := CheckAndGetSSHKeys(KubePath, PrivKeyName, PubKeyName)
+	sshPubKeyString := CheckAndGetSSHKeys(KubePath, NestedKeyType, PrivKeyName, PubKeyName)
 
 	for _, vmItem := range vms {
 		err := cluster.CreateVM(nsName, vmItem.name, vmItem.ip, vmItem.cpu, vmItem.ram, HvStorageClass, vmItem.image, sshPubKeyString, vmItem.diskSize)
 		if err != nil {
-			Fatalf("creating: %w", err)
+			Fatalf("creating: %s", err.Error())
 		}
 	}
 }
 
 func vmSync(cluster *KCluster, vms []VmConfig, nsName string) {
 	vmList, err := cluster.ListVM(VmFilter{NameSpace: nsName})
+	fresh := map[string]bool{}
 	if err != nil || len(vmList) < len(vms) {
+		for _, cfg := range vms {
+			fresh[cfg.name] = !slices.ContainsFunc(vmList, func(vm vmType) bool { return vm.Name == cfg.name })
+		}
 		Infof("Creating VMs")
 		vmCreate(cluster, vms, nsName)
 	}
 
-	if err := RetrySec(8*60, func() error {
+	if err := WaitFor(cluster.ctx, 8*time.Minute, func(context.Context) error {
 		vmList, err = cluster.ListVM(VmFilter{NameSpace: nsName, Phase: string(virt.MachineRunning)})
 		if err != nil {
 			return err
@@ -77,7 +82,7 @@ func vmSync(cluster *KCluster, vms []VmConfig, nsName string) {
 		Debugf("VMs are ready: %d", len(vmList))
 		return nil
 	}); err != nil {
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 
 	for _, vm := range vmList {
@@ -88,18 +93,35 @@ func vmSync(cluster *KCluster, vms []VmConfig, nsName string) {
 			}
 		}
 	}
+
+	if HostKeyMode == HostKeyInsecure {
+		return
+	}
+	for _, cfg := range vms {
+		if !fresh[cfg.name] {
+			continue
+		}
+		if err := HvSshClient.pinVmHostKey(NestedSshUser, cfg.ip+":22", NestedSshKey); err != nil {
+			Fatalf("VM %s host key: %s", cfg.name, err.Error())
+		}
+	}
 }
 
 func mkTemplateFile(tplPath string, resPath string, a ...any) {
 	template, err := os.ReadFile(tplPath)
 	if err != nil {
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 
+	// parallel runs (shards) render the same file, so replace it atomically
 	renderedTemplateString := fmt.Sprintf(string(template), a...)
-	err = os.WriteFile(resPath, []byte(renderedTemplateString), 0644)
+	tmpPath := fmt.Sprintf("%s.%d", resPath, os.Getpid())
+	err = os.WriteFile(tmpPath, []byte(renderedTemplateString), 0644)
+	if err == nil {
+		err = os.Rename(tmpPath, resPath)
+	}
 	if err != nil {
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 }
 
@@ -112,46 +134,48 @@ func mkResources() {
 }
 
 func installVmDh(client sshClient, masterIp string) error {
-	if err := ensureDockerInstalled(client); err != nil {
+	ctx := context.Background()
+	if err := ensureDockerInstalled(ctx, client); err != nil {
 		return err
 	}
 
-	dhImg, err := authenticateRegistry(client)
+	dhImg, err := authenticateRegistry(ctx, client)
 	if err != nil {
 		return err
 	}
 
-	if err := bootstrapConfig(client, dhImg, masterIp); err != nil {
+	if err := bootstrapConfig(ctx, client, dhImg, masterIp); err != nil {
 		return err
 	}
 
-	if err := bootstrapResources(client, dhImg, masterIp); err != nil {
+	if err := bootstrapResources(ctx, client, dhImg, masterIp); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func ensureDockerInstalled(client sshClient) error {
+func ensureDockerInstalled(ctx context.Context, client sshClient) error {
 	// Check if docker is already installed
-	out, err := client.Exec("docker --version")
-	if err == nil && strings.Contains(out, "Docker version") {
-		Debugf("Docker is already installed: %s", strings.TrimSpace(out))
+	res, err := client.Run(ctx, "docker --version", ExecOpts{Timeout: time.Minute})
+	if err == nil && strings.Contains(res.Stdout, "Docker version") {
+		Debugf("Docker is already installed: %s", strings.TrimSpace(res.Stdout))
 		return nil
 	}
 
 	Infof("Installing Docker")
 
 	// Retry apt installation to handle lock conflicts
-	if err := RetrySec(120, func() error {
-		out, err := client.Exec("sudo apt update && sudo apt install -y docker.io")
+	if err := WaitFor(ctx, 120*time.Second, func(ctx context.Context) error {
+		opts := ExecOpts{Sudo: true, Timeout: 10 * time.Minute, Env: map[string]string{"DEBIAN_FRONTEND": "noninteractive"}}
+		res, err := client.Run(ctx, "apt update && apt install -y docker.io", opts)
 		if err != nil {
 			// Check if it's an apt lock error
-			if strings.Contains(out, "Could not get lock") || strings.Contains(out, "Unable to lock directory") {
-				return fmt.Errorf("apt is locked, retrying: %w\nOutput: %s", err, out)
+			if res.ExitCode > 0 && (strings.Contains(res.Stderr, "Could not get lock") || strings.Contains(res.Stderr, "Unable to lock directory")) {
+				return fmt.Errorf("apt is locked, retrying: %w\nOutput: %s", err, res.Output())
 			}
 			// For other errors, return immediately
-			return fmt.Errorf("failed to install docker.io: %w\nOutput: %s", err, out)
+			return fmt.Errorf("failed to install docker.io: %w\nOutput: %s", err, res.Output())
 		}
 		return nil
 	}); err != nil {
@@ -159,15 +183,15 @@ func ensureDockerInstalled(client sshClient) error {
 	}
 
 	// Verify docker installation
-	out, err = client.Exec("docker --version")
+	res, err = client.Run(ctx, "docker --version", ExecOpts{Timeout: time.Minute})
 	if err != nil {
-		return fmt.Errorf("docker installation completed but docker command failed: %w\nOutput: %s", err, out)
+		return fmt.Errorf("docker installation completed but docker command failed: %w\nOutput: %s", err, res.Output())
 	}
-	if !strings.Contains(out, "Docker version") {
-		return fmt.Errorf("docker installation verification failed: expected 'Docker version' in output, got: %s", out)
+	if !strings.Contains(res.Stdout, "Docker version") {
+		return fmt.Errorf("docker installation verification failed: expected 'Docker version' in output, got: %s", res.Output())
 	}
 
-	Infof("Docker successfully installed: %s", strings.TrimSpace(out))
+	Infof("Docker successfully installed: %s", strings.TrimSpace(res.Stdout))
 	return nil
 }
 
@@ -199,37 +223,42 @@ func ensureDockerRemoved(bootstrapIp string) error {
 	return nil
 }
 
-func authenticateRegistry(client sshClient) (string, error) {
+func authenticateRegistry(ctx context.Context, client sshClient) (string, error) {
 	dhImg := DhCeImg
 	if licenseKey != "" {
-		_ = client.ExecFatal(fmt.Sprintf(RegistryLoginCmd, licenseKey))
+		// license key is passed via stdin to keep it out of command audit
+		opts := ExecOpts{Sudo: true, Stdin: strings.NewReader(licenseKey), Timeout: time.Minute}
+		if res, err := client.Run(ctx, RegistryLoginCmd, opts); err != nil {
+			return "", fmt.Errorf("registry login: %w: %s", err, res.Output())
+		}
 		dhImg = DhDevImg
 	}
 	return dhImg, nil
 }
 
-func bootstrapConfig(client sshClient, dhImg, masterIp string) error {
-	Infof("Master: running dhctl bootstrap phase 'config'")
-	cmd := fmt.Sprintf(DhInstallCommand, dhImg, masterIp)
-	Debugf(cmd)
-	cmd = "sudo -i timeout 900 " + cmd + " > /tmp/bootstrap.out || {(tail -30 /tmp/bootstrap.out; exit 124)}"
-	if out, err := client.Exec(cmd); err != nil {
-		Critf(out)
-		return fmt.Errorf("dhctl bootstrap config error: %w", err)
+// runBootstrap runs dhctl command as root, its output is streamed to debug log
+func runBootstrap(ctx context.Context, client sshClient, phase, cmd string, timeout time.Duration) error {
+	Infof("Master: running dhctl bootstrap phase '%s'", phase)
+	Debugf("%s", cmd)
+	out := &lineWriter{log: func(line string) { Debugf("dhctl: %s", line) }}
+	defer out.Flush()
+
+	res, err := client.Run(ctx, cmd, ExecOpts{Sudo: true, Timeout: timeout, Stdout: out, Stderr: out})
+	if err != nil {
+		Critf("%s", tailLines(res.Output(), 30))
+		return fmt.Errorf("dhctl bootstrap %s error (exit code %d): %w", phase, res.ExitCode, err)
 	}
 	return nil
 }
 
-func bootstrapResources(client sshClient, dhImg, masterIp string) error {
-	Infof("Master: running dhctl bootstrap phase 'resources'")
-	cmd := fmt.Sprintf(DhResourcesInstallCommand, dhImg, masterIp)
-	Debugf(cmd)
-	cmd = "sudo -i timeout 600 " + cmd + " > /tmp/bootstrap.out || {(tail -30 /tmp/bootstrap.out; exit 124)}"
-	if out, err := client.Exec(cmd); err != nil {
-		Critf(out)
-		return fmt.Errorf("dhctl bootstrap resources error: %w", err)
-	}
-	return nil
+func bootstrapConfig(ctx context.Context, client sshClient, dhImg, masterIp string) error {
+	cmd := fmt.Sprintf(DhInstallCommand, dhImg, masterIp, PrivKeyName)
+	return runBootstrap(ctx, client, "config", cmd, 15*time.Minute)
+}
+
+func bootstrapResources(ctx context.Context, client sshClient, dhImg, masterIp string) error {
+	cmd := fmt.Sprintf(DhResourcesInstallCommand, dhImg, masterIp, PrivKeyName)
+	return runBootstrap(ctx, client, "resources", cmd, 10*time.Minute)
 }
 
 // TODO - check if Deckhouse is installed by checking if pods are running in d8-system namespace
@@ -240,31 +269,21 @@ func checkDeckhouseInstalled() bool {
 
 // TODO - remove unused parameter bootstrapVm
 func uploadBootstrapFiles(client sshClient, bootstrapVm *VmConfig) error {
-	for _, f := range []string{ConfigName, ResourcesName} {
-		err := client.Upload(filepath.Join(DataPath, f), filepath.Join(RemoteAppPath, f))
-		if err != nil {
-			return err
-		}
-	}
-
-	for _, f := range []string{PrivKeyName} {
-		err := client.Upload(filepath.Join(KubePath, f), filepath.Join(RemoteAppPath, f))
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return client.UploadFiles(RemoteAppPath,
+		filepath.Join(DataPath, ConfigName),
+		filepath.Join(DataPath, ResourcesName),
+		filepath.Join(KubePath, PrivKeyName),
+	)
 }
 
 // TODO - remove unused parameter masterVm
 func getKubeconfig(masterVm *VmConfig) error {
-	out := NestedSshClient.ExecFatal("sudo cat /root/.kube/config")
-	out = strings.ReplaceAll(out, "127.0.0.1:6445", "127.0.0.1:"+NestedK8sPort)
-	err := os.WriteFile(NestedClusterKubeConfig, []byte(out), 0600)
+	res, err := NestedSshClient.Run(context.Background(), "cat /root/.kube/config", ExecOpts{Sudo: true, Timeout: time.Minute, Redact: true})
 	if err != nil {
-		return err
+		return fmt.Errorf("read kubeconfig: %w: %s", err, res.Stderr)
 	}
-	return nil
+	out := strings.ReplaceAll(res.Stdout, "127.0.0.1:6445", "127.0.0.1:"+NestedLocalPort)
+	return os.WriteFile(NestedClusterKubeConfig, []byte(out), 0600)
 }
 
 // Installs Deckhouse on virtual machines
@@ -283,16 +302,16 @@ func initVmD8(masterVm, bootstrapVm *VmConfig, vmKeyPath string) {
 		defer client.Close()
 
 		if err := uploadBootstrapFiles(client, bootstrapVm); err != nil {
-			Fatalf("failed to upload bootstrap files: %w", err)
+			Fatalf("failed to upload bootstrap files: %s", err.Error())
 		}
 
 		if err := installVmDh(client, masterVm.ip); err != nil {
-			Fatalf("failed to install Deckhouse on the test cluster: %w", err)
+			Fatalf("failed to install Deckhouse on the test cluster: %s", err.Error())
 		}
 	}
 
 	if err := getKubeconfig(masterVm); err != nil {
-		Fatalf("failed to get kubeconfig: %w", err)
+		Fatalf("failed to get kubeconfig: %s", err.Error())
 	}
 }
 
@@ -314,11 +333,14 @@ func cleanUpNs(cluster *KCluster) {
 
 func setupHypervisorConnection() (*KCluster, error) {
 	HvSshClient = GetSshClient(HvSshUser, HvHost+":22", HvSshKey)
-	go HvSshClient.NewTunnel("127.0.0.1:"+HvK8sPort, "127.0.0.1:"+HvK8sPort)
+	HvTunnel = startApiTunnel(HvSshClient, &HvLocalPort, "127.0.0.1:"+HvK8sPort)
+	if err := HvTunnel.Ready(context.Background(), time.Minute); err != nil {
+		return nil, err
+	}
 
 	cluster, err := InitKCluster(HypervisorKubeConfig, "")
 	if err != nil {
-		Critf("Kubeclient '%s' problem: %w", HypervisorKubeConfig, err)
+		Critf("Kubeclient '%s' problem: %s", HypervisorKubeConfig, err.Error())
 		return nil, err
 	}
 
@@ -331,17 +353,19 @@ func prepareNamespace(cluster *KCluster, nsName string) error {
 		Debugf("Deleting old namespace %s", nsName)
 		// TODO add NS exists check
 		if err := cluster.DeleteNsAndWait(NsFilter{Name: nsName}); err != nil {
-			Fatalf("failed to delete old namespace %s: %w", nsName, err)
+			Fatalf("failed to delete old namespace %s: %s", nsName, err.Error())
 			return err
 		}
 	case "free tmp":
 		cleanUpNs(cluster)
 	}
 
-	GenerateRSAKeys(NestedSshKey, filepath.Join(KubePath, PubKeyName))
+	if err := GenerateSSHKeys(NestedKeyType, NestedSshKey, filepath.Join(KubePath, PubKeyName)); err != nil {
+		return err
+	}
 
 	if err := cluster.CreateNs(nsName); err != nil {
-		Fatalf("failed to create namespace %s: %w", nsName, err)
+		Fatalf("failed to create namespace %s: %s", nsName, err.Error())
 		return err
 	}
 
@@ -377,7 +401,7 @@ func identifyVmRoles(vms []VmConfig) ([]*VmConfig, []*VmConfig, *VmConfig, error
 // ensureNodesReady checks if all nodes are ready after being added
 func ensureNodesReady(cluster *KCluster, expectedNodeCount int) error {
 	Infof("Check if nodes are ready")
-	return RetrySec(NodesReadyTimeout, func() error {
+	return WaitFor(cluster.ctx, time.Duration(NodesReadyTimeout)*time.Second, func(context.Context) error {
 		nodes, err := cluster.ListNode()
 		if err != nil {
 			return fmt.Errorf("failed to list nodes: %w", err)
@@ -440,29 +464,32 @@ func ClusterCreate() {
 
 	cluster, err := setupHypervisorConnection()
 	if err != nil {
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 
 	if err := prepareNamespace(cluster, nsName); err != nil {
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 
 	vmSync(cluster, VmCluster, nsName)
 
 	vmMasters, vmWorkers, vmBootstrap, err := identifyVmRoles(VmCluster)
 	if err != nil {
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 
 	NestedSshClient = HvSshClient.GetFwdClient(NestedSshUser, vmMasters[0].ip+":22", NestedSshKey)
 
+	NestedTunnel = startApiTunnel(NestedSshClient, &NestedLocalPort, vmMasters[0].ip+":"+NestedK8sPort)
 	initVmD8(vmMasters[0], vmBootstrap, NestedSshKey)
-	go NestedSshClient.NewTunnel("127.0.0.1:"+NestedK8sPort, vmMasters[0].ip+":"+NestedK8sPort)
+	if err := NestedTunnel.Ready(context.Background(), time.Minute); err != nil {
+		Fatalf("%s", err.Error())
+	}
 
 	cluster, err = InitKCluster("", "")
 	if err != nil {
 		Critf("Kubeclient '%s' problem", NestedClusterKubeConfig)
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 
 	nodeIps := make([]string, len(vmWorkers))
@@ -477,7 +504,7 @@ func ClusterCreate() {
 	}
 
 	if err := cluster.AddStaticNodes("e2e", "user", nodeIps); err != nil {
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 
 	// Wait for nodes to be ready before checking module readiness
@@ -486,6 +513,6 @@ func ClusterCreate() {
 	}
 
 	if err := ensureClusterReady(cluster); err != nil {
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 }
@@ -17,12 +17,34 @@ limitations under the License.
 package integration
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"os"
 	"runtime"
+	"strings"
+	"sync"
+	"testing"
 	"time"
 )
 
+const (
+	LevelCrit  = slog.Level(12)
+	LevelFatal = slog.Level(16)
+
+	fileOnlyKey = "fileOnly"
+)
+
+var (
+	RunID = fmt.Sprintf("%s-%s", startTime.Format("20060102-150405"), RandString(4))
+
+	termOut     = &termHandler{mx: &sync.Mutex{}, w: os.Stderr}
+	logMx       sync.RWMutex
+	logHandlers = []slog.Handler{termOut}
+	logger      = slog.New(&fanoutHandler{}).With("run", RunID)
+)
+
 func getPrefix() string {
 	return "    "
 }
@@ -41,63 +63,314 @@ func getDuration() string {
 	return fmt.Sprintf("%c%c%c", rune(char+i%1000/100), rune(char+i%100/10), rune(char+i%10))
 }
 
-func Filelogf(format string, v ...any) {
-	if fileLogger == nil {
-		return
+/*  Handlers  */
+
+// logSink receives terminal lines of test loggers instead of stderr
+type logSink interface {
+	logLine(line string)
+}
+
+// tbSink logs through testing.T, so go test groups output per (sub)test
+type tbSink struct {
+	tb testing.TB
+}
+
+func (s tbSink) logLine(line string) {
+	s.tb.Log(line)
+}
+
+// fanoutHandler passes records to all registered handlers (terminal, -logfile)
+type fanoutHandler struct {
+	attrs []slog.Attr
+	group string
+	sink  logSink
+}
+
+func (h *fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	logMx.RLock()
+	defer logMx.RUnlock()
+	for _, handler := range logHandlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
 	}
-	fileLogger.Printf(format, v...)
+	return false
 }
 
-func Debugf(format string, v ...any) {
-	Filelogf("🦗"+format, v...)
-	if !*debugFlag {
-		return
+func (h *fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	if len(h.attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(h.attrs...)
 	}
-	log.SetFlags(0)
-	//log.SetFlags(log.Lmicroseconds)
-	log.SetPrefix(getPrefix())
-	log.Printf("\033[32m🦗\033[2m"+getDuration()+" \033[0m"+format+"\033[0m", v...)
+
+	logMx.RLock()
+	defer logMx.RUnlock()
+	for _, handler := range logHandlers {
+		if !handler.Enabled(ctx, r.Level) {
+			continue
+		}
+		if handler == termOut && h.sink != nil {
+			if line, ok := termLine(r); ok {
+				h.sink.logLine(line)
+			}
+			continue
+		}
+		_ = handler.Handle(ctx, r)
+	}
+	return nil
 }
 
-func Infof(format string, v ...any) {
-	Filelogf("✎ "+format, v...)
-	if !*verboseFlag && !*debugFlag {
+func (h *fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if h.group != "" {
+		for i := range attrs {
+			attrs[i].Key = h.group + "." + attrs[i].Key
+		}
+	}
+	return &fanoutHandler{attrs: append(append([]slog.Attr{}, h.attrs...), attrs...), group: h.group, sink: h.sink}
+}
+
+func (h *fanoutHandler) WithGroup(name string) slog.Handler {
+	if h.group != "" {
+		name = h.group + "." + name
+	}
+	return &fanoutHandler{attrs: h.attrs, group: name, sink: h.sink}
+}
+
+// termHandler writes coloured messages to terminal, attributes except run ID are shown dimmed
+type termHandler struct {
+	mx *sync.Mutex
+	w  io.Writer
+}
+
+func (h *termHandler) Enabled(_ context.Context, level slog.Level) bool {
+	switch {
+	case level < slog.LevelInfo:
+		return *debugFlag
+	case level < slog.LevelWarn:
+		return *verboseFlag || *debugFlag
+	}
+	return true
+}
+
+// termLine formats record as coloured line. Records marked file only are not shown
+func termLine(r slog.Record) (string, bool) {
+	attrs := []string{}
+	fileOnly := false
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case "run":
+		case fileOnlyKey:
+			fileOnly = true
+		default:
+			attrs = append(attrs, a.String())
+		}
+		return true
+	})
+	if fileOnly {
+		return "", false
+	}
+
+	var line string
+	switch {
+	case r.Level < slog.LevelInfo:
+		line = "\033[32m🦗\033[2m" + getDuration() + " \033[0m" + r.Message + "\033[0m"
+	case r.Level < slog.LevelWarn:
+		line = "\033[2m✎ " + getDuration() + " \033[2m" + r.Message + "\033[0m"
+	case r.Level < slog.LevelError:
+		line = "\033[93m🗈 \033[2m" + getDuration() + " \033[0;2m" + r.Message + "\033[0m"
+	case r.Level < LevelCrit:
+		line = "\033[91m❕\033[2m" + getDuration() + " \033[0m" + r.Message + "\033[0m"
+	case r.Level < LevelFatal:
+		line = "\033[91;5m⚠️ \033[2m" + getDuration() + " \033[0;91m" + r.Message + "\033[0m"
+	default:
+		line = "\033[31m🯀 " + getDuration() + " \033[0m" + r.Message
+	}
+	if len(attrs) > 0 {
+		line += " \033[2m" + strings.Join(attrs, " ") + "\033[0m"
+	}
+	return line, true
+}
+
+func (h *termHandler) Handle(_ context.Context, r slog.Record) error {
+	line, ok := termLine(r)
+	if !ok {
+		return nil
+	}
+	return h.writeLine(line)
+}
+
+func (h *termHandler) writeLine(line string) error {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	_, err := fmt.Fprintln(h.w, getPrefix()+line)
+	return err
+}
+
+func (h *termHandler) WithAttrs(_ []slog.Attr) slog.Handler { return h }
+
+func (h *termHandler) WithGroup(_ string) slog.Handler { return h }
+
+func levelName(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 || a.Key != slog.LevelKey {
+		return a
+	}
+	switch level := a.Value.Any().(slog.Level); {
+	case level >= LevelFatal:
+		a.Value = slog.StringValue("FATAL")
+	case level >= LevelCrit:
+		a.Value = slog.StringValue("CRIT")
+	}
+	return a
+}
+
+// setLogFile adds handler writing JSON lines to file (-logfile)
+func setLogFile(w io.Writer) {
+	logMx.Lock()
+	defer logMx.Unlock()
+	logHandlers = append(logHandlers, slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       slog.LevelDebug,
+		ReplaceAttr: levelName,
+	}))
+}
+
+/*  Loggers  */
+
+// Logger returns run logger. Records have run ID attribute
+func Logger() *slog.Logger {
+	return logger
+}
+
+// TestLogger returns logger with test name attribute. Terminal output goes through t.Log,
+// so it is grouped under the (sub)test in go test output
+func TestLogger(t testing.TB) *slog.Logger {
+	if tt, ok := t.(*T); ok {
+		return tt.Logger()
+	}
+	return withSink(logger.With("test", t.Name()), tbSink{t})
+}
+
+// withSink returns logger writing terminal lines to sink
+func withSink(l *slog.Logger, sink logSink) *slog.Logger {
+	h, ok := l.Handler().(*fanoutHandler)
+	if !ok {
+		return l
+	}
+	return slog.New(&fanoutHandler{attrs: h.attrs, group: h.group, sink: sink})
+}
+
+// Logger returns logger with cluster attribute
+func (cluster *KCluster) Logger() *slog.Logger {
+	if cluster.log == nil {
+		return logger
+	}
+	return cluster.log
+}
+
+// WithContext returns cluster for the test context: API calls are canceled with ctx and helpers
+// log through its logger (LoggerFrom), so their output is grouped under the (node) test
+//
+//	cluster := cluster.WithContext(t.Context())
+func (cluster *KCluster) WithContext(ctx context.Context) *KCluster {
+	c := *cluster
+	c.ctx = ctx
+	c.log = LoggerFrom(ctx).With("cluster", cluster.label)
+	return &c
+}
+
+func (cluster *KCluster) Debugf(format string, v ...any) {
+	logf(cluster.Logger(), slog.LevelDebug, format, v...)
+}
+
+func (cluster *KCluster) Infof(format string, v ...any) {
+	logf(cluster.Logger(), slog.LevelInfo, format, v...)
+}
+
+func (cluster *KCluster) Warnf(format string, v ...any) {
+	logf(cluster.Logger(), slog.LevelWarn, format, v...)
+}
+
+func (cluster *KCluster) Errorf(format string, v ...any) {
+	logf(cluster.Logger(), slog.LevelError, format, v...)
+}
+
+// Logger returns logger with test, node group, node and cluster attributes.
+// Terminal output goes through t.Log and to the node test report
+func (t *T) Logger() *slog.Logger {
+	t.logOnce.Do(func() {
+		if t.log == nil {
+			t.log = withSink(logger.With("test", t.T.Name()), t)
+		}
+	})
+	return t.log
+}
+
+func (t *T) Debugf(format string, v ...any) {
+	logf(t.Logger(), slog.LevelDebug, format, v...)
+}
+
+func (t *T) Infof(format string, v ...any) {
+	logf(t.Logger(), slog.LevelInfo, format, v...)
+}
+
+func (t *T) Warnf(format string, v ...any) {
+	logf(t.Logger(), slog.LevelWarn, format, v...)
+}
+
+type loggerKey struct{}
+
+// WithLogger returns context carrying logger for util helpers
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// LoggerFrom returns logger of context (node test context, WithLogger) or global logger
+func LoggerFrom(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return l
+		}
+	}
+	return logger
+}
+
+func logf(l *slog.Logger, level slog.Level, format string, v ...any) {
+	ctx := context.Background()
+	if !l.Enabled(ctx, level) {
 		return
 	}
-	log.SetFlags(0)
-	//log.SetFlags(log.Lmicroseconds)
-	log.SetPrefix(getPrefix())
-	log.Printf("\033[2m✎ "+getDuration()+" \033[2m"+format+"\033[0m", v...)
+	l.Log(ctx, level, fmt.Sprintf(format, v...))
+}
+
+// Filelogf writes message to -logfile only
+func Filelogf(format string, v ...any) {
+	logf(logger.With(fileOnlyKey, true), slog.LevelInfo, format, v...)
+}
+
+func Debugf(format string, v ...any) {
+	logf(logger, slog.LevelDebug, format, v...)
+}
+
+func Infof(format string, v ...any) {
+	logf(logger, slog.LevelInfo, format, v...)
 }
 
 func Warnf(format string, v ...any) {
-	Filelogf("🗈 "+format, v...)
-	log.SetFlags(0)
-	log.SetPrefix(getPrefix())
-	log.Printf("\033[93m🗈 \033[2m"+getDuration()+" \033[0;2m"+format+"\033[0m", v...)
+	logf(logger, slog.LevelWarn, format, v...)
 }
 
 func Warn(v ...any) {
-	Warnf(fmt.Sprint(v...))
+	Warnf("%s", fmt.Sprint(v...))
 }
 
 func Errorf(format string, v ...any) {
-	Filelogf("❕"+format, v...)
-	log.SetFlags(0)
-	log.SetPrefix(getPrefix())
-	log.Printf("\033[91m❕\033[2m"+getDuration()+" \033[0m"+format+"\033[0m", v...)
+	logf(logger, slog.LevelError, format, v...)
 }
 
 func Critf(format string, v ...any) {
-	Filelogf("⚠️ "+format, v...)
-	log.SetFlags(0)
-	log.SetPrefix(getPrefix())
-	log.Printf("\033[91;5m⚠️ \033[2m"+getDuration()+" \033[0;91m"+format+"\033[0m", v...)
+	logf(logger, LevelCrit, format, v...)
 }
 
 func Fatalf(format string, v ...any) {
-	Filelogf("🯀 "+format, v...)
-	log.SetFlags(0)
-	log.SetPrefix(getPrefix())
-	log.Fatalf("\033[31m🯀 "+getDuration()+" \033[0m"+format, v...)
+	logf(logger, LevelFatal, format, v...)
+	os.Exit(1)
 }
@@ -0,0 +1,299 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	snc "github.com/deckhouse/sds-node-configurator/api/v1alpha1"
+)
+
+const (
+	lsblkBin = "/opt/deckhouse/bin/lsblk"
+
+	BdMissingOnNode = "missing-on-node" // BlockDevice CR without node device
+	BdMissingCR     = "missing-cr"      // node device without BlockDevice CR
+
+	bdMinSize = 1000 * 1000 * 1000 // agent ignores devices less than 1G
+)
+
+// NodeBlockDevice is block device from `lsblk --json --bytes -O`
+type NodeBlockDevice struct {
+	Name        string            `json:"name"`
+	Kname       string            `json:"kname"`
+	Path        string            `json:"path"`
+	Type        string            `json:"type"`
+	FsType      string            `json:"fstype"`
+	Uuid        string            `json:"uuid"`
+	PartUuid    string            `json:"partuuid"`
+	Mountpoint  string            `json:"mountpoint"`
+	Mountpoints []string          `json:"mountpoints"`
+	Size        lsblkInt          `json:"size"`
+	Model       string            `json:"model"`
+	Serial      string            `json:"serial"`
+	Wwn         string            `json:"wwn"`
+	Rota        lsblkBool         `json:"rota"`
+	HotPlug     lsblkBool         `json:"hotplug"`
+	ReadOnly    lsblkBool         `json:"ro"`
+	PkName      string            `json:"pkname"`
+	Children    []NodeBlockDevice `json:"children"`
+}
+
+// lsblkInt is number, old lsblk versions report numbers as strings
+type lsblkInt int64
+
+func (v *lsblkInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" || s == "" {
+		*v = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	*v = lsblkInt(n)
+	return err
+}
+
+// lsblkBool is true/false, old lsblk versions report "1"/"0"
+type lsblkBool bool
+
+func (v *lsblkBool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	*v = lsblkBool(s == "true" || s == "1")
+	return nil
+}
+
+// Mounted is true if the device has any mountpoint
+func (d *NodeBlockDevice) Mounted() bool {
+	if d.Mountpoint != "" {
+		return true
+	}
+	return slices.ContainsFunc(d.Mountpoints, func(m string) bool { return m != "" })
+}
+
+func (d *NodeBlockDevice) hasChild(types ...string) bool {
+	return slices.ContainsFunc(d.Children, func(c NodeBlockDevice) bool { return slices.Contains(types, c.Type) })
+}
+
+// bdCandidate reports if sds-node-configurator should have BlockDevice for the device
+func (d *NodeBlockDevice) bdCandidate() bool {
+	switch {
+	case !slices.Contains([]string{"disk", "part", "mpath"}, d.Type):
+		return false
+	case d.FsType != "" && d.FsType != "LVM2_member":
+		return false
+	case int64(d.Size) < bdMinSize, strings.HasPrefix(d.Name, "drbd"):
+		return false
+	case d.hasChild("part", "mpath"):
+		return false
+	}
+	return true
+}
+
+// bdConsumable is expected Consumable flag of device BlockDevice
+func (d *NodeBlockDevice) bdConsumable() bool {
+	return d.FsType == "" && len(d.Children) == 0 && !d.Mounted() && !bool(d.ReadOnly)
+}
+
+// flatBlockDevices lists devices of lsblk tree, children after their parents
+func flatBlockDevices(devs []NodeBlockDevice) (resp []NodeBlockDevice) {
+	for _, d := range devs {
+		resp = append(resp, d)
+		resp = append(resp, flatBlockDevices(d.Children)...)
+	}
+	return
+}
+
+// NodeBlockDevices returns block device tree of the node
+func (cluster *KCluster) NodeBlockDevices(nName string) ([]NodeBlockDevice, error) {
+	stdout, stderr, err := cluster.ExecNode(nName, []string{"sudo", lsblkBin, "--json", "--bytes", "-O"})
+	if err != nil {
+		return nil, fmt.Errorf("%s lsblk: %w (%s)", nName, err, strings.TrimSpace(stderr))
+	}
+	var out struct {
+		BlockDevices []NodeBlockDevice `json:"blockdevices"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &out); err != nil {
+		return nil, fmt.Errorf("%s lsblk: %w", nName, err)
+	}
+	return out.BlockDevices, nil
+}
+
+/*  Consistency check  */
+
+// BdIssue is mismatch of BlockDevice CR and node device
+type BdIssue struct {
+	Node   string
+	BD     string // CR name, empty for BdMissingCR
+	Path   string
+	Field  string // size, model, type..., BdMissingOnNode, BdMissingCR
+	CR     string // value in BlockDevice status
+	Actual string // value on node
+}
+
+func (i BdIssue) String() string {
+	name := i.Path
+	if i.BD != "" {
+		name += " (" + i.BD + ")"
+	}
+	switch i.Field {
+	case BdMissingOnNode:
+		return fmt.Sprintf("%s %s: no device on node", i.Node, name)
+	case BdMissingCR:
+		return fmt.Sprintf("%s %s: no BlockDevice", i.Node, name)
+	}
+	return fmt.Sprintf("%s %s: %s %q != %q on node", i.Node, name, i.Field, i.CR, i.Actual)
+}
+
+// CompareBlockDevices matches BlockDevices of the node with lsblk tree by path, serial and WWN
+func CompareBlockDevices(nName string, bds []snc.BlockDevice, devs []NodeBlockDevice) (issues []BdIssue) {
+	flat := flatBlockDevices(devs)
+	matched := map[string]bool{}
+	for _, bd := range bds {
+		if bd.Status.NodeName != nName {
+			continue
+		}
+		st := bd.Status
+		dev := findBlockDevice(flat, st.Path, st.Serial, st.Wwn)
+		if dev == nil {
+			issues = append(issues, BdIssue{Node: nName, BD: bd.Name, Path: st.Path, Field: BdMissingOnNode})
+			continue
+		}
+		matched[dev.Path] = true
+
+		add := func(field, cr, actual string) {
+			if strings.TrimSpace(cr) != strings.TrimSpace(actual) {
+				issues = append(issues, BdIssue{Node: nName, BD: bd.Name, Path: st.Path, Field: field, CR: cr, Actual: actual})
+			}
+		}
+		add("path", st.Path, dev.Path)
+		add("serial", st.Serial, dev.Serial)
+		add("wwn", st.Wwn, dev.Wwn)
+		add("size", strconv.FormatInt(st.Size.Value(), 10), strconv.FormatInt(int64(dev.Size), 10))
+		add("model", st.Model, dev.Model)
+		add("type", st.Type, dev.Type)
+		add("fstype", st.FsType, dev.FsType)
+		if dev.PartUuid != "" || dev.Type == "part" {
+			add("partuuid", st.PartUUID, dev.PartUuid)
+		}
+		add("rota", strconv.FormatBool(st.Rota), strconv.FormatBool(bool(dev.Rota)))
+		add("hotplug", strconv.FormatBool(st.HotPlug), strconv.FormatBool(bool(dev.HotPlug)))
+		add("consumable", strconv.FormatBool(st.Consumable), strconv.FormatBool(dev.bdConsumable()))
+	}
+
+	for _, dev := range flat {
+		if dev.bdCandidate() && !matched[dev.Path] {
+			issues = append(issues, BdIssue{Node: nName, Path: dev.Path, Field: BdMissingCR, Actual: strconv.FormatInt(int64(dev.Size), 10)})
+		}
+	}
+	return
+}
+
+// findBlockDevice finds device by path, renamed device by serial and WWN
+func findBlockDevice(devs []NodeBlockDevice, path, serial, wwn string) *NodeBlockDevice {
+	for i := range devs {
+		if devs[i].Path == path {
+			return &devs[i]
+		}
+	}
+	if serial == "" && wwn == "" {
+		return nil
+	}
+	for i := range devs {
+		if devs[i].Serial == serial && devs[i].Wwn == wwn && devs[i].Type != "part" {
+			return &devs[i]
+		}
+	}
+	return nil
+}
+
+// BlockDeviceIssues compares BlockDevices of the node with lsblk on the node
+func (cluster *KCluster) BlockDeviceIssues(nName string) ([]BdIssue, error) {
+	bds, err := cluster.ListBD(BdFilter{Node: nName})
+	if err != nil {
+		return nil, err
+	}
+	devs, err := cluster.NodeBlockDevices(nName)
+	if err != nil {
+		return nil, err
+	}
+	return CompareBlockDevices(nName, bds, devs), nil
+}
+
+// CheckBlockDevices fails if BlockDevices of the node don't match the node devices
+//
+//	if err := cluster.CheckBlockDevices(nName); err != nil {
+//		t.Error(err.Error())
+//	}
+func (cluster *KCluster) CheckBlockDevices(nName string) error {
+	issues, err := cluster.BlockDeviceIssues(nName)
+	if err != nil {
+		return err
+	}
+	return bdIssuesError(issues)
+}
+
+// AuditBlockDevices checks BlockDevices of cluster nodes running sds-node-configurator.
+// Nodes without command access are reported as errors, other nodes are still checked
+func (cluster *KCluster) AuditBlockDevices() ([]BdIssue, error) {
+	pods, err := cluster.ListPod(AgentPods.Namespace, PodFilter{Name: AgentPods.Name})
+	if err != nil {
+		return nil, err
+	}
+	bds, err := cluster.ListBD()
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []BdIssue
+	var errs []error
+	agentNodes := map[string]bool{}
+	for _, pod := range pods {
+		nName := pod.Spec.NodeName
+		if nName == "" || agentNodes[nName] {
+			continue
+		}
+		agentNodes[nName] = true
+		devs, err := cluster.NodeBlockDevices(nName)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		issues = append(issues, CompareBlockDevices(nName, bds, devs)...)
+	}
+	for _, bd := range bds {
+		if !agentNodes[bd.Status.NodeName] {
+			issues = append(issues, BdIssue{Node: bd.Status.NodeName, BD: bd.Name, Path: bd.Status.Path, Field: BdMissingOnNode})
+		}
+	}
+	return issues, errors.Join(errs...)
+}
+
+func bdIssuesError(issues []BdIssue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	lines := make([]string, len(issues))
+	for i, issue := range issues {
+		lines[i] = issue.String()
+	}
+	return fmt.Errorf("BlockDevices don't match nodes:\n  %s", strings.Join(lines, "\n  "))
+}
@@ -0,0 +1,385 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubescheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	NodeExecPod   = "pod"   // nsenter through sds-node-configurator pod
+	NodeExecSsh   = "ssh"   // SSH to node InternalIP, through NestedSshClient if it is set
+	NodeExecDebug = "debug" // privileged host PID debug pod, created on demand
+)
+
+var (
+	NodeExecOrder     = []string{NodeExecPod, NodeExecSsh} // backend preference (-nodeexec), debug pod is opt-in
+	DebugPodImage     = "alpine:3.20"                      // image with nsenter (-debugimage)
+	DebugPodNamespace = "default"
+	DebugPodTimeout   = 2 * time.Minute // wait for debug pod Running
+	PodExecTimeout    = 10 * time.Minute
+	SshAvailableTTL   = time.Minute // successful ssh executor check is reused
+
+	// nsenter namespaces of node commands (pod and debug pod backends), both pods use host network
+	nodeNamespaces = []string{"-m", "-u", "-i", "-p", "-t", "1", "--"}
+
+	debugPods   = map[string]*debugPod{} // by cluster label and node
+	debugPodsMx sync.Mutex
+
+	sshAvailable   = map[string]time.Time{} // by cluster label and node, time of successful check
+	sshAvailableMx sync.Mutex
+)
+
+// NodeExecutor runs commands on cluster node as root
+type NodeExecutor interface {
+	Name() string
+	// Available returns error if backend can't run commands on the node now
+	Available(nName string) error
+	Exec(nName string, cmd []string) (stdout, stderr string, err error)
+}
+
+// SetNodeExecutor sets the only backend of node commands of the cluster instead of NodeExecOrder
+func (cluster *KCluster) SetNodeExecutor(e NodeExecutor) {
+	cluster.nodeExec = e
+}
+
+// NodeExecutor returns backend set by SetNodeExecutor or first available one in NodeExecOrder
+func (cluster *KCluster) NodeExecutor(nName string) (NodeExecutor, error) {
+	if cluster.nodeExec != nil {
+		return cluster.nodeExec, nil
+	}
+	var errs []string
+	for _, name := range NodeExecOrder {
+		e, err := cluster.nodeExecutor(name)
+		if err == nil {
+			err = e.Available(nName)
+		}
+		if err == nil {
+			return e, nil
+		}
+		errs = append(errs, name+": "+err.Error())
+	}
+	return nil, fmt.Errorf("no executor for node %s (%s)", nName, strings.Join(errs, "; "))
+}
+
+func (cluster *KCluster) nodeExecutor(name string) (NodeExecutor, error) {
+	switch name {
+	case NodeExecPod:
+		return podExecutor{cluster}, nil
+	case NodeExecSsh:
+		return sshExecutor{cluster}, nil
+	case NodeExecDebug:
+		return debugExecutor{cluster}, nil
+	}
+	return nil, fmt.Errorf("unknown node executor %s", name)
+}
+
+// ExecNodeWith runs cmd on node with the backend (pod, ssh, debug)
+func (cluster *KCluster) ExecNodeWith(backend, nName string, cmd []string) (string, string, error) {
+	e, err := cluster.nodeExecutor(backend)
+	if err != nil {
+		return "", "", err
+	}
+	if err := e.Available(nName); err != nil {
+		return "", "", fmt.Errorf("%s executor on %s: %w", backend, nName, err)
+	}
+	return e.Exec(nName, cmd)
+}
+
+// podExec runs cmd in pod container through API server
+func (cluster *KCluster) podExec(nsName, pName, container, nName, transport string, cmd, podCmd []string) (string, string, error) {
+	req := cluster.goClient.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pName).
+		Namespace(nsName).
+		SubResource("exec").
+		Timeout(5 * time.Second)
+	req = req.VersionedParams(&coreapi.PodExecOptions{
+		Container: container,
+		Command:   podCmd,
+		Stdin:     false,
+		Stdout:    true,
+		Stderr:    true,
+	}, kubescheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(cluster.restCfg, "POST", req.URL())
+	//NewSPDYExecutor connects to the provided server and upgrades the connection to multiplexed bidirectional streams
+	if err != nil {
+		return "", "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	streamOps := remotecommand.StreamOptions{
+		Stdout: &stdout,
+		Stderr: &stderr,
+	}
+	rec := &CommandRecord{Node: nName, Transport: transport, Command: strings.Join(cmd, " "), Args: cmd, Root: true, Start: time.Now(), cluster: cluster.label}
+	ctx, cancel := context.WithTimeout(cluster.ctx, PodExecTimeout)
+	defer cancel()
+	err = exec.StreamWithContext(ctx, streamOps)
+	rec.Stdout, rec.Stderr = stdout.String(), stderr.String()
+	auditCommand(rec, err)
+	if err != nil {
+		return stdout.String(), stderr.String(), fmt.Errorf("Exec %s %v: %w", nName, cmd, err)
+	}
+
+	return stdout.String(), stderr.String(), nil
+}
+
+func podRunning(pod *coreapi.Pod) bool {
+	if pod.Status.Phase != coreapi.PodRunning || pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, st := range pod.Status.ContainerStatuses {
+		if st.State.Running == nil {
+			return false
+		}
+	}
+	return true
+}
+
+/*  Module pod  */
+
+type podExecutor struct {
+	cluster *KCluster
+}
+
+func (e podExecutor) Name() string { return NodeExecPod }
+
+func (e podExecutor) pod(nName string) (*coreapi.Pod, error) {
+	pods, err := e.cluster.ListPod("d8-sds-node-configurator", PodFilter{Name: "%sds-node-configurator-%", Node: nName})
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods {
+		if podRunning(&pods[i]) {
+			return &pods[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no running sds-node-configurator for node %s", nName)
+}
+
+func (e podExecutor) Available(nName string) error {
+	_, err := e.pod(nName)
+	return err
+}
+
+func (e podExecutor) Exec(nName string, cmd []string) (string, string, error) {
+	pod, err := e.pod(nName)
+	if err != nil {
+		return "", "", err
+	}
+	nsCmd := append(append([]string{"/opt/deckhouse/sds/bin/nsenter.static"}, nodeNamespaces...), cmd...)
+	return e.cluster.podExec(pod.Namespace, pod.Name, pod.Spec.Containers[0].Name, nName, TransportPodExec, cmd, nsCmd)
+}
+
+/*  SSH  */
+
+type sshExecutor struct {
+	cluster *KCluster
+}
+
+func (e sshExecutor) Name() string { return NodeExecSsh }
+
+// Available checks node SSH connection (pooled), success is reused for SshAvailableTTL
+func (e sshExecutor) Available(nName string) error {
+	if NestedSshKey == "" {
+		return errors.New("no ssh key")
+	}
+	key := e.cluster.label + "/" + nName
+	sshAvailableMx.Lock()
+	checked, ok := sshAvailable[key]
+	sshAvailableMx.Unlock()
+	if ok && time.Since(checked) < SshAvailableTTL {
+		return nil
+	}
+
+	client, err := e.cluster.nodeSshClient(nName)
+	if err != nil {
+		return err
+	}
+	sshAvailableMx.Lock()
+	sshAvailable[key] = time.Now()
+	sshAvailableMx.Unlock()
+	return client.Close()
+}
+
+func (e sshExecutor) Exec(nName string, cmd []string) (string, string, error) {
+	client, err := e.cluster.nodeSshClient(nName)
+	if err != nil {
+		return "", "", err
+	}
+	defer client.Close()
+	quoted := make([]string, len(cmd))
+	for i, arg := range cmd {
+		quoted[i] = shellQuote(arg)
+	}
+	res, err := client.Run(e.cluster.ctx, strings.Join(quoted, " "), ExecOpts{Sudo: true})
+	if res.ExitCode == -1 {
+		// transport failure, check connection again next time
+		sshAvailableMx.Lock()
+		delete(sshAvailable, e.cluster.label+"/"+nName)
+		sshAvailableMx.Unlock()
+	}
+	if err != nil {
+		return res.Stdout, res.Stderr, fmt.Errorf("Exec %s %v: %w", nName, cmd, err)
+	}
+	return res.Stdout, res.Stderr, nil
+}
+
+/*  Debug pod  */
+
+type debugPod struct {
+	cluster *KCluster
+	node    string
+	name    string
+	mx      sync.Mutex
+	ready   bool
+}
+
+type debugExecutor struct {
+	cluster *KCluster
+}
+
+func (e debugExecutor) Name() string { return NodeExecDebug }
+
+func (e debugExecutor) Available(nName string) error {
+	return e.cluster.debugPod(nName).ensure()
+}
+
+func (e debugExecutor) Exec(nName string, cmd []string) (string, string, error) {
+	p := e.cluster.debugPod(nName)
+	if err := p.ensure(); err != nil {
+		return "", "", err
+	}
+	nsCmd := append(append([]string{"nsenter"}, nodeNamespaces...), cmd...)
+	return e.cluster.podExec(DebugPodNamespace, p.name, "debug", nName, TransportDebugPod, cmd, nsCmd)
+}
+
+func (cluster *KCluster) debugPod(nName string) *debugPod {
+	debugPodsMx.Lock()
+	defer debugPodsMx.Unlock()
+	key := cluster.label + "/" + nName
+	if debugPods[key] == nil {
+		debugPods[key] = &debugPod{cluster: cluster, node: nName, name: "sds-e2e-debug-" + hashMd5(nName)[:10]}
+	}
+	return debugPods[key]
+}
+
+// ensure creates debug pod if it is not running and waits for it
+func (p *debugPod) ensure() error {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	pods := p.cluster.goClient.CoreV1().Pods(DebugPodNamespace)
+	pod, err := pods.Get(p.cluster.ctx, p.name, metav1.GetOptions{})
+	switch {
+	case err == nil && podRunning(pod):
+		p.ready = true
+		return nil
+	case err == nil:
+		// failed or deleted pod of previous run
+		if err := p.delete(); err != nil {
+			return err
+		}
+	case !apierrors.IsNotFound(err):
+		return err
+	}
+
+	p.cluster.Debugf("Creating debug pod %s on %s", p.name, p.node)
+	pod = &coreapi.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.name,
+			Namespace: DebugPodNamespace,
+			Labels:    map[string]string{"app": "sds-e2e-debug", "sds-e2e/run": RunID},
+		},
+		Spec: coreapi.PodSpec{
+			NodeName:                      p.node,
+			HostPID:                       true,
+			HostIPC:                       true,
+			HostNetwork:                   true,
+			RestartPolicy:                 coreapi.RestartPolicyNever,
+			ActiveDeadlineSeconds:         ptr.To(int64(6 * 3600)),
+			TerminationGracePeriodSeconds: ptr.To(int64(0)),
+			Tolerations:                   []coreapi.Toleration{{Operator: coreapi.TolerationOpExists}},
+			Containers: []coreapi.Container{{
+				Name:            "debug",
+				Image:           DebugPodImage,
+				Command:         []string{"sleep", "infinity"},
+				SecurityContext: &coreapi.SecurityContext{Privileged: ptr.To(true)},
+			}},
+		},
+	}
+	if _, err := pods.Create(p.cluster.ctx, pod, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("create debug pod %s: %w", p.name, err)
+	}
+	p.ready = true
+	return WaitFor(p.cluster.ctx, DebugPodTimeout, func(ctx context.Context) error {
+		pod, err := pods.Get(ctx, p.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !podRunning(pod) {
+			return fmt.Errorf("debug pod %s is %s", p.name, pod.Status.Phase)
+		}
+		return nil
+	})
+}
+
+func (p *debugPod) delete() error {
+	err := p.cluster.goClient.CoreV1().Pods(DebugPodNamespace).Delete(p.cluster.ctx, p.name,
+		metav1.DeleteOptions{GracePeriodSeconds: ptr.To(int64(0))})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return WaitFor(p.cluster.ctx, DebugPodTimeout, func(ctx context.Context) error {
+		_, err := p.cluster.goClient.CoreV1().Pods(DebugPodNamespace).Get(ctx, p.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("debug pod %s is not deleted", p.name)
+	})
+}
+
+// DeleteDebugPods removes debug pods created by the run
+func DeleteDebugPods() {
+	debugPodsMx.Lock()
+	list := debugPods
+	debugPods = map[string]*debugPod{}
+	debugPodsMx.Unlock()
+
+	for _, p := range list {
+		if !p.ready {
+			continue
+		}
+		if err := p.delete(); err != nil {
+			Warnf("Can't delete debug pod %s: %s", p.name, err.Error())
+		}
+	}
+}
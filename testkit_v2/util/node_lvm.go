@@ -0,0 +1,279 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	nodePvFields = "pv_name,vg_name,pv_uuid,pv_attr,pv_size,pv_free,dev_size,pv_tags"
+	nodeVgFields = "vg_name,vg_uuid,vg_attr,vg_size,vg_free,vg_extent_size,vg_extent_count,vg_free_count,pv_count,lv_count,vg_tags"
+	nodeLvFields = "lv_name,vg_name,lv_uuid,lv_attr,lv_size,pool_lv,segtype,data_percent,metadata_percent,lv_metadata_size,lv_path,lv_tags"
+)
+
+// NodePV is physical volume as node LVM reports it, sizes in bytes
+type NodePV struct {
+	Name    string
+	VgName  string
+	Uuid    string
+	Attr    string
+	Size    int64
+	Free    int64
+	DevSize int64
+	Tags    []string
+}
+
+// NodeVG is volume group as node LVM reports it, sizes in bytes
+type NodeVG struct {
+	Name        string
+	Uuid        string
+	Attr        string
+	Size        int64
+	Free        int64
+	ExtentSize  int64
+	ExtentCount int
+	FreeCount   int
+	PvCount     int
+	LvCount     int
+	Tags        []string
+}
+
+// Allocated is size of VG extents used by LVs
+func (vg *NodeVG) Allocated() int64 {
+	return vg.Size - vg.Free
+}
+
+// NodeLV is logical volume as node LVM reports it, sizes in bytes
+type NodeLV struct {
+	Name         string
+	VgName       string
+	Uuid         string
+	Attr         string
+	Path         string
+	Pool         string // thin pool of thin LV
+	SegType      string // linear, thin-pool, thin...
+	Size         int64
+	MetadataSize int64   // thin pool metadata
+	DataPercent  float64 // thin pool or thin LV usage
+	MetaPercent  float64
+	Tags         []string
+}
+
+// NodeThinPool is thin pool LV with its thin volumes
+type NodeThinPool struct {
+	NodeLV
+	Allocated int64 // data used by thin LVs
+	Thin      []NodeLV
+}
+
+// NodeLvm is LVM state of the node
+//
+//	lvm, _ := cluster.GetNodeLvm(nName)
+//	vg := lvm.VG("data")
+//	if vg == nil || vg.Allocated() != 0 { ... }
+type NodeLvm struct {
+	Node string
+	PVs  []NodePV
+	VGs  []NodeVG
+	LVs  []NodeLV
+}
+
+func (l *NodeLvm) PV(name string) *NodePV {
+	for i := range l.PVs {
+		if l.PVs[i].Name == name {
+			return &l.PVs[i]
+		}
+	}
+	return nil
+}
+
+func (l *NodeLvm) VG(name string) *NodeVG {
+	for i := range l.VGs {
+		if l.VGs[i].Name == name {
+			return &l.VGs[i]
+		}
+	}
+	return nil
+}
+
+func (l *NodeLvm) LV(vgName, name string) *NodeLV {
+	for i := range l.LVs {
+		if l.LVs[i].VgName == vgName && l.LVs[i].Name == name {
+			return &l.LVs[i]
+		}
+	}
+	return nil
+}
+
+// VgPVs returns PVs of VG
+func (l *NodeLvm) VgPVs(vgName string) (resp []NodePV) {
+	for _, pv := range l.PVs {
+		if pv.VgName == vgName {
+			resp = append(resp, pv)
+		}
+	}
+	return
+}
+
+// VgLVs returns LVs of VG, hidden LVs (pool metadata, spare) are not reported
+func (l *NodeLvm) VgLVs(vgName string) (resp []NodeLV) {
+	for _, lv := range l.LVs {
+		if lv.VgName == vgName {
+			resp = append(resp, lv)
+		}
+	}
+	return
+}
+
+// ThinPools returns thin pools of VG
+func (l *NodeLvm) ThinPools(vgName string) (resp []NodeThinPool) {
+	for _, lv := range l.VgLVs(vgName) {
+		if lv.SegType != "thin-pool" {
+			continue
+		}
+		tp := NodeThinPool{NodeLV: lv, Allocated: int64(float64(lv.Size) * lv.DataPercent / 100)}
+		for _, thin := range l.VgLVs(vgName) {
+			if thin.Pool == lv.Name {
+				tp.Thin = append(tp.Thin, thin)
+			}
+		}
+		resp = append(resp, tp)
+	}
+	return
+}
+
+func (l *NodeLvm) ThinPool(vgName, name string) *NodeThinPool {
+	for _, tp := range l.ThinPools(vgName) {
+		if tp.Name == name {
+			return &tp
+		}
+	}
+	return nil
+}
+
+// GetNodeLvm reads PVs, VGs and LVs of the node with lvm.static JSON reports
+func (cluster *KCluster) GetNodeLvm(nName string) (*NodeLvm, error) {
+	return cluster.GetNodeLvmWith(nName, lvmStatic)
+}
+
+// GetNodeLvmWith reads node LVM state with lvm binary (lvm.static, lvm)
+func (cluster *KCluster) GetNodeLvmWith(nName, lvmBin string) (*NodeLvm, error) {
+	resp := &NodeLvm{Node: nName}
+
+	pvs, err := cluster.lvmReport(nName, lvmBin, "pvs", "pv", nodePvFields)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range pvs {
+		resp.PVs = append(resp.PVs, NodePV{
+			Name:    r["pv_name"],
+			VgName:  r["vg_name"],
+			Uuid:    r["pv_uuid"],
+			Attr:    r["pv_attr"],
+			Size:    r.bytes("pv_size"),
+			Free:    r.bytes("pv_free"),
+			DevSize: r.bytes("dev_size"),
+			Tags:    r.tags("pv_tags"),
+		})
+	}
+
+	vgs, err := cluster.lvmReport(nName, lvmBin, "vgs", "vg", nodeVgFields)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range vgs {
+		resp.VGs = append(resp.VGs, NodeVG{
+			Name:        r["vg_name"],
+			Uuid:        r["vg_uuid"],
+			Attr:        r["vg_attr"],
+			Size:        r.bytes("vg_size"),
+			Free:        r.bytes("vg_free"),
+			ExtentSize:  r.bytes("vg_extent_size"),
+			ExtentCount: int(r.bytes("vg_extent_count")),
+			FreeCount:   int(r.bytes("vg_free_count")),
+			PvCount:     int(r.bytes("pv_count")),
+			LvCount:     int(r.bytes("lv_count")),
+			Tags:        r.tags("vg_tags"),
+		})
+	}
+
+	lvs, err := cluster.lvmReport(nName, lvmBin, "lvs", "lv", nodeLvFields)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range lvs {
+		resp.LVs = append(resp.LVs, NodeLV{
+			Name:         r["lv_name"],
+			VgName:       r["vg_name"],
+			Uuid:         r["lv_uuid"],
+			Attr:         r["lv_attr"],
+			Path:         r["lv_path"],
+			Pool:         r["pool_lv"],
+			SegType:      r["segtype"],
+			Size:         r.bytes("lv_size"),
+			MetadataSize: r.bytes("lv_metadata_size"),
+			DataPercent:  r.percent("data_percent"),
+			MetaPercent:  r.percent("metadata_percent"),
+			Tags:         r.tags("lv_tags"),
+		})
+	}
+
+	return resp, nil
+}
+
+type lvmRow map[string]string
+
+// bytes parses size reported with `--units b`, suffix B is optional
+func (r lvmRow) bytes(field string) int64 {
+	v, _ := strconv.ParseInt(strings.TrimSuffix(r[field], "B"), 10, 64)
+	return v
+}
+
+func (r lvmRow) percent(field string) float64 {
+	v, _ := strconv.ParseFloat(r[field], 64)
+	return v
+}
+
+func (r lvmRow) tags(field string) []string {
+	if r[field] == "" {
+		return nil
+	}
+	return strings.Split(r[field], ",")
+}
+
+func (cluster *KCluster) lvmReport(nName, lvmBin, tool, key, fields string) ([]lvmRow, error) {
+	cmd := []string{"sudo", lvmBin, tool, "--reportformat", "json", "--units", "b", "--nosuffix", "-o", fields}
+	stdout, stderr, err := cluster.ExecNode(nName, cmd)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w (%s)", nName, tool, err, strings.TrimSpace(stderr))
+	}
+	var report struct {
+		Report []map[string][]lvmRow `json:"report"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		return nil, fmt.Errorf("%s %s report: %w", nName, tool, err)
+	}
+	var resp []lvmRow
+	for _, r := range report.Report {
+		resp = append(resp, r[key]...)
+	}
+	return resp, nil
+}
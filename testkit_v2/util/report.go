@@ -0,0 +1,517 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusSkipped = "skipped"
+	// failed quarantined test, does not fail the run
+	StatusQuarantined = "quarantined"
+
+	caseTest  = "test"
+	caseGroup = "group"
+	caseNode  = "node"
+)
+
+type ReportNode struct {
+	Name             string `json:"name"`
+	OsImage          string `json:"osImage"`
+	Kernel           string `json:"kernel"`
+	Kubelet          string `json:"kubelet"`
+	ContainerRuntime string `json:"containerRuntime"`
+}
+
+type ReportCase struct {
+	Name     string        `json:"name"`
+	Kind     string        `json:"kind"`
+	Group    string        `json:"group,omitempty"`
+	Node     *ReportNode   `json:"node,omitempty"`
+	Status   string        `json:"status"`
+	Start    time.Time     `json:"start"`
+	Duration float64       `json:"duration"`
+	Failures []string      `json:"failures,omitempty"`
+	Skip     string        `json:"skip,omitempty"`
+	Children []*ReportCase `json:"children,omitempty"`
+
+	Attempts    int      `json:"attempts,omitempty"`
+	Flaky       bool     `json:"flaky,omitempty"`
+	Quarantined bool     `json:"quarantined,omitempty"`
+	Retries     []string `json:"retryFailures,omitempty"`
+	DependsOn   []string `json:"dependsOn,omitempty"`
+	Namespace   string   `json:"namespace,omitempty"`
+	Output      []string `json:"output,omitempty"`
+}
+
+type Report struct {
+	RunID            string             `json:"runId"`
+	Start            time.Time          `json:"start"`
+	Duration         float64            `json:"duration"`
+	Config           map[string]string  `json:"config"`
+	ClusterType      string             `json:"clusterType"`
+	Namespace        string             `json:"namespace"`
+	DeckhouseVersion string             `json:"deckhouseVersion"`
+	Tests            []*ReportCase      `json:"tests"`
+	Unmet            []UnmetRequirement `json:"unmetRequirements,omitempty"`
+	Ssh              *SshStats          `json:"ssh,omitempty"`
+	cases            map[string]*ReportCase
+	tracked          map[string]bool
+}
+
+var (
+	report   = Report{Start: startTime, cases: map[string]*ReportCase{}, tracked: map[string]bool{}}
+	reportMx sync.Mutex
+)
+
+func newReportNode(node *coreapi.Node) *ReportNode {
+	if node == nil {
+		return nil
+	}
+	info := node.Status.NodeInfo
+	return &ReportNode{
+		Name:             node.Name,
+		OsImage:          info.OSImage,
+		Kernel:           info.KernelVersion,
+		Kubelet:          info.KubeletVersion,
+		ContainerRuntime: info.ContainerRuntimeVersion,
+	}
+}
+
+// addCase registers test case in report tree. Must be called with reportMx locked
+func addCase(name, kind string) *ReportCase {
+	if c, ok := report.cases[name]; ok {
+		return c
+	}
+
+	c := &ReportCase{Name: name, Kind: kind, Status: StatusPassed, Start: time.Now()}
+	report.cases[name] = c
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		parent := addCase(name[:i], caseGroup)
+		parent.Children = append(parent.Children, c)
+	} else {
+		c.Kind = caseTest
+		report.Tests = append(report.Tests, c)
+	}
+	return c
+}
+
+func (c *ReportCase) addFailure(msg string) {
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	c.Failures = append(c.Failures, msg)
+	c.Status = StatusFailed
+}
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// addOutput records log line of the test. Safe for nil case (T outside node tests)
+func (c *ReportCase) addOutput(line string) {
+	if c == nil {
+		return
+	}
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	c.Output = append(c.Output, ansiEscape.ReplaceAllString(line, ""))
+}
+
+func (c *ReportCase) setSkipped(msg string) {
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	c.Skip = msg
+	if c.Status != StatusFailed {
+		c.Status = StatusSkipped
+	}
+}
+
+func (c *ReportCase) setDependsOn(deps []string) {
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	c.DependsOn = deps
+}
+
+// addRetry records failures of node test attempt followed by retry
+func (c *ReportCase) addRetry(failures []string) {
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	c.Retries = append(c.Retries, failures...)
+}
+
+// setResult records result of the last node test attempt
+func (c *ReportCase) setResult(failures []string, skipped string, attempts int, quarantined bool) {
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	c.Attempts, c.Quarantined = attempts, quarantined
+	switch {
+	case len(failures) > 0 && quarantined:
+		c.Failures = append(c.Failures, failures...)
+		c.Status = StatusQuarantined
+	case len(failures) > 0:
+		c.Failures = append(c.Failures, failures...)
+		c.Status = StatusFailed
+	case skipped != "":
+		c.Skip = skipped
+		if c.Status != StatusFailed {
+			c.Status = StatusSkipped
+		}
+	case attempts > 1:
+		c.Flaky = true
+	}
+}
+
+func (c *ReportCase) finish(failed, skipped bool) {
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	c.Duration = time.Since(c.Start).Seconds()
+	switch {
+	case failed:
+		c.Status = StatusFailed
+	case skipped && c.Status != StatusFailed:
+		c.Status = StatusSkipped
+	}
+	RecordTimeline(TimelineRecord{Kind: TimelineTest, Test: c.Name, Node: c.nodeName(), Action: c.Status, Duration: c.Duration})
+}
+
+func (c *ReportCase) nodeName() string {
+	if c.Node == nil {
+		return ""
+	}
+	return c.Node.Name
+}
+
+// Track adds test to the report. RunTestGroupNodes and Require track tests automatically
+func Track(t *testing.T) *ReportCase {
+	reportMx.Lock()
+	c := addCase(t.Name(), caseGroup)
+	tracked := report.tracked[t.Name()]
+	if !tracked {
+		report.tracked[t.Name()] = true
+		c.Start = time.Now()
+	}
+	reportMx.Unlock()
+	if tracked {
+		return c
+	}
+	RecordTimeline(TimelineRecord{Kind: TimelineTest, Test: c.Name, Action: "start"})
+
+	t.Cleanup(func() {
+		c.finish(t.Failed(), t.Skipped())
+		if c.Kind == caseTest {
+			FlushReports()
+			FlushTimeline()
+			FlushTranscript()
+		}
+	})
+	return c
+}
+
+// resetReport starts new report, used between soak iterations
+func resetReport() *Report {
+	reportMx.Lock()
+	defer reportMx.Unlock()
+
+	prev := report
+	prev.Duration = time.Since(prev.Start).Seconds()
+	report = Report{Start: time.Now(), cases: map[string]*ReportCase{}, tracked: map[string]bool{}}
+	return &prev
+}
+
+// trackNode adds node test to the report. Node test without own subtest (not tree mode) is finished by caller
+func trackNode(name string, tn *TestNode) *ReportCase {
+	reportMx.Lock()
+	defer reportMx.Unlock()
+
+	c := addCase(name, caseNode)
+	c.Kind, c.Group, c.Node = caseNode, tn.GroupName, newReportNode(tn.Raw)
+	RecordTimeline(TimelineRecord{Kind: TimelineTest, Test: name, Node: tn.Name, Action: "start"})
+	return c
+}
+
+func (cluster *KCluster) GetDeckhouseVersion() (string, error) {
+	d, err := cluster.goClient.AppsV1().Deployments("d8-system").Get(cluster.ctx, "deckhouse", metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	if v, ok := d.Annotations["core.deckhouse.io/version"]; ok {
+		return v, nil
+	}
+	if len(d.Spec.Template.Spec.Containers) > 0 {
+		img := d.Spec.Template.Spec.Containers[0].Image
+		return img[strings.LastIndex(img, ":")+1:], nil
+	}
+	return "", fmt.Errorf("no deckhouse version")
+}
+
+func setReportCluster(cluster *KCluster) {
+	version, err := cluster.GetDeckhouseVersion()
+	if err != nil {
+		Debugf("Can't get Deckhouse version: %s", err.Error())
+		version = "unknown"
+	}
+
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	report.DeckhouseVersion = version
+}
+
+/*  Output  */
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitCase struct {
+	ClassName  string          `xml:"classname,attr"`
+	Name       string          `xml:"name,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitMessage   `xml:"failure,omitempty"`
+	Skipped    *junitMessage   `xml:"skipped,omitempty"`
+	SystemOut  string          `xml:"system-out,omitempty"`
+}
+
+type junitSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitCase     `xml:"testcase"`
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+func junitLeaves(c *ReportCase, suite *junitSuite) {
+	if len(c.Children) > 0 {
+		for _, child := range c.Children {
+			junitLeaves(child, suite)
+		}
+		return
+	}
+
+	className, name := suite.Name, c.Name
+	if i := strings.LastIndex(c.Name, "/"); i >= 0 {
+		className, name = c.Name[:i], c.Name[i+1:]
+	}
+	jc := junitCase{ClassName: className, Name: name, Time: fmt.Sprintf("%.3f", c.Duration)}
+	if c.Node != nil {
+		jc.Properties = []junitProperty{
+			{"node.group", c.Group},
+			{"node.osImage", c.Node.OsImage},
+			{"node.kernel", c.Node.Kernel},
+			{"node.kubelet", c.Node.Kubelet},
+			{"node.containerRuntime", c.Node.ContainerRuntime},
+		}
+	}
+	if c.Attempts > 1 {
+		jc.Properties = append(jc.Properties, junitProperty{"attempts", fmt.Sprint(c.Attempts)})
+	}
+	if len(c.DependsOn) > 0 {
+		jc.Properties = append(jc.Properties, junitProperty{"dependsOn", strings.Join(c.DependsOn, ",")})
+	}
+	if c.Flaky {
+		jc.Properties = append(jc.Properties, junitProperty{"flaky", "true"})
+	}
+	if len(c.Retries) > 0 {
+		jc.SystemOut = "retried after: " + strings.Join(c.Retries, "\n") + "\n"
+	}
+	jc.SystemOut += strings.Join(c.Output, "\n")
+	switch c.Status {
+	case StatusFailed:
+		msg := "failed"
+		if len(c.Failures) > 0 {
+			msg = c.Failures[0]
+		}
+		jc.Failure = &junitMessage{Message: msg, Text: strings.Join(c.Failures, "\n")}
+		suite.Failures++
+	case StatusSkipped:
+		jc.Skipped = &junitMessage{Message: c.Skip}
+		suite.Skipped++
+	case StatusQuarantined:
+		jc.Skipped = &junitMessage{Message: "quarantined", Text: strings.Join(c.Failures, "\n")}
+		suite.Skipped++
+	}
+	suite.Tests++
+	suite.Cases = append(suite.Cases, jc)
+}
+
+func (r *Report) junit() junitSuites {
+	props := []junitProperty{
+		{"clusterType", r.ClusterType},
+		{"deckhouseVersion", r.DeckhouseVersion},
+	}
+	keys := make([]string, 0, len(r.Config))
+	for k := range r.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		props = append(props, junitProperty{"config." + k, r.Config[k]})
+	}
+
+	suites := junitSuites{Name: "sds-e2e", Time: fmt.Sprintf("%.3f", r.Duration)}
+	for _, c := range r.Tests {
+		suite := junitSuite{
+			Name:       c.Name,
+			Time:       fmt.Sprintf("%.3f", c.Duration),
+			Timestamp:  c.Start.Format(time.RFC3339),
+			Properties: props,
+		}
+		if c.Namespace != "" {
+			suite.Properties = append(props[:len(props):len(props)], junitProperty{"namespace", c.Namespace})
+		}
+		junitLeaves(c, &suite)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Skipped += suite.Skipped
+		suites.Suites = append(suites.Suites, suite)
+	}
+	return suites
+}
+
+func (r *Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (r *Report) WriteJUnit(path string) error {
+	data, err := xml.MarshalIndent(r.junit(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+}
+
+// ReadReport reads JSON report (-jsonreport)
+func ReadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("report %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// MergeReports merges reports of runs on different clusters. Test is marked with namespace of its run,
+// test that ran several times is taken from the last report
+func MergeReports(reports ...*Report) *Report {
+	merged := &Report{Config: map[string]string{}}
+	index := map[string]int{}
+	var end time.Time
+	for _, r := range reports {
+		if merged.Start.IsZero() || r.Start.Before(merged.Start) {
+			merged.Start = r.Start
+		}
+		if e := r.Start.Add(time.Duration(r.Duration * float64(time.Second))); e.After(end) {
+			end = e
+		}
+		if merged.ClusterType == "" {
+			merged.ClusterType, merged.Config = r.ClusterType, r.Config
+		}
+		if merged.DeckhouseVersion == "" || merged.DeckhouseVersion == "unknown" {
+			merged.DeckhouseVersion = r.DeckhouseVersion
+		}
+		merged.Unmet = append(merged.Unmet, r.Unmet...)
+
+		for _, c := range r.Tests {
+			c.Namespace = r.Namespace
+			if i, ok := index[c.Name]; ok {
+				merged.Tests[i] = c
+				continue
+			}
+			index[c.Name] = len(merged.Tests)
+			merged.Tests = append(merged.Tests, c)
+		}
+	}
+	merged.Duration = end.Sub(merged.Start).Seconds()
+	return merged
+}
+
+// FlushReports writes JSON and JUnit reports (-jsonreport, -junitreport)
+func FlushReports() {
+	if *jsonReportFlag == "" && *junitReportFlag == "" {
+		return
+	}
+
+	reportMx.Lock()
+	defer reportMx.Unlock()
+
+	report.Duration = time.Since(report.Start).Seconds()
+	report.ClusterType = *clusterTypeFlag
+	report.RunID = RunID
+	report.Namespace = TestNS
+	report.Unmet = UnmetRequirements("")
+	if stats := SshPoolStats(); len(stats.Conns) > 0 {
+		report.Ssh = &stats
+	}
+	report.Config = map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		if !strings.HasPrefix(f.Name, "test.") {
+			report.Config[f.Name] = f.Value.String()
+		}
+	})
+
+	if *jsonReportFlag != "" {
+		if err := report.WriteJSON(*jsonReportFlag); err != nil {
+			Errorf("Can't write JSON report: %s", err.Error())
+		}
+	}
+
+	if *junitReportFlag != "" {
+		if err := report.WriteJUnit(*junitReportFlag); err != nil {
+			Errorf("Can't write JUnit report: %s", err.Error())
+		}
+	}
+}
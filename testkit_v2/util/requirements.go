@@ -0,0 +1,252 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Requirements declares what test needs from the stand.
+// Unmet requirements skip test in -skipoptional mode and fail it otherwise.
+type Requirements struct {
+	Hypervisor       bool     // virtual machines management (VD resize, VMBD attach)
+	ThinProvisioning bool     // sds-node-configurator enableThinProvisioning setting
+	Modules          []string // enabled Deckhouse modules
+	NodeGroups       []string // NodeRequired labels with at least one node
+	BdCount          int      // consumable BlockDevices on each node (checked for node tests)
+	BdSize           int64    // consumable BlockDevice size in Gi (0 - any)
+	StaticNodes      bool     // nodes bootstrapped by CAPS from StaticInstances (remove and re-add node)
+}
+
+type UnmetRequirement struct {
+	Test    string
+	Node    string
+	Missing []string
+}
+
+// requirementCheck is cluster level check, done once per run
+type requirementCheck struct {
+	once    sync.Once
+	missing string
+}
+
+var (
+	requirements      = map[string]Requirements{}
+	requirementChecks = map[string]*requirementCheck{}
+	unmetRequirements = []UnmetRequirement{}
+	reqMx             sync.Mutex
+)
+
+// checkOnce caches result of cluster level requirement check. Checks of different keys run concurrently
+func checkOnce(key string, check func() string) string {
+	reqMx.Lock()
+	c := requirementChecks[key]
+	if c == nil {
+		c = &requirementCheck{}
+		requirementChecks[key] = c
+	}
+	reqMx.Unlock()
+
+	c.once.Do(func() { c.missing = check() })
+	return c.missing
+}
+
+func (cluster *KCluster) missingRequirements(req Requirements) []string {
+	missing := []string{}
+	add := func(m string) {
+		if m != "" {
+			missing = append(missing, m)
+		}
+	}
+
+	if req.Hypervisor {
+		add(checkOnce("hypervisor", func() string {
+			if HypervisorKubeConfig == "" {
+				return "hypervisor (-hypervisorkconfig)"
+			}
+			return ""
+		}))
+	}
+
+	if req.ThinProvisioning {
+		add(checkOnce("thin provisioning", func() string {
+			mc, err := cluster.GetModuleConfig(SDSNodeConfiguratorModuleName)
+			if err != nil {
+				return fmt.Sprintf("thin provisioning (%s)", err.Error())
+			}
+			if enabled, _ := mc.Spec.Settings["enableThinProvisioning"].(bool); !enabled {
+				return "thin provisioning (sds-node-configurator enableThinProvisioning)"
+			}
+			return ""
+		}))
+	}
+
+	if req.StaticNodes {
+		add(checkOnce("static nodes", func() string {
+			sis, err := cluster.ListStaticInstance()
+			if err != nil {
+				return fmt.Sprintf("static nodes (%s)", err.Error())
+			}
+			for _, si := range sis {
+				if si.Status.NodeRef != nil {
+					return ""
+				}
+			}
+			return "static nodes (StaticInstance with node)"
+		}))
+	}
+
+	for _, module := range req.Modules {
+		add(checkOnce("module "+module, func() string {
+			enabled, err := cluster.IsModuleEnabled(module)
+			if err != nil {
+				return fmt.Sprintf("module %s (%s)", module, err.Error())
+			}
+			if !enabled {
+				return fmt.Sprintf("module %s enabled", module)
+			}
+			return ""
+		}))
+	}
+
+	for _, group := range req.NodeGroups {
+		add(checkOnce("node group "+group, func() string {
+			if _, ok := NodeRequired[group]; !ok {
+				return fmt.Sprintf("node group %s (not in cluster type)", group)
+			}
+			if len(cluster.MapLabelNodes(WhereIn{group})[group]) == 0 {
+				return fmt.Sprintf("nodes in group %s", group)
+			}
+			return ""
+		}))
+	}
+
+	return missing
+}
+
+// missingNodeRequirements checks requirements depending on node state (not cached)
+func (cluster *KCluster) missingNodeRequirements(req Requirements, nName string) []string {
+	if req.BdCount == 0 {
+		return nil
+	}
+
+	filter := BdFilter{Node: nName, Consumable: true, Size: float32(req.BdSize)}
+	bds, err := cluster.ListBD(filter)
+	if err != nil {
+		return []string{fmt.Sprintf("consumable BDs on %s (%s)", nName, err.Error())}
+	}
+	if len(bds) < req.BdCount && HypervisorKubeConfig != "" {
+		// hypervisor creates missing BlockDevices on demand, if the node is its VM
+		vms, err := EnsureCluster(HypervisorKubeConfig, "").ListVM(VmFilter{NameSpace: TestNS, Name: nName})
+		if err != nil {
+			return []string{fmt.Sprintf("VM of %s for BlockDevices (%s)", nName, err.Error())}
+		}
+		if len(vms) == 0 {
+			return []string{fmt.Sprintf("VM of %s in %s for BlockDevices", nName, TestNS)}
+		}
+		return nil
+	}
+	if len(bds) < req.BdCount {
+		size := ""
+		if req.BdSize > 0 {
+			size = fmt.Sprintf(" %dGi", req.BdSize)
+		}
+		return []string{fmt.Sprintf("%d consumable BDs%s on %s (found %d)", req.BdCount, size, nName, len(bds))}
+	}
+	return nil
+}
+
+// requirementsFor returns requirements of the nearest declared parent test
+func requirementsFor(name string) (Requirements, bool) {
+	reqMx.Lock()
+	defer reqMx.Unlock()
+
+	for {
+		if req, ok := requirements[name]; ok {
+			return req, true
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return Requirements{}, false
+		}
+		name = name[:i]
+	}
+}
+
+// unmet skips or fails test with unmet requirements. Node case of group test without own
+// subtest (no tree mode) is only recorded, so the group test goes on with other nodes
+func unmet(t *testing.T, rc *ReportCase, nName string, missing []string) {
+	reqMx.Lock()
+	unmetRequirements = append(unmetRequirements, UnmetRequirement{Test: t.Name(), Node: nName, Missing: missing})
+	reqMx.Unlock()
+
+	msg := "missing requirements: " + strings.Join(missing, ", ")
+	shared := nName != "" && !TreeMode
+	if SkipOptional {
+		Warnf("%s %s %s", t.Name(), nName, msg)
+		rc.setSkipped(msg)
+		if shared {
+			return
+		}
+		t.Skip(msg)
+	}
+	rc.addFailure(msg)
+	if shared {
+		t.Errorf("%s: %s", nName, msg)
+		return
+	}
+	t.Fatal(msg)
+}
+
+// Require declares test requirements. Cluster level requirements are checked once per run,
+// node level ones (BlockDevices) are checked by RunTestGroupNodes before each node test
+//
+//	cluster.Require(t, util.Requirements{Hypervisor: true, BdCount: 1, BdSize: 2})
+func (cluster *KCluster) Require(t *testing.T, req Requirements) {
+	rc := Track(t)
+
+	reqMx.Lock()
+	requirements[t.Name()] = req
+	reqMx.Unlock()
+
+	t.Cleanup(func() {
+		for _, u := range UnmetRequirements(t.Name()) {
+			t.Logf("%s %s: missing %s", u.Test, u.Node, strings.Join(u.Missing, ", "))
+		}
+	})
+
+	if missing := cluster.missingRequirements(req); len(missing) > 0 {
+		unmet(t, rc, "", missing)
+	}
+}
+
+// UnmetRequirements returns unmet requirements of the test and its subtests
+func UnmetRequirements(testName string) []UnmetRequirement {
+	reqMx.Lock()
+	defer reqMx.Unlock()
+
+	resp := []UnmetRequirement{}
+	for _, u := range unmetRequirements {
+		if testName == "" || u.Test == testName || strings.HasPrefix(u.Test, testName+"/") {
+			resp = append(resp, u)
+		}
+	}
+	return resp
+}
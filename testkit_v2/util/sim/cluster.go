@@ -0,0 +1,71 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sim
+
+import (
+	"fmt"
+
+	util "github.com/deckhouse/sds-e2e/util"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// NewCluster returns cluster without API server. ExecNode and node assertions
+// (ExecNodeRespContains...) run commands on simulated nodes, objects (LVMVolumeGroup,
+// BlockDevice...) are served by fake client
+//
+//	cluster := sim.NewCluster("sim", []*sim.Node{node}, &lvg)
+func NewCluster(name string, nodes []*Node, objs ...client.Object) *util.KCluster {
+	scheme, err := util.NewScheme()
+	if err != nil {
+		panic(err)
+	}
+	rtClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).WithStatusSubresource(objs...).Build()
+
+	e := executor{nodes: map[string]*Node{}}
+	for _, n := range nodes {
+		e.nodes[n.Name] = n
+	}
+	cluster := util.NewOfflineKCluster(name, rtClient)
+	cluster.SetNodeExecutor(e)
+	return cluster
+}
+
+// executor is NodeExecutor of simulated cluster, runs argv like pod exec with nsenter does
+type executor struct {
+	nodes map[string]*Node
+}
+
+func (e executor) Name() string { return "sim" }
+
+func (e executor) Available(nName string) error {
+	if e.nodes[nName] == nil {
+		return fmt.Errorf("no simulated node %s", nName)
+	}
+	return nil
+}
+
+func (e executor) Exec(nName string, cmd []string) (string, string, error) {
+	if err := e.Available(nName); err != nil {
+		return "", "", err
+	}
+	stdout, stderr, code := e.nodes[nName].Run(cmd, "")
+	if code != 0 {
+		return stdout, stderr, fmt.Errorf("Exec %s %v: %w", nName, cmd, exitError{code})
+	}
+	return stdout, stderr, nil
+}
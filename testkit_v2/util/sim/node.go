@@ -0,0 +1,488 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sim
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Command is scripted command of Node. Gets argv without wrappers (sudo, env, timeout...)
+type Command func(args []string, stdin string) (stdout, stderr string, code int)
+
+// Node is offline model of cluster node: block devices, LVM physical volumes, volume groups,
+// logical volumes and thin pools. Exec answers lsblk and LVM tooling (pvs, vgs, lvs, *display,
+// pvcreate, vgcreate, lvcreate, lvremove...) in the format of real tools and changes the model
+//
+//	node := NewNode("worker-0")
+//	node.AddDisk("sdb", 2<<30)
+//	out, _, code := node.Exec("sudo /opt/deckhouse/sds/bin/lvm.static vgcreate data /dev/sdb", "")
+type Node struct {
+	Name string
+	// Commands are scripted commands by name, take precedence over emulated tools
+	Commands map[string]Command
+	// Shell runs unknown commands with local sh instead of "command not found"
+	Shell bool
+
+	mx    sync.Mutex
+	disks []*Disk
+	vgs   []*simVg
+	dmIdx int
+	seq   int
+}
+
+// Disk is block device of Node. Parts are partitions of disk
+type Disk struct {
+	Name    string
+	Size    int64
+	Model   string
+	Serial  string
+	Wwn     string
+	Rota    bool
+	HotPlug bool
+	FsType  string
+	Mount   string
+	Parts   []*Disk
+
+	major, minor int
+	pv           *simPv
+}
+
+// exitError is exit status of simulated command, satisfies k8s.io/client-go/util/exec.ExitError
+type exitError struct {
+	code int
+}
+
+func (e exitError) Error() string {
+	return fmt.Sprintf("command terminated with exit code %d", e.code)
+}
+
+func (e exitError) String() string  { return e.Error() }
+func (e exitError) Exited() bool    { return true }
+func (e exitError) ExitStatus() int { return e.code }
+
+// NewNode returns node with system disk sda (20G, mounted to /)
+func NewNode(name string) *Node {
+	n := &Node{Name: name, Commands: map[string]Command{}}
+	sda := n.AddDisk("sda", 20<<30)
+	sda.Parts = []*Disk{{Name: "sda1", Size: sda.Size - 1<<20, FsType: "ext4", Mount: "/", major: 8, minor: 1}}
+	return n
+}
+
+// AddDisk attaches new disk to the node
+func (n *Node) AddDisk(name string, size int64) *Disk {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	id := hashMd5(n.Name + "/" + name)
+	d := &Disk{
+		Name:   name,
+		Size:   size,
+		Model:  "QEMU HARDDISK",
+		Serial: id[:20],
+		Wwn:    "0x" + id[:16],
+		major:  8,
+		minor:  16 * len(n.disks),
+	}
+	n.disks = append(n.disks, d)
+	return d
+}
+
+// ResizeDisk changes disk size, PV on it keeps old size until pvresize
+func (n *Node) ResizeDisk(name string, size int64) error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	d := n.disk(name)
+	if d == nil {
+		return fmt.Errorf("no disk %s on %s", name, n.Name)
+	}
+	if d.pv != nil && d.pv.vg != nil && size < d.pv.size {
+		return fmt.Errorf("disk %s is PV of %s, can't shrink", name, d.pv.vg.name)
+	}
+	d.Size = size
+	return nil
+}
+
+// RemoveDisk detaches disk. PV on it becomes missing in its VG
+func (n *Node) RemoveDisk(name string) error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	for i, d := range n.disks {
+		if d.Name != name {
+			continue
+		}
+		if d.pv != nil && d.pv.vg != nil {
+			d.pv.missing = true
+		}
+		n.disks = append(n.disks[:i], n.disks[i+1:]...)
+		return nil
+	}
+	return fmt.Errorf("no disk %s on %s", name, n.Name)
+}
+
+// Disks returns names of node disks
+func (n *Node) Disks() []string {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	names := make([]string, len(n.disks))
+	for i, d := range n.disks {
+		names[i] = d.Name
+	}
+	return names
+}
+
+func (n *Node) disk(name string) *Disk {
+	name = strings.TrimPrefix(name, "/dev/")
+	for _, d := range n.disks {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+func (n *Node) uuid(kind string) string {
+	n.seq++
+	id := hashMd5(fmt.Sprintf("%s/%s/%d", n.Name, kind, n.seq))
+	// LVM style: 6-4-4-4-4-4-6
+	const abc = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	sum := sha256.Sum256([]byte(id))
+	var sb strings.Builder
+	for i, b := range sum[:32] {
+		if i == 6 || i == 10 || i == 14 || i == 18 || i == 22 || i == 26 {
+			sb.WriteByte('-')
+		}
+		sb.WriteByte(abc[int(b)%len(abc)])
+	}
+	return sb.String()
+}
+
+/*  Shell  */
+
+type simShell struct {
+	node *Node
+	cwd  string
+}
+
+// Exec runs shell command line on the node. Supports `&&`, `||`, `;` and `|`, quoting,
+// wrappers (sudo, timeout, env, nsenter, sh -c) and cd
+func (n *Node) Exec(cmd, stdin string) (stdout, stderr string, code int) {
+	sh := &simShell{node: n, cwd: "/"}
+	return sh.script(cmd, stdin)
+}
+
+// Run runs argv on the node without shell, like pod exec does
+func (n *Node) Run(args []string, stdin string) (stdout, stderr string, code int) {
+	sh := &simShell{node: n, cwd: "/"}
+	return sh.run(args, stdin)
+}
+
+func (sh *simShell) script(cmd, stdin string) (string, string, int) {
+	tokens, err := shellSplit(cmd)
+	if err != nil {
+		return "", "sh: " + err.Error() + "\n", 2
+	}
+	var stdout, stderr strings.Builder
+	code := 0
+	skip := false
+	var pipeline [][]string
+	var args []string
+	flush := func(op string) {
+		pipeline = append(pipeline, args)
+		args = nil
+		if op == "|" {
+			return
+		}
+		if !skip {
+			in := stdin
+			for i, a := range pipeline {
+				var out, errOut string
+				out, errOut, code = sh.run(a, in)
+				stderr.WriteString(errOut)
+				if i == len(pipeline)-1 {
+					stdout.WriteString(out)
+				}
+				in = out
+			}
+		}
+		pipeline = nil
+		switch op {
+		case "&&":
+			skip = code != 0
+		case "||":
+			skip = code == 0
+		default:
+			skip = false
+		}
+	}
+	for _, t := range tokens {
+		switch t {
+		case "&&", "||", ";", "|":
+			flush(t)
+		default:
+			args = append(args, t)
+		}
+	}
+	if len(args) > 0 || len(pipeline) > 0 {
+		flush("")
+	}
+	return stdout.String(), stderr.String(), code
+}
+
+func (sh *simShell) run(args []string, stdin string) (string, string, int) {
+	args = unwrapCommand(args)
+	if len(args) == 0 {
+		return "", "", 0
+	}
+	name := filepath.Base(args[0])
+	if (name == "sh" || name == "bash") && len(args) > 2 && args[1] == "-c" {
+		return sh.script(args[2], stdin)
+	}
+	if f := sh.node.Commands[name]; f != nil {
+		return f(args[1:], stdin)
+	}
+	if name == "lvm" || name == "lvm.static" {
+		if len(args) == 1 {
+			return "", "  No command specified.\n", 3
+		}
+		args = args[1:]
+		name = args[0]
+	}
+	if f := simTools[name]; f != nil {
+		sh.node.mx.Lock()
+		defer sh.node.mx.Unlock()
+		return f(sh.node, args[1:], stdin)
+	}
+
+	switch name {
+	case "true":
+		return "", "", 0
+	case "false":
+		return "", "", 1
+	case "echo":
+		return strings.Join(args[1:], " ") + "\n", "", 0
+	case "hostname":
+		return sh.node.Name + "\n", "", 0
+	case "cloud-init":
+		return "\nstatus: done\n", "", 0
+	case "cd":
+		if len(args) > 1 {
+			sh.cwd = args[1]
+		}
+		return "", "", 0
+	}
+
+	if !sh.node.Shell {
+		return "", "sh: 1: " + name + ": not found\n", 127
+	}
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	c := exec.Command("sh", "-c", strings.Join(quoted, " "))
+	var stdout, stderr bytes.Buffer
+	c.Dir, c.Stdin, c.Stdout, c.Stderr = sh.cwd, strings.NewReader(stdin), &stdout, &stderr
+	err := c.Run()
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		return "", err.Error() + "\n", 126
+	}
+	return stdout.String(), stderr.String(), code
+}
+
+func hashMd5(in string) string {
+	sum := md5.Sum([]byte(in))
+	return hex.EncodeToString(sum[:])
+}
+
+// shellQuote quotes string for POSIX shell
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./=:,@%+") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// unwrapCommand strips sudo, timeout, env, nsenter and VAR=value prefixes
+func unwrapCommand(args []string) []string {
+	for len(args) > 0 {
+		switch filepath.Base(args[0]) {
+		case "sudo":
+			args = skipOpts(args[1:], "-u", "-g", "-C")
+		case "timeout":
+			args = skipOpts(args[1:], "-k", "-s", "--kill-after", "--signal")
+			if len(args) > 0 {
+				args = args[1:]
+			}
+		case "env":
+			args = skipOpts(args[1:], "-u", "-C")
+			for len(args) > 0 && strings.Contains(args[0], "=") {
+				args = args[1:]
+			}
+		case "nsenter", "nsenter.static":
+			args = skipOpts(args[1:], "-t", "--target", "-S", "-G")
+		default:
+			if strings.Contains(args[0], "=") && !strings.HasPrefix(args[0], "-") {
+				args = args[1:]
+				continue
+			}
+			return args
+		}
+	}
+	return args
+}
+
+// skipOpts skips leading options, withValue options take next argument
+func skipOpts(args []string, withValue ...string) []string {
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		if args[0] == "--" {
+			return args[1:]
+		}
+		for _, o := range withValue {
+			if args[0] == o {
+				args = args[1:]
+				break
+			}
+		}
+		if len(args) > 0 {
+			args = args[1:]
+		}
+	}
+	return args
+}
+
+// shellSplit splits POSIX shell command line to words and operators
+func shellSplit(s string) ([]string, error) {
+	var tokens []string
+	var sb strings.Builder
+	word := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'':
+			j := strings.IndexByte(s[i+1:], '\'')
+			if j < 0 {
+				return nil, errors.New("unterminated quoted string")
+			}
+			sb.WriteString(s[i+1 : i+1+j])
+			i += j + 1
+			word = true
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`", s[i+1]) >= 0 {
+					i++
+				}
+				sb.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New("unterminated quoted string")
+			}
+			word = true
+		case c == '\\' && i+1 < len(s):
+			i++
+			sb.WriteByte(s[i])
+			word = true
+		case c == ' ' || c == '\t' || c == '\n' || c == ';' || c == '&' || c == '|':
+			if word {
+				tokens = append(tokens, sb.String())
+				sb.Reset()
+				word = false
+			}
+			switch {
+			case c == ';' || c == '\n':
+				tokens = append(tokens, ";")
+			case (c == '&' || c == '|') && i+1 < len(s) && s[i+1] == c:
+				tokens = append(tokens, s[i:i+2])
+				i++
+			case c == '|':
+				tokens = append(tokens, "|")
+			case c == '&':
+				return nil, errors.New("background jobs are not supported")
+			}
+		default:
+			sb.WriteByte(c)
+			word = true
+		}
+	}
+	if word {
+		tokens = append(tokens, sb.String())
+	}
+	return tokens, nil
+}
+
+/*  Sizes  */
+
+// parseSimSize parses LVM size argument (500m, 1.5G, +1g, 2147483648b), default unit is MiB
+func parseSimSize(s string) (int64, error) {
+	s = strings.TrimLeft(s, "+-")
+	unit := int64(1 << 20)
+	if s != "" {
+		switch strings.ToLower(s[len(s)-1:]) {
+		case "b":
+			unit = 1
+		case "s":
+			unit = 512
+		case "k":
+			unit = 1 << 10
+		case "m":
+			unit = 1 << 20
+		case "g":
+			unit = 1 << 30
+		case "t":
+			unit = 1 << 40
+		case "p":
+			unit = 1 << 50
+		}
+		if s[len(s)-1] < '0' || s[len(s)-1] > '9' {
+			s = s[:len(s)-1]
+		}
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(v * float64(unit)), nil
+}
+
+// lsblkSize formats size like lsblk: 20G, 3.3G, 500M
+func lsblkSize(size int64) string {
+	v := float64(size)
+	units := "BKMGTPE"
+	i := 0
+	for v >= 1024 && i < len(units)-1 {
+		v /= 1024
+		i++
+	}
+	s := strconv.FormatFloat(v, 'f', 1, 64)
+	s = strings.TrimSuffix(s, ".0")
+	if i == 0 {
+		return s + "B"
+	}
+	return s + units[i:i+1]
+}
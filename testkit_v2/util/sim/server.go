@@ -0,0 +1,232 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sim
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	util "github.com/deckhouse/sds-e2e/util"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// Server is in-process SSH server of Node. Exec requests run in the node model, sftp
+// subsystem serves in-memory file system, direct-tcpip to local addresses makes it usable
+// as jump host of other servers
+//
+//	srv, _ := sim.StartServer(node, pubKey)
+//	defer srv.Close()
+//	util.PinHostKey(srv.Addr, srv.HostKey)
+//	client := util.GetSshClient("user", srv.Addr, keyPath)
+type Server struct {
+	Node    *Node
+	Addr    string
+	HostKey ssh.PublicKey
+
+	config   *ssh.ServerConfig
+	files    sftp.Handlers
+	listener net.Listener
+	mx       sync.Mutex
+	conns    []net.Conn
+	exec     int
+}
+
+// StartServer listens on ephemeral local port, clients authenticate with one of authorized keys
+func StartServer(node *Node, authorized ...ssh.PublicKey) (*Server, error) {
+	if len(authorized) == 0 {
+		return nil, errors.New("no authorized keys")
+	}
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for _, k := range authorized {
+				if bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown key for %s", meta.User())
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Node:     node,
+		Addr:     listener.Addr().String(),
+		HostKey:  signer.PublicKey(),
+		config:   config,
+		files:    sftp.InMemHandler(),
+		listener: listener,
+	}
+	go s.serve()
+	return s, nil
+}
+
+// Execs returns count of exec requests served
+func (s *Server) Execs() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.exec
+}
+
+// Drop breaks established connections, server keeps accepting new ones
+func (s *Server) Drop() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, c := range s.conns {
+		_ = c.Close()
+	}
+	s.conns = nil
+}
+
+// Close stops server and breaks its connections
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.Drop()
+	return err
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mx.Lock()
+		s.conns = append(s.conns, c)
+		s.mx.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	_, chans, reqs, err := ssh.NewServerConn(c, s.config)
+	if err != nil {
+		util.Debugf("sim %s: %s", s.Node.Name, err.Error())
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		switch nc.ChannelType() {
+		case "session":
+			ch, chReqs, err := nc.Accept()
+			if err != nil {
+				continue
+			}
+			go s.session(ch, chReqs)
+		case "direct-tcpip":
+			go s.forward(nc)
+		default:
+			_ = nc.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+}
+
+func (s *Server) session(ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+	for r := range reqs {
+		switch r.Type {
+		case "exec":
+			var payload struct{ Command string }
+			if err := ssh.Unmarshal(r.Payload, &payload); err != nil {
+				_ = r.Reply(false, nil)
+				continue
+			}
+			_ = r.Reply(true, nil)
+			go ssh.DiscardRequests(reqs)
+			s.mx.Lock()
+			s.exec++
+			s.mx.Unlock()
+
+			stdin, _ := io.ReadAll(ch)
+			stdout, stderr, code := s.Node.Exec(payload.Command, string(stdin))
+			_, _ = io.WriteString(ch, stdout)
+			_, _ = io.WriteString(ch.Stderr(), stderr)
+			_, _ = ch.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, uint32(code)))
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			if err := ssh.Unmarshal(r.Payload, &payload); err != nil || payload.Name != "sftp" {
+				_ = r.Reply(false, nil)
+				continue
+			}
+			_ = r.Reply(true, nil)
+			go ssh.DiscardRequests(reqs)
+			_ = sftp.NewRequestServer(ch, s.files).Serve()
+			return
+		default:
+			// pty-req, env, auth-agent-req@openssh.com
+			if r.WantReply {
+				_ = r.Reply(true, nil)
+			}
+		}
+	}
+}
+
+func (s *Server) forward(nc ssh.NewChannel) {
+	var p struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(nc.ExtraData(), &p); err != nil {
+		_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	if ip := net.ParseIP(p.Host); p.Host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		_ = nc.Reject(ssh.Prohibited, "only local addresses are forwarded")
+		return
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(p.Host, strconv.Itoa(int(p.Port))), 10*time.Second)
+	if err != nil {
+		_ = nc.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	ch, reqs, err := nc.Accept()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	go func() {
+		_, _ = io.Copy(ch, conn)
+		_ = ch.CloseWrite()
+	}()
+	_, _ = io.Copy(conn, ch)
+	_ = conn.Close()
+}
@@ -0,0 +1,306 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"slices"
+	"testing"
+
+	util "github.com/deckhouse/sds-e2e/util"
+	snc "github.com/deckhouse/sds-node-configurator/api/v1alpha1"
+	"golang.org/x/crypto/ssh"
+	"k8s.io/apimachinery/pkg/api/resource"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+const (
+	lvmStatic = "/opt/deckhouse/sds/bin/lvm.static"
+	simLvm    = "sudo " + lvmStatic
+)
+
+// exitStatus checks command error has exit status code
+func exitStatus(err error, code int) bool {
+	var exitErr utilexec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitStatus() == code
+}
+
+func TestSimLvmThick(t *testing.T) {
+	node := NewNode("worker-0")
+	node.AddDisk("sdb", 2<<30)
+	cluster := NewCluster("sim", []*Node{node})
+
+	if _, _, err := cluster.ExecNode(node.Name, []string{"sudo", lvmStatic, "vgcreate", "data", "/dev/sdb"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := cluster.ExecNodeRespContains(node.Name, simLvm+" vgdisplay --units B", []string{
+		"VG Name\\s+data",
+		"VG Size\\s+21[45]\\d{7} B",
+		"Alloc PE / Size[\\s\\d]+ 0 / 0 B",
+	}); err != nil {
+		t.Error(err.Error())
+	}
+	if err := cluster.ExecNodeRespContains(node.Name, simLvm+" pvdisplay --units B", []string{"PV Size\\s+21[45]\\d{7} B  /"}); err != nil {
+		t.Error(err.Error())
+	}
+	if err := cluster.ExecNodeRespContains(node.Name, "sudo /opt/deckhouse/bin/lsblk", []string{"sdb [\\d\\s:]* 2G\\s+0\\s+disk\\s*\\n"}); err != nil {
+		t.Error(err.Error())
+	}
+
+	if _, _, err := cluster.ExecNode(node.Name, []string{"sudo", lvmStatic, "lvcreate", "-L", "500m", "data"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := cluster.ExecNodeRespContains(node.Name, simLvm+" vgdisplay", []string{"Alloc PE / Size[\\s\\d]+ / 500.00 MiB"}); err != nil {
+		t.Error(err.Error())
+	}
+	if _, _, err := cluster.ExecNode(node.Name, []string{"sudo", lvmStatic, "lvcreate", "-L", "2g", "data"}); !exitStatus(err, 5) {
+		t.Errorf("lvcreate over VG size: %v", err)
+	}
+
+	if _, _, err := cluster.ExecNode(node.Name, []string{"sudo", lvmStatic, "vgremove", "-f", "data"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := cluster.ExecNodeRespNotContains(node.Name, simLvm+" vgdisplay", []string{"VG Name "}); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestSimLvmThin(t *testing.T) {
+	node := NewNode("worker-0")
+	node.AddDisk("sdb", 4<<30)
+	for _, cmd := range []string{
+		"vgcreate data /dev/sdb",
+		"lvcreate -L 1G -T data/tp1",
+		"lvcreate -L 2.33G -T data/tp2",
+		"lvcreate -V 1G -T data/tp1 -n thin-e2e-01",
+	} {
+		if _, stderr, code := node.Exec(simLvm+" "+cmd, ""); code != 0 {
+			t.Fatalf("%s: %s", cmd, stderr)
+		}
+	}
+	if err := node.SetLvUsage("data", "thin-e2e-01", 256<<20); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cluster := NewCluster("sim", []*Node{node})
+	if err := cluster.ExecNodeRespContains(node.Name, simLvm+" lvdisplay", []string{"LV Name\\s+thin-e2e-01", "LV Size\\s+1.00 GiB"}); err != nil {
+		t.Error(err.Error())
+	}
+	if err := cluster.ExecNodeRespContains(node.Name, simLvm+" vgdisplay --units B", []string{"Alloc PE / Size[\\s\\d]+ / 35[0-9]\\d{7} B"}); err != nil {
+		t.Error(err.Error())
+	}
+
+	stdout, _, err := cluster.ExecNode(node.Name, []string{"sudo", lvmStatic, "lvs", "--reportformat", "json", "--units", "b", "-o", "lv_name,lv_size,data_percent", "data/tp1"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	var report struct {
+		Report []struct {
+			Lv []map[string]string `json:"lv"`
+		} `json:"report"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatal(err.Error())
+	}
+	lv := report.Report[0].Lv[0]
+	if lv["lv_size"] != "1073741824B" || lv["data_percent"] != "25.00" {
+		t.Errorf("tp1 report: %v", lv)
+	}
+
+	if _, _, err := cluster.ExecNode(node.Name, []string{"sudo", lvmStatic, "lvremove", "-y", "/dev/data/thin-e2e-01"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := cluster.ExecNodeRespNotContains(node.Name, simLvm+" lvdisplay", []string{"thin-e2e-01"}); err != nil {
+		t.Error(err.Error())
+	}
+}
+
+func TestSimNodeLvm(t *testing.T) {
+	node := NewNode("worker-0")
+	node.AddDisk("sdb", 4<<30)
+	for _, cmd := range []string{
+		"vgcreate data /dev/sdb --addtag e2e",
+		"lvcreate -L 1G -T data/tp1",
+		"lvcreate -V 2G -T data/tp1 -n thin-e2e-01",
+		"lvcreate -L 500m -n lv1 data",
+	} {
+		if _, stderr, code := node.Exec(simLvm+" "+cmd, ""); code != 0 {
+			t.Fatalf("%s: %s", cmd, stderr)
+		}
+	}
+	if err := node.SetLvUsage("data", "thin-e2e-01", 256<<20); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	lvm, err := NewCluster("sim", []*Node{node}).GetNodeLvm(node.Name)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	pv := lvm.PV("/dev/sdb")
+	if pv == nil || pv.VgName != "data" || pv.DevSize != 4<<30 {
+		t.Fatalf("PV /dev/sdb: %+v", pv)
+	}
+	vg := lvm.VG("data")
+	if vg == nil {
+		t.Fatal("no VG data")
+	}
+	if vg.ExtentSize != 4<<20 || vg.Size != int64(vg.ExtentCount)*vg.ExtentSize || vg.PvCount != 1 || len(vg.Tags) != 1 {
+		t.Errorf("VG data: %+v", vg)
+	}
+	// 1G pool + 4M metadata + 4M spare + 500M
+	if vg.Allocated() != 1532<<20 {
+		t.Errorf("VG data allocated: %d", vg.Allocated())
+	}
+	if lv := lvm.LV("data", "lv1"); lv == nil || lv.Size != 500<<20 || lv.SegType != "linear" || lv.Path != "/dev/data/lv1" {
+		t.Errorf("LV lv1: %+v", lv)
+	}
+	tp := lvm.ThinPool("data", "tp1")
+	if tp == nil {
+		t.Fatal("no thin pool tp1")
+	}
+	if tp.Size != 1<<30 || tp.Allocated != 256<<20 || tp.MetadataSize != 4<<20 || len(tp.Thin) != 1 {
+		t.Errorf("thin pool tp1: %+v", tp)
+	}
+	if thin := tp.Thin[0]; thin.Name != "thin-e2e-01" || thin.Size != 2<<30 || thin.DataPercent != 12.5 {
+		t.Errorf("thin LV: %+v", thin)
+	}
+}
+
+func TestSimLsblkJson(t *testing.T) {
+	node := NewNode("worker-0")
+	node.AddDisk("sdb", 2<<30)
+	node.Exec(simLvm+" vgcreate data /dev/sdb && "+simLvm+" lvcreate -n lv1 -L 100m data", "")
+
+	stdout, stderr, code := node.Exec("lsblk --json --bytes -O /dev/sdb", "")
+	if code != 0 {
+		t.Fatal(stderr)
+	}
+	var blk struct {
+		BlockDevices []struct {
+			Path     string `json:"path"`
+			Size     int64  `json:"size"`
+			FsType   string `json:"fstype"`
+			Children []struct {
+				Name   string `json:"name"`
+				PkName string `json:"pkname"`
+			} `json:"children"`
+		} `json:"blockdevices"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &blk); err != nil {
+		t.Fatal(err.Error())
+	}
+	d := blk.BlockDevices[0]
+	if d.Path != "/dev/sdb" || d.Size != 2<<30 || d.FsType != "LVM2_member" || len(d.Children) != 1 ||
+		d.Children[0].Name != "data-lv1" || d.Children[0].PkName != "sdb" {
+		t.Errorf("lsblk sdb: %+v", d)
+	}
+}
+
+func TestSimBlockDevices(t *testing.T) {
+	node := NewNode("worker-0")
+	sdb := node.AddDisk("sdb", 2<<30)
+	sdc := node.AddDisk("sdc", 3<<30)
+	node.AddDisk("sdd", 1<<30)
+	node.Exec(simLvm+" vgcreate data /dev/sdc", "")
+
+	devs, err := NewCluster("sim", []*Node{node}).NodeBlockDevices(node.Name)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	bd := func(name string, d *Disk, size int64, consumable bool, fsType string) snc.BlockDevice {
+		b := snc.BlockDevice{}
+		b.Name = name
+		b.Status = snc.BlockDeviceStatus{NodeName: node.Name, Path: "/dev/" + d.Name, Type: "disk", FsType: fsType, Model: d.Model,
+			Serial: d.Serial, Wwn: d.Wwn, Size: *resource.NewQuantity(size, resource.BinarySI), Consumable: consumable}
+		return b
+	}
+	bds := []snc.BlockDevice{bd("dev-b", sdb, 2<<30, true, ""), bd("dev-c", sdc, 3<<30, false, "LVM2_member")}
+	if issues := util.CompareBlockDevices(node.Name, bds, devs); len(issues) != 1 || issues[0].Path != "/dev/sdd" || issues[0].Field != util.BdMissingCR {
+		t.Errorf("issues: %v", issues)
+	}
+
+	renamed := bd("dev-d", sdb, 1<<30, false, "")
+	renamed.Status.Path = "/dev/sdx"
+	bds = []snc.BlockDevice{renamed, bd("dev-c", sdc, 3<<30, false, ""), bd("dev-y", &Disk{Name: "sdy"}, 1<<30, true, "")}
+	var got []string
+	for _, issue := range util.CompareBlockDevices(node.Name, bds, devs) {
+		got = append(got, issue.BD+":"+issue.Field)
+	}
+	want := []string{"dev-d:path", "dev-d:size", "dev-d:consumable", "dev-c:fstype", "dev-y:" + util.BdMissingOnNode, ":" + util.BdMissingCR}
+	if !slices.Equal(got, want) {
+		t.Errorf("issues: %v != %v", got, want)
+	}
+}
+
+func TestSimSsh(t *testing.T) {
+	mode := util.HostKeyMode
+	util.HostKeyMode = util.HostKeyStrict
+	t.Cleanup(func() { util.HostKeyMode = mode })
+
+	dir := t.TempDir()
+	if err := util.GenerateSSHKeys(util.KeyTypeEd25519, dir+"/id", dir+"/id.pub"); err != nil {
+		t.Fatal(err.Error())
+	}
+	pub, err := os.ReadFile(dir + "/id.pub")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(pub)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, err := StartServer(NewNode("open")); err == nil {
+		t.Error("server without authorized keys started")
+	}
+
+	node := NewNode("worker-0")
+	node.AddDisk("sdb", 2<<30)
+	var srv [2]*Server
+	for i, n := range []*Node{NewNode("jump"), node} {
+		s, err := StartServer(n, key)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		t.Cleanup(func() { _ = s.Close() })
+		_ = util.PinHostKey(s.Addr, s.HostKey)
+		srv[i] = s
+	}
+
+	jump := util.GetSshClient("user", srv[0].Addr, dir+"/id")
+	defer jump.Close()
+	client := jump.GetFwdClient("user", srv[1].Addr, dir+"/id")
+	defer client.Close()
+
+	res, err := client.Run(context.Background(), lvmStatic+" vgcreate data /dev/sdb && vgs data", util.ExecOpts{Sudo: true})
+	if err != nil || !regexp.MustCompile(`data\s+1\s+0\s+0 wz--n- <2.00g <2.00g`).MatchString(res.Stdout) {
+		t.Errorf("vgcreate: %v\n%s", err, res.Output())
+	}
+	res, _ = client.Run(context.Background(), "vgs missing", util.ExecOpts{})
+	if res.ExitCode != 5 {
+		t.Errorf("vgs missing exit code: %d", res.ExitCode)
+	}
+
+	srv[1].Drop()
+	out, err := client.Exec("hostname")
+	if err != nil || out != "worker-0\n" {
+		t.Errorf("exec after drop: %v %q", err, out)
+	}
+}
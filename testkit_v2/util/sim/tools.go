@@ -0,0 +1,1734 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sim
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	simExtent  = 4 << 20 // LVM default physical extent
+	simPeStart = 1 << 20 // LVM default data alignment
+)
+
+type simTool func(n *Node, args []string, stdin string) (stdout, stderr string, code int)
+
+// simTools are emulated node tools, called under node lock
+var simTools = map[string]simTool{
+	"lsblk":     simLsblk,
+	"blockdev":  simBlockdev,
+	"pvs":       simPvs,
+	"vgs":       simVgs,
+	"lvs":       simLvs,
+	"pvdisplay": simPvdisplay,
+	"vgdisplay": simVgdisplay,
+	"lvdisplay": simLvdisplay,
+	"pvcreate":  simPvcreate,
+	"pvremove":  simPvremove,
+	"pvresize":  simPvresize,
+	"vgcreate":  simVgcreate,
+	"vgextend":  simVgextend,
+	"vgreduce":  simVgreduce,
+	"vgremove":  simVgremove,
+	"vgchange":  simVgchange,
+	"lvcreate":  simLvcreate,
+	"lvremove":  simLvremove,
+	"lvextend":  simLvresize,
+	"lvresize":  simLvresize,
+}
+
+/*  LVM model  */
+
+type simPv struct {
+	dev     *Disk
+	uuid    string
+	size    int64 // device size at pvcreate/pvresize
+	vg      *simVg
+	missing bool
+}
+
+type simVg struct {
+	name  string
+	uuid  string
+	pvs   []*simPv
+	lvs   []*simLv
+	tags  []string
+	seqNo int
+}
+
+type simSeg struct {
+	pv      *simPv
+	extents int
+}
+
+type simLv struct {
+	name    string
+	uuid    string
+	vg      *simVg
+	kind    string // linear, thin-pool, thin, tmeta, pmspare
+	segs    []simSeg
+	virt    int64 // thin volume size
+	used    int64 // thin volume mapped bytes
+	pool    *simLv
+	meta    *simLv
+	dm      []int // device mapper minors
+	created time.Time
+}
+
+func (pv *simPv) path() string {
+	if pv.missing {
+		return "[unknown]"
+	}
+	return "/dev/" + pv.dev.Name
+}
+
+func (pv *simPv) peCount() int {
+	if pv.vg == nil {
+		return 0
+	}
+	return int((pv.size - simPeStart) / simExtent)
+}
+
+func (pv *simPv) allocated() int {
+	if pv.vg == nil {
+		return 0
+	}
+	used := 0
+	for _, lv := range pv.vg.lvs {
+		for _, s := range lv.segs {
+			if s.pv == pv {
+				used += s.extents
+			}
+		}
+	}
+	return used
+}
+
+func (vg *simVg) extents() int {
+	ext := 0
+	for _, pv := range vg.pvs {
+		ext += pv.peCount()
+	}
+	return ext
+}
+
+func (vg *simVg) allocated() int {
+	ext := 0
+	for _, lv := range vg.lvs {
+		ext += lv.extents()
+	}
+	return ext
+}
+
+func (vg *simVg) partial() bool {
+	for _, pv := range vg.pvs {
+		if pv.missing {
+			return true
+		}
+	}
+	return false
+}
+
+func (vg *simVg) lv(name string) *simLv {
+	for _, lv := range vg.lvs {
+		if lv.name == name {
+			return lv
+		}
+	}
+	return nil
+}
+
+func (vg *simVg) visibleLvs() []*simLv {
+	var lvs []*simLv
+	for _, lv := range vg.lvs {
+		if !lv.hidden() {
+			lvs = append(lvs, lv)
+		}
+	}
+	return lvs
+}
+
+// allocate takes extents from PVs in order
+func (vg *simVg) allocate(extents int) ([]simSeg, error) {
+	if free := vg.extents() - vg.allocated(); extents > free {
+		return nil, fmt.Errorf("Volume group \"%s\" has insufficient free space (%d extents): %d required.", vg.name, free, extents)
+	}
+	var segs []simSeg
+	for _, pv := range vg.pvs {
+		if extents == 0 {
+			break
+		}
+		if pv.missing {
+			continue
+		}
+		take := min(pv.peCount()-pv.allocated(), extents)
+		if take > 0 {
+			segs = append(segs, simSeg{pv, take})
+			extents -= take
+		}
+	}
+	return segs, nil
+}
+
+func (lv *simLv) extents() int {
+	ext := 0
+	for _, s := range lv.segs {
+		ext += s.extents
+	}
+	return ext
+}
+
+func (lv *simLv) size() int64 {
+	if lv.kind == "thin" {
+		return lv.virt
+	}
+	return int64(lv.extents()) * simExtent
+}
+
+func (lv *simLv) hidden() bool {
+	return lv.kind == "tmeta" || lv.kind == "pmspare"
+}
+
+func (lv *simLv) displayName() string {
+	if lv.hidden() {
+		return "[" + lv.name + "]"
+	}
+	return lv.name
+}
+
+func (lv *simLv) attr() string {
+	switch lv.kind {
+	case "thin-pool":
+		return "twi-a-tz--"
+	case "thin":
+		return "Vwi-a-tz--"
+	case "tmeta":
+		return "ewi-ao----"
+	case "pmspare":
+		return "ewi-------"
+	}
+	return "-wi-a-----"
+}
+
+func (lv *simLv) dataPercent() string {
+	switch lv.kind {
+	case "thin-pool":
+		used := int64(0)
+		for _, t := range lv.vg.lvs {
+			if t.pool == lv {
+				used += t.used
+			}
+		}
+		return fmt.Sprintf("%.2f", float64(used)*100/float64(lv.size()))
+	case "thin":
+		return fmt.Sprintf("%.2f", float64(lv.used)*100/float64(lv.virt))
+	}
+	return ""
+}
+
+func (lv *simLv) metaPercent() string {
+	if lv.kind != "thin-pool" {
+		return ""
+	}
+	// empty pool uses 448KiB of metadata
+	return fmt.Sprintf("%.2f", float64(448<<10)*100/float64(lv.meta.size()))
+}
+
+func (lv *simLv) dev() string {
+	return fmt.Sprintf("253:%d", lv.dm[len(lv.dm)-1])
+}
+
+func dmName(vg, lv string) string {
+	return strings.ReplaceAll(vg, "-", "--") + "-" + strings.ReplaceAll(lv, "-", "--")
+}
+
+func (n *Node) pvs() []*simPv {
+	var pvs []*simPv
+	for _, d := range n.disks {
+		if d.pv != nil {
+			pvs = append(pvs, d.pv)
+		}
+	}
+	for _, vg := range n.vgs {
+		for _, pv := range vg.pvs {
+			if pv.missing {
+				pvs = append(pvs, pv)
+			}
+		}
+	}
+	return pvs
+}
+
+func (n *Node) vg(name string) *simVg {
+	for _, vg := range n.vgs {
+		if vg.name == name {
+			return vg
+		}
+	}
+	return nil
+}
+
+func (n *Node) dmMinors(count int) []int {
+	m := make([]int, count)
+	for i := range m {
+		m[i] = n.dmIdx
+		n.dmIdx++
+	}
+	return m
+}
+
+// SetLvUsage sets mapped bytes of thin volume, as if data was written to it
+func (n *Node) SetLvUsage(vgName, lvName string, used int64) error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	vg := n.vg(vgName)
+	if vg == nil {
+		return fmt.Errorf("no VG %s on %s", vgName, n.Name)
+	}
+	lv := vg.lv(lvName)
+	if lv == nil || lv.kind != "thin" {
+		return fmt.Errorf("no thin LV %s/%s on %s", vgName, lvName, n.Name)
+	}
+	if used > lv.virt {
+		return fmt.Errorf("usage %d > LV size %d", used, lv.virt)
+	}
+	lv.used = used
+	return nil
+}
+
+/*  Arguments  */
+
+// simOptSpec is options of emulated tools: short:long, `=` suffix means option with value
+const simOptSpec = "L:size= l:extents= n:name= V:virtualsize= T:thin type= thinpool= y:yes f:force " +
+	"units= o:options= O:sort= reportformat= nosuffix noheadings a:all separator= addtag= deltag= " +
+	"s:physicalextentsize= r:resizefs A:autobackup= Z:zero= W:wipesignatures= v:verbose q:quiet " +
+	"b:bytes J:json p:paths d:nodeps list O:output-all getsize64 getsize ay an activate= dataalignment= setphysicalvolumesize="
+
+type simArgs struct {
+	opts map[string]string
+	pos  []string
+}
+
+func (a simArgs) has(name string) bool {
+	_, ok := a.opts[name]
+	return ok
+}
+
+func parseSimArgs(tool string, args []string) (simArgs, error) {
+	short, long, withValue := map[string]string{}, map[string]bool{}, map[string]bool{}
+	for _, o := range strings.Fields(simOptSpec) {
+		name := strings.TrimSuffix(o, "=")
+		if s, l, ok := strings.Cut(name, ":"); ok {
+			short[s], name = l, l
+		}
+		long[name], withValue[name] = true, strings.HasSuffix(o, "=")
+	}
+	// lsblk -O means all columns, LVM -O means sort
+	if tool == "lsblk" {
+		short["O"] = "output-all"
+		short["l"] = "list"
+		short["o"] = "output"
+		long["output"], withValue["output"] = true, true
+	}
+
+	a := simArgs{opts: map[string]string{}}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var name, value string
+		hasValue := false
+		switch {
+		case arg == "--":
+			a.pos = append(a.pos, args[i+1:]...)
+			return a, nil
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue = strings.Cut(arg[2:], "=")
+			if !long[name] {
+				return a, fmt.Errorf("%s: unrecognized option '%s'", tool, arg)
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			if long[arg[1:]] {
+				name = arg[1:]
+				break
+			}
+			name = short[arg[1:2]]
+			if name == "" {
+				return a, fmt.Errorf("%s: invalid option -- '%s'", tool, arg[1:2])
+			}
+			if len(arg) > 2 {
+				if !withValue[name] {
+					// combined flags: -fy
+					if _, err := parseSimArgs(tool, []string{"-" + arg[2:]}); err != nil {
+						return a, err
+					}
+					for _, c := range arg[2:] {
+						a.opts[short[string(c)]] = ""
+					}
+				} else {
+					value, hasValue = arg[2:], true
+				}
+			}
+		default:
+			a.pos = append(a.pos, arg)
+			continue
+		}
+		if withValue[name] && !hasValue {
+			if i+1 == len(args) {
+				return a, fmt.Errorf("%s: option '%s' requires an argument", tool, arg)
+			}
+			i++
+			value = args[i]
+		}
+		a.opts[name] = value
+	}
+	return a, nil
+}
+
+func simFail(code int, format string, args ...any) (string, string, int) {
+	return "", "  " + fmt.Sprintf(format, args...) + "\n", code
+}
+
+/*  Sizes  */
+
+// lvmUnit returns size in units with unit suffix and display name. Lower case units are
+// binary, upper case are SI, h selects unit by size
+func lvmUnit(size int64, units string) (float64, string, string) {
+	const letters = "bkmgtpe"
+	if units == "" || strings.ToLower(units) == "h" {
+		units = "b"
+		for i := len(letters) - 2; i > 0; i-- {
+			if size >= 1<<(10*i) {
+				units = letters[i : i+1]
+				break
+			}
+		}
+	}
+	exp := strings.Index(letters, strings.ToLower(units))
+	if exp < 0 {
+		exp, units = 0, "b"
+	}
+	base, names := 1024.0, []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	if exp > 0 && units != strings.ToLower(units) {
+		base, names = 1000, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	}
+	return float64(size) / math.Pow(base, float64(exp)), units, names[exp]
+}
+
+// lvmRound formats value with 2 decimals, `<` means rounded up like LVM does
+func lvmRound(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	if r, _ := strconv.ParseFloat(s, 64); r > v+1e-9 {
+		return "<" + s
+	}
+	return s
+}
+
+// lvmReportSize formats size for pvs, vgs, lvs: <2.00g, 500.00m, 2143289344B
+func lvmReportSize(size int64, units string, suffix bool) string {
+	v, u, _ := lvmUnit(size, units)
+	if strings.ToLower(u) == "b" {
+		if !suffix {
+			return strconv.FormatInt(size, 10)
+		}
+		return strconv.FormatInt(size, 10) + "B"
+	}
+	if !suffix {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	if size == 0 {
+		return "0 "
+	}
+	return lvmRound(v) + u
+}
+
+// lvmDisplaySize formats size for *display: <2.00 GiB, 2143289344 B
+func lvmDisplaySize(size int64, units string) string {
+	v, u, name := lvmUnit(size, units)
+	if strings.ToLower(u) == "b" {
+		return strconv.FormatInt(size, 10) + " B "
+	}
+	if size == 0 {
+		return "0   "
+	}
+	return lvmRound(v) + " " + name
+}
+
+/*  Reports  */
+
+const (
+	simStr = iota
+	simNum
+	simSize
+	simPct
+)
+
+type simVal struct {
+	kind int
+	s    string
+	n    int64
+}
+
+type simField[T any] struct {
+	head string
+	get  func(T) simVal
+}
+
+func strVal(s string) simVal    { return simVal{kind: simStr, s: s} }
+func numVal(n int) simVal       { return simVal{kind: simNum, n: int64(n)} }
+func sizeVal(n int64) simVal    { return simVal{kind: simSize, n: n} }
+func tagsVal(t []string) simVal { return strVal(strings.Join(t, ",")) }
+func pctVal(s string) simVal    { return simVal{kind: simPct, s: s} }
+
+var simPvFields = map[string]simField[*simPv]{
+	"pv_name": {"PV", func(pv *simPv) simVal { return strVal(pv.path()) }},
+	"vg_name": {"VG", func(pv *simPv) simVal {
+		if pv.vg == nil {
+			return strVal("")
+		}
+		return strVal(pv.vg.name)
+	}},
+	"pv_fmt": {"Fmt", func(*simPv) simVal { return strVal("lvm2") }},
+	"pv_attr": {"Attr", func(pv *simPv) simVal {
+		switch {
+		case pv.missing:
+			return strVal("a-m")
+		case pv.vg != nil:
+			return strVal("a--")
+		}
+		return strVal("---")
+	}},
+	"pv_size": {"PSize", func(pv *simPv) simVal {
+		if pv.vg == nil {
+			return sizeVal(pv.size)
+		}
+		return sizeVal(int64(pv.peCount()) * simExtent)
+	}},
+	"pv_free": {"PFree", func(pv *simPv) simVal {
+		if pv.vg == nil {
+			return sizeVal(pv.size)
+		}
+		return sizeVal(int64(pv.peCount()-pv.allocated()) * simExtent)
+	}},
+	"pv_used":           {"Used", func(pv *simPv) simVal { return sizeVal(int64(pv.allocated()) * simExtent) }},
+	"pv_uuid":           {"PV UUID", func(pv *simPv) simVal { return strVal(pv.uuid) }},
+	"dev_size":          {"DevSize", func(pv *simPv) simVal { return sizeVal(pv.dev.Size) }},
+	"pe_start":          {"1st PE", func(*simPv) simVal { return sizeVal(simPeStart) }},
+	"pv_pe_count":       {"PE", func(pv *simPv) simVal { return numVal(pv.peCount()) }},
+	"pv_pe_alloc_count": {"Alloc", func(pv *simPv) simVal { return numVal(pv.allocated()) }},
+	"pv_tags":           {"PV Tags", func(*simPv) simVal { return strVal("") }},
+	"vg_uuid": {"VG UUID", func(pv *simPv) simVal {
+		if pv.vg == nil {
+			return strVal("")
+		}
+		return strVal(pv.vg.uuid)
+	}},
+}
+
+var simVgFields = map[string]simField[*simVg]{
+	"vg_name":    {"VG", func(vg *simVg) simVal { return strVal(vg.name) }},
+	"pv_count":   {"#PV", func(vg *simVg) simVal { return numVal(len(vg.pvs)) }},
+	"lv_count":   {"#LV", func(vg *simVg) simVal { return numVal(len(vg.visibleLvs())) }},
+	"snap_count": {"#SN", func(*simVg) simVal { return numVal(0) }},
+	"vg_attr": {"Attr", func(vg *simVg) simVal {
+		if vg.partial() {
+			return strVal("wz-pn-")
+		}
+		return strVal("wz--n-")
+	}},
+	"vg_size":         {"VSize", func(vg *simVg) simVal { return sizeVal(int64(vg.extents()) * simExtent) }},
+	"vg_free":         {"VFree", func(vg *simVg) simVal { return sizeVal(int64(vg.extents()-vg.allocated()) * simExtent) }},
+	"vg_uuid":         {"VG UUID", func(vg *simVg) simVal { return strVal(vg.uuid) }},
+	"vg_extent_size":  {"Ext", func(*simVg) simVal { return sizeVal(simExtent) }},
+	"vg_extent_count": {"#Ext", func(vg *simVg) simVal { return numVal(vg.extents()) }},
+	"vg_free_count":   {"Free", func(vg *simVg) simVal { return numVal(vg.extents() - vg.allocated()) }},
+	"vg_tags":         {"VG Tags", func(vg *simVg) simVal { return tagsVal(vg.tags) }},
+	"vg_seqno":        {"Seq", func(vg *simVg) simVal { return numVal(vg.seqNo) }},
+}
+
+var simLvFields = map[string]simField[*simLv]{
+	"lv_name": {"LV", func(lv *simLv) simVal { return strVal(lv.displayName()) }},
+	"vg_name": {"VG", func(lv *simLv) simVal { return strVal(lv.vg.name) }},
+	"lv_attr": {"Attr", func(lv *simLv) simVal { return strVal(lv.attr()) }},
+	"lv_size": {"LSize", func(lv *simLv) simVal { return sizeVal(lv.size()) }},
+	"pool_lv": {"Pool", func(lv *simLv) simVal {
+		if lv.pool == nil {
+			return strVal("")
+		}
+		return strVal(lv.pool.name)
+	}},
+	"origin":           {"Origin", func(*simLv) simVal { return strVal("") }},
+	"data_percent":     {"Data%", func(lv *simLv) simVal { return pctVal(lv.dataPercent()) }},
+	"metadata_percent": {"Meta%", func(lv *simLv) simVal { return pctVal(lv.metaPercent()) }},
+	"move_pv":          {"Move", func(*simLv) simVal { return strVal("") }},
+	"mirror_log":       {"Log", func(*simLv) simVal { return strVal("") }},
+	"copy_percent":     {"Cpy%Sync", func(*simLv) simVal { return strVal("") }},
+	"convert_lv":       {"Convert", func(*simLv) simVal { return strVal("") }},
+	"lv_uuid":          {"LV UUID", func(lv *simLv) simVal { return strVal(lv.uuid) }},
+	"lv_path": {"Path", func(lv *simLv) simVal {
+		if lv.hidden() {
+			return strVal("")
+		}
+		return strVal("/dev/" + lv.vg.name + "/" + lv.name)
+	}},
+	"lv_dm_path":   {"DMPath", func(lv *simLv) simVal { return strVal("/dev/mapper/" + dmName(lv.vg.name, lv.name)) }},
+	"lv_full_name": {"LV", func(lv *simLv) simVal { return strVal(lv.vg.name + "/" + lv.name) }},
+	"lv_tags":      {"LV Tags", func(*simLv) simVal { return strVal("") }},
+	"segtype":      {"Type", func(lv *simLv) simVal { return strVal(strings.Replace(lv.kind, "tmeta", "linear", 1)) }},
+	"lv_active":    {"Active", func(*simLv) simVal { return strVal("active") }},
+	"lv_metadata_size": {"MSize", func(lv *simLv) simVal {
+		if lv.meta == nil {
+			return strVal("")
+		}
+		return sizeVal(lv.meta.size())
+	}},
+	"devices": {"Devices", func(lv *simLv) simVal {
+		var devs []string
+		start := map[*simPv]int{}
+		for _, l := range lv.vg.lvs {
+			for _, s := range l.segs {
+				if l == lv {
+					devs = append(devs, fmt.Sprintf("%s(%d)", s.pv.path(), start[s.pv]))
+				}
+				start[s.pv] += s.extents
+			}
+		}
+		if lv.pool != nil {
+			devs = append(devs, lv.pool.name+"_tdata(0)")
+		}
+		return strVal(strings.Join(devs, ","))
+	}},
+}
+
+var (
+	simPvDefault = "pv_name,vg_name,pv_fmt,pv_attr,pv_size,pv_free"
+	simVgDefault = "vg_name,pv_count,lv_count,snap_count,vg_attr,vg_size,vg_free"
+	simLvDefault = "lv_name,vg_name,lv_attr,lv_size,pool_lv,origin,data_percent,metadata_percent,move_pv,mirror_log,copy_percent,convert_lv"
+)
+
+// simReport prints rows like pvs/vgs/lvs: aligned columns or `--reportformat json`
+func simReport[T any](key, prefix string, rows []T, fields map[string]simField[T], defaults string, a simArgs) (string, string, int) {
+	names := strings.Split(defaults, ",")
+	if o, ok := a.opts["options"]; ok {
+		if strings.HasPrefix(o, "+") {
+			o = o[1:]
+		} else {
+			names = nil
+		}
+		for _, f := range strings.Split(o, ",") {
+			names = append(names, strings.TrimSpace(f))
+		}
+	}
+	for i, f := range names {
+		if _, ok := fields[f]; !ok {
+			if _, ok := fields[prefix+f]; !ok {
+				return simFail(5, "Unrecognised field: %s", f)
+			}
+			names[i] = prefix + f
+		}
+	}
+	units := a.opts["units"]
+	suffix := !a.has("nosuffix")
+	cells := make([][]string, len(rows))
+	for r, row := range rows {
+		for _, f := range names {
+			v := fields[f].get(row)
+			switch v.kind {
+			case simNum:
+				cells[r] = append(cells[r], strconv.FormatInt(v.n, 10))
+			case simSize:
+				cells[r] = append(cells[r], lvmReportSize(v.n, units, suffix))
+			default:
+				cells[r] = append(cells[r], v.s)
+			}
+		}
+	}
+
+	var sb strings.Builder
+	if strings.HasPrefix(a.opts["reportformat"], "json") {
+		sb.WriteString("  {\n      \"report\": [\n          {\n              \"" + key + "\": [\n")
+		for r := range rows {
+			var kv []string
+			for i, f := range names {
+				v, _ := json.Marshal(cells[r][i])
+				kv = append(kv, fmt.Sprintf("\"%s\":%s", f, v))
+			}
+			sb.WriteString("                  {" + strings.Join(kv, ", ") + "}")
+			if r < len(rows)-1 {
+				sb.WriteString(",")
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("              ]\n          }\n      ]\n  }\n")
+		return sb.String(), "", 0
+	}
+
+	if sep, ok := a.opts["separator"]; ok {
+		if !a.has("noheadings") {
+			var heads []string
+			for _, f := range names {
+				heads = append(heads, fields[f].head)
+			}
+			sb.WriteString("  " + strings.Join(heads, sep) + "\n")
+		}
+		for _, row := range cells {
+			sb.WriteString("  " + strings.Join(row, sep) + "\n")
+		}
+		return sb.String(), "", 0
+	}
+
+	widths := make([]int, len(names))
+	for i, f := range names {
+		if !a.has("noheadings") {
+			widths[i] = len(fields[f].head)
+		}
+		for _, row := range cells {
+			widths[i] = max(widths[i], len(row[i]))
+		}
+	}
+	line := func(vals []string) {
+		sb.WriteString(" ")
+		for i, v := range vals {
+			if fields[names[i]].get(rows[0]).kind != simStr {
+				fmt.Fprintf(&sb, " %*s", widths[i], v)
+			} else {
+				fmt.Fprintf(&sb, " %-*s", widths[i], v)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	if !a.has("noheadings") && len(rows) > 0 {
+		var heads []string
+		for _, f := range names {
+			heads = append(heads, fields[f].head)
+		}
+		line(heads)
+	}
+	for _, row := range cells {
+		line(row)
+	}
+	return sb.String(), "", 0
+}
+
+func simPvs(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("pvs", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	pvs := n.pvs()
+	if len(a.pos) > 0 {
+		var sel []*simPv
+		for _, p := range a.pos {
+			i := slices.IndexFunc(pvs, func(pv *simPv) bool { return pv.path() == p })
+			if i < 0 {
+				return simFail(5, "Failed to find physical volume \"%s\".", p)
+			}
+			sel = append(sel, pvs[i])
+		}
+		pvs = sel
+	}
+	return simReport("pv", "pv_", pvs, simPvFields, simPvDefault, a)
+}
+
+func (n *Node) selectVgs(names []string) ([]*simVg, string, int) {
+	if len(names) == 0 {
+		return n.vgs, "", 0
+	}
+	var vgs []*simVg
+	for _, name := range names {
+		vg := n.vg(strings.TrimPrefix(name, "/dev/"))
+		if vg == nil {
+			_, stderr, code := simFail(5, "Volume group \"%s\" not found\n  Cannot process volume group %s", name, name)
+			return nil, stderr, code
+		}
+		vgs = append(vgs, vg)
+	}
+	return vgs, "", 0
+}
+
+func simVgs(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("vgs", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	vgs, stderr, code := n.selectVgs(a.pos)
+	if code != 0 {
+		return "", stderr, code
+	}
+	return simReport("vg", "vg_", vgs, simVgFields, simVgDefault, a)
+}
+
+// selectLvs finds LVs by vg, vg/lv or /dev/vg/lv
+func (n *Node) selectLvs(names []string, all bool) ([]*simLv, string, int) {
+	var lvs []*simLv
+	if len(names) == 0 {
+		for _, vg := range n.vgs {
+			for _, lv := range vg.lvs {
+				if all || !lv.hidden() {
+					lvs = append(lvs, lv)
+				}
+			}
+		}
+		return lvs, "", 0
+	}
+	for _, name := range names {
+		vgName, lvName, isLv := strings.Cut(strings.TrimPrefix(name, "/dev/"), "/")
+		vg := n.vg(vgName)
+		if vg == nil {
+			_, stderr, code := simFail(5, "Volume group \"%s\" not found\n  Cannot process volume group %s", vgName, vgName)
+			return nil, stderr, code
+		}
+		if !isLv {
+			for _, lv := range vg.lvs {
+				if all || !lv.hidden() {
+					lvs = append(lvs, lv)
+				}
+			}
+			continue
+		}
+		lv := vg.lv(lvName)
+		if lv == nil {
+			_, stderr, code := simFail(5, "Failed to find logical volume \"%s/%s\"", vgName, lvName)
+			return nil, stderr, code
+		}
+		lvs = append(lvs, lv)
+	}
+	return lvs, "", 0
+}
+
+func simLvs(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("lvs", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	lvs, stderr, code := n.selectLvs(a.pos, a.has("all"))
+	if code != 0 {
+		return "", stderr, code
+	}
+	return simReport("lv", "lv_", lvs, simLvFields, simLvDefault, a)
+}
+
+/*  Display  */
+
+func simPvdisplay(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("pvdisplay", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	units := a.opts["units"]
+	var sb strings.Builder
+	for _, pv := range n.pvs() {
+		if len(a.pos) > 0 && !slices.Contains(a.pos, pv.path()) {
+			continue
+		}
+		vgName, alloc, peSize := "", "NO", "0"
+		if pv.vg == nil {
+			fmt.Fprintf(&sb, "  \"%s\" is a new physical volume of \"%s\"\n  --- NEW Physical volume ---\n", pv.path(), lvmDisplaySize(pv.size, units))
+		} else {
+			vgName, alloc, peSize = pv.vg.name, "yes ", lvmDisplaySize(simExtent, units)
+			sb.WriteString("  --- Physical volume ---\n")
+		}
+		pvSize := lvmDisplaySize(pv.size, units)
+		if pv.vg != nil {
+			pvSize += " / not usable " + lvmDisplaySize(pv.size-int64(pv.peCount())*simExtent, units)
+		}
+		fmt.Fprintf(&sb, "  PV Name               %s\n", pv.path())
+		fmt.Fprintf(&sb, "  VG Name               %s\n", vgName)
+		fmt.Fprintf(&sb, "  PV Size               %s\n", pvSize)
+		fmt.Fprintf(&sb, "  Allocatable           %s\n", alloc)
+		fmt.Fprintf(&sb, "  PE Size               %s\n", peSize)
+		fmt.Fprintf(&sb, "  Total PE              %d\n", pv.peCount())
+		fmt.Fprintf(&sb, "  Free PE               %d\n", pv.peCount()-pv.allocated())
+		fmt.Fprintf(&sb, "  Allocated PE          %d\n", pv.allocated())
+		fmt.Fprintf(&sb, "  PV UUID               %s\n   \n", pv.uuid)
+	}
+	return sb.String(), "", 0
+}
+
+func simVgdisplay(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("vgdisplay", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	vgs, stderr, code := n.selectVgs(a.pos)
+	if code != 0 {
+		return "", stderr, code
+	}
+	units := a.opts["units"]
+	var sb strings.Builder
+	for _, vg := range vgs {
+		act := 0
+		for _, pv := range vg.pvs {
+			if !pv.missing {
+				act++
+			}
+		}
+		alloc, free := vg.allocated(), vg.extents()-vg.allocated()
+		sb.WriteString("  --- Volume group ---\n")
+		fmt.Fprintf(&sb, "  VG Name               %s\n", vg.name)
+		sb.WriteString("  System ID             \n  Format                lvm2\n")
+		fmt.Fprintf(&sb, "  Metadata Areas        %d\n", act)
+		fmt.Fprintf(&sb, "  Metadata Sequence No  %d\n", vg.seqNo)
+		sb.WriteString("  VG Access             read/write\n  VG Status             resizable\n  MAX LV                0\n")
+		fmt.Fprintf(&sb, "  Cur LV                %d\n", len(vg.visibleLvs()))
+		sb.WriteString("  Open LV               0\n  Max PV                0\n")
+		fmt.Fprintf(&sb, "  Cur PV                %d\n", len(vg.pvs))
+		fmt.Fprintf(&sb, "  Act PV                %d\n", act)
+		fmt.Fprintf(&sb, "  VG Size               %s\n", lvmDisplaySize(int64(vg.extents())*simExtent, units))
+		fmt.Fprintf(&sb, "  PE Size               %s\n", lvmDisplaySize(simExtent, units))
+		fmt.Fprintf(&sb, "  Total PE              %d\n", vg.extents())
+		fmt.Fprintf(&sb, "  Alloc PE / Size       %d / %s\n", alloc, lvmDisplaySize(int64(alloc)*simExtent, units))
+		fmt.Fprintf(&sb, "  Free  PE / Size       %d / %s\n", free, lvmDisplaySize(int64(free)*simExtent, units))
+		fmt.Fprintf(&sb, "  VG UUID               %s\n   \n", vg.uuid)
+	}
+	return sb.String(), "", 0
+}
+
+func simLvdisplay(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("lvdisplay", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	lvs, stderr, code := n.selectLvs(a.pos, a.has("all"))
+	if code != 0 {
+		return "", stderr, code
+	}
+	units := a.opts["units"]
+	var sb strings.Builder
+	for _, lv := range lvs {
+		sb.WriteString("  --- Logical volume ---\n")
+		if lv.kind != "thin-pool" && !lv.hidden() {
+			fmt.Fprintf(&sb, "  LV Path                /dev/%s/%s\n", lv.vg.name, lv.name)
+		}
+		fmt.Fprintf(&sb, "  LV Name                %s\n", lv.name)
+		fmt.Fprintf(&sb, "  VG Name                %s\n", lv.vg.name)
+		fmt.Fprintf(&sb, "  LV UUID                %s\n", lv.uuid)
+		if lv.kind == "thin-pool" {
+			sb.WriteString("  LV Write Access        read/write (activated read only)\n")
+		} else {
+			sb.WriteString("  LV Write Access        read/write\n")
+		}
+		fmt.Fprintf(&sb, "  LV Creation host, time %s, %s\n", n.Name, lv.created.Format("2006-01-02 15:04:05 -0700"))
+		switch lv.kind {
+		case "thin-pool":
+			fmt.Fprintf(&sb, "  LV Pool metadata       %s\n  LV Pool data           %s_tdata\n", lv.meta.name, lv.name)
+		case "thin":
+			fmt.Fprintf(&sb, "  LV Pool name           %s\n", lv.pool.name)
+		}
+		sb.WriteString("  LV Status              available\n  # open                 0\n")
+		fmt.Fprintf(&sb, "  LV Size                %s\n", lvmDisplaySize(lv.size(), units))
+		switch lv.kind {
+		case "thin-pool":
+			fmt.Fprintf(&sb, "  Allocated pool data    %s%%\n  Allocated metadata     %s%%\n", lv.dataPercent(), lv.metaPercent())
+		case "thin":
+			fmt.Fprintf(&sb, "  Mapped size            %s%%\n", lv.dataPercent())
+		}
+		fmt.Fprintf(&sb, "  Current LE             %d\n", lv.size()/simExtent)
+		fmt.Fprintf(&sb, "  Segments               %d\n", max(len(lv.segs), 1))
+		sb.WriteString("  Allocation             inherit\n  Read ahead sectors     auto\n  - currently set to     256\n")
+		if len(lv.dm) > 0 {
+			fmt.Fprintf(&sb, "  Block device           %s\n", lv.dev())
+		}
+		sb.WriteString("   \n")
+	}
+	return sb.String(), "", 0
+}
+
+/*  PV and VG changes  */
+
+// newPv initializes disk as PV, out gets pvcreate messages
+func (n *Node) newPv(path string, out *strings.Builder) (*simPv, string, int) {
+	d := n.disk(path)
+	if d == nil || !strings.HasPrefix(path, "/dev/") {
+		_, stderr, code := simFail(5, "No device found for %s.", path)
+		return nil, stderr, code
+	}
+	if d.pv != nil {
+		return d.pv, "", 0
+	}
+	if len(d.Parts) > 0 || d.Mount != "" || d.FsType != "" {
+		_, stderr, code := simFail(5, "Cannot use %s: device is partitioned", path)
+		return nil, stderr, code
+	}
+	d.pv = &simPv{dev: d, uuid: n.uuid("pv"), size: d.Size}
+	fmt.Fprintf(out, "  Physical volume \"%s\" successfully created.\n", path)
+	return d.pv, "", 0
+}
+
+func simPvcreate(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("pvcreate", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if len(a.pos) == 0 {
+		return simFail(3, "Please enter a physical volume path.")
+	}
+	var out strings.Builder
+	for _, p := range a.pos {
+		if d := n.disk(p); d != nil && d.pv != nil && d.pv.vg != nil {
+			return out.String(), fmt.Sprintf("  Can't initialize physical volume \"%s\" of volume group \"%s\" without -ff\n", p, d.pv.vg.name), 5
+		}
+		if _, stderr, code := n.newPv(p, &out); code != 0 {
+			return out.String(), stderr, code
+		}
+	}
+	return out.String(), "", 0
+}
+
+func simPvremove(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("pvremove", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	var out strings.Builder
+	for _, p := range a.pos {
+		d := n.disk(p)
+		if d == nil || d.pv == nil {
+			return out.String(), fmt.Sprintf("  No PV found on device %s.\n", p), 5
+		}
+		if d.pv.vg != nil {
+			return out.String(), fmt.Sprintf("  PV %s is used by VG %s so please use vgreduce first.\n", p, d.pv.vg.name), 5
+		}
+		d.pv = nil
+		fmt.Fprintf(&out, "  Labels on physical volume \"%s\" successfully wiped.\n", p)
+	}
+	return out.String(), "", 0
+}
+
+func simPvresize(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("pvresize", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	var out strings.Builder
+	for _, p := range a.pos {
+		d := n.disk(p)
+		if d == nil || d.pv == nil {
+			return out.String(), fmt.Sprintf("  Failed to find physical volume \"%s\".\n", p), 5
+		}
+		size := d.Size
+		if s, ok := a.opts["setphysicalvolumesize"]; ok {
+			if size, err = parseSimSize(s); err != nil {
+				return simFail(3, "%s", err)
+			}
+		}
+		old := d.pv.size
+		d.pv.size = size
+		if d.pv.vg != nil && d.pv.peCount() < d.pv.allocated() {
+			d.pv.size = old
+			return out.String(), fmt.Sprintf("  %s: cannot resize to %d extents as later ones are allocated.\n", p, d.pv.peCount()), 5
+		}
+		if d.pv.vg != nil {
+			d.pv.vg.seqNo++
+		}
+		fmt.Fprintf(&out, "  Physical volume \"%s\" changed\n", p)
+	}
+	fmt.Fprintf(&out, "  %d physical volume(s) resized or updated / 0 physical volume(s) not resized\n", len(a.pos))
+	return out.String(), "", 0
+}
+
+// addPvs adds devices to VG with implicit pvcreate
+func (n *Node) addPvs(vg *simVg, paths []string, out *strings.Builder) (string, int) {
+	for _, p := range paths {
+		pv, stderr, code := n.newPv(p, out)
+		if code != 0 {
+			return stderr, code
+		}
+		if pv.vg != nil {
+			return fmt.Sprintf("  Physical volume '%s' is already in volume group '%s'\n  Unable to add physical volume '%s' to volume group '%s'\n", p, pv.vg.name, p, vg.name), 5
+		}
+		pv.vg = vg
+		vg.pvs = append(vg.pvs, pv)
+	}
+	vg.seqNo++
+	return "", 0
+}
+
+func simVgcreate(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("vgcreate", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if len(a.pos) < 2 {
+		return simFail(3, "Please enter a volume group name and physical volumes.")
+	}
+	name := a.pos[0]
+	if n.vg(name) != nil {
+		return simFail(5, "A volume group called %s already exists.", name)
+	}
+	vg := &simVg{name: name, uuid: n.uuid("vg")}
+	if t, ok := a.opts["addtag"]; ok {
+		vg.tags = append(vg.tags, t)
+	}
+	var out strings.Builder
+	if stderr, code := n.addPvs(vg, a.pos[1:], &out); code != 0 {
+		for _, pv := range vg.pvs {
+			pv.vg = nil
+		}
+		return out.String(), stderr, code
+	}
+	n.vgs = append(n.vgs, vg)
+	fmt.Fprintf(&out, "  Volume group \"%s\" successfully created\n", name)
+	return out.String(), "", 0
+}
+
+func simVgextend(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("vgextend", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if len(a.pos) < 2 {
+		return simFail(3, "Please enter volume group name and physical volume(s)")
+	}
+	vgs, stderr, code := n.selectVgs(a.pos[:1])
+	if code != 0 {
+		return "", stderr, code
+	}
+	var out strings.Builder
+	if stderr, code := n.addPvs(vgs[0], a.pos[1:], &out); code != 0 {
+		return out.String(), stderr, code
+	}
+	fmt.Fprintf(&out, "  Volume group \"%s\" successfully extended\n", vgs[0].name)
+	return out.String(), "", 0
+}
+
+func simVgreduce(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("vgreduce", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if len(a.pos) < 2 {
+		return simFail(3, "Please give volume group name and physical volume paths")
+	}
+	vgs, stderr, code := n.selectVgs(a.pos[:1])
+	if code != 0 {
+		return "", stderr, code
+	}
+	vg := vgs[0]
+	var out strings.Builder
+	for _, p := range a.pos[1:] {
+		i := slices.IndexFunc(vg.pvs, func(pv *simPv) bool { return pv.path() == p })
+		if i < 0 {
+			return out.String(), fmt.Sprintf("  Physical Volume \"%s\" not found in Volume Group \"%s\".\n", p, vg.name), 5
+		}
+		if vg.pvs[i].allocated() > 0 {
+			return out.String(), fmt.Sprintf("  Physical volume \"%s\" still in use\n", p), 5
+		}
+		vg.pvs[i].vg = nil
+		vg.pvs = slices.Delete(vg.pvs, i, i+1)
+		vg.seqNo++
+		fmt.Fprintf(&out, "  Removed \"%s\" from volume group \"%s\"\n", p, vg.name)
+	}
+	return out.String(), "", 0
+}
+
+func simVgremove(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("vgremove", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	vgs, stderr, code := n.selectVgs(a.pos)
+	if code != 0 || len(a.pos) == 0 {
+		if len(a.pos) == 0 {
+			return simFail(3, "Please enter one or more volume group paths.")
+		}
+		return "", stderr, code
+	}
+	var out strings.Builder
+	for _, vg := range vgs {
+		if lvs := vg.visibleLvs(); len(lvs) > 0 {
+			if !a.has("force") {
+				return out.String(), fmt.Sprintf("  Volume group \"%s\" still contains %d logical volume(s)\n", vg.name, len(lvs)), 5
+			}
+			for _, lv := range lvs {
+				if lv.kind != "thin" {
+					fmt.Fprintf(&out, "  Logical volume \"%s\" successfully removed.\n", lv.name)
+				}
+			}
+		}
+		for _, pv := range vg.pvs {
+			pv.vg = nil
+		}
+		n.vgs = slices.DeleteFunc(n.vgs, func(v *simVg) bool { return v == vg })
+		fmt.Fprintf(&out, "  Volume group \"%s\" successfully removed\n", vg.name)
+	}
+	return out.String(), "", 0
+}
+
+func simVgchange(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("vgchange", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if a.has("all") && len(a.pos) > 0 && (a.pos[0] == "y" || a.pos[0] == "n") {
+		a.pos = a.pos[1:]
+	}
+	vgs, stderr, code := n.selectVgs(a.pos)
+	if code != 0 {
+		return "", stderr, code
+	}
+	var out strings.Builder
+	for _, vg := range vgs {
+		if t, ok := a.opts["addtag"]; ok && !slices.Contains(vg.tags, t) {
+			vg.tags = append(vg.tags, t)
+		}
+		if t, ok := a.opts["deltag"]; ok {
+			vg.tags = slices.DeleteFunc(vg.tags, func(v string) bool { return v == t })
+		}
+		vg.seqNo++
+		if a.has("addtag") || a.has("deltag") {
+			fmt.Fprintf(&out, "  Volume group \"%s\" successfully changed\n", vg.name)
+		} else {
+			fmt.Fprintf(&out, "  %d logical volume(s) in volume group \"%s\" now active\n", len(vg.visibleLvs()), vg.name)
+		}
+	}
+	return out.String(), "", 0
+}
+
+/*  LV changes  */
+
+// lvExtents converts -L/-l argument to extents, cur is current LV size in extents
+func lvExtents(a simArgs, vg *simVg, cur int) (int, error) {
+	if s, ok := a.opts["size"]; ok {
+		b, err := parseSimSize(s)
+		if err != nil {
+			return 0, err
+		}
+		ext := int((b + simExtent - 1) / simExtent)
+		switch {
+		case strings.HasPrefix(s, "+"):
+			ext += cur
+		case strings.HasPrefix(s, "-"):
+			ext = cur - ext
+		}
+		return ext, nil
+	}
+	s, ok := a.opts["extents"]
+	if !ok {
+		return 0, fmt.Errorf("Please specify either size or extents")
+	}
+	v, pct, isPct := strings.Cut(strings.TrimLeft(s, "+"), "%")
+	ext, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid argument for --extents: %s", s)
+	}
+	if isPct {
+		switch strings.ToUpper(pct) {
+		case "FREE":
+			ext = (vg.extents() - vg.allocated()) * ext / 100
+		case "VG":
+			ext = vg.extents() * ext / 100
+		default:
+			return 0, fmt.Errorf("Invalid argument for --extents: %s", s)
+		}
+	}
+	if strings.HasPrefix(s, "+") || (isPct && strings.ToUpper(pct) == "FREE") {
+		ext += cur
+	}
+	return ext, nil
+}
+
+func simLvcreate(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("lvcreate", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if len(a.pos) == 0 {
+		return simFail(3, "Please provide a volume group name")
+	}
+	vgName, poolName, _ := strings.Cut(strings.TrimPrefix(a.pos[0], "/dev/"), "/")
+	if p, ok := a.opts["thinpool"]; ok {
+		poolName = p
+	}
+	vg := n.vg(vgName)
+	if vg == nil {
+		return simFail(5, "Volume group \"%s\" not found\n  Cannot process volume group %s", vgName, vgName)
+	}
+	kind := "linear"
+	switch {
+	case a.has("virtualsize") || a.opts["type"] == "thin":
+		kind = "thin"
+	case a.has("thin") || a.has("thinpool") || a.opts["type"] == "thin-pool" || poolName != "":
+		kind = "thin-pool"
+	}
+	name := a.opts["name"]
+	if kind == "thin-pool" && poolName != "" {
+		name = poolName
+	}
+	if name == "" {
+		for i := 0; ; i++ {
+			if name = fmt.Sprintf("lvol%d", i); vg.lv(name) == nil {
+				break
+			}
+		}
+	}
+	if vg.lv(name) != nil {
+		return simFail(5, "Logical Volume \"%s\" already exists in volume group \"%s\"", name, vg.name)
+	}
+	lv := &simLv{name: name, uuid: n.uuid("lv"), vg: vg, kind: kind, created: time.Now()}
+
+	if kind == "thin" {
+		pool := vg.lv(poolName)
+		if pool == nil || pool.kind != "thin-pool" {
+			return simFail(5, "Thin pool %s/%s not found.", vg.name, poolName)
+		}
+		virt, err := parseSimSize(a.opts["virtualsize"])
+		if err != nil {
+			return simFail(3, "%s", err)
+		}
+		lv.virt, lv.pool, lv.dm = (virt+simExtent-1)/simExtent*simExtent, pool, n.dmMinors(1)
+		vg.lvs = append(vg.lvs, lv)
+		vg.seqNo++
+		return fmt.Sprintf("  Logical volume \"%s\" created.\n", name), "", 0
+	}
+
+	ext, err := lvExtents(a, vg, 0)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if ext <= 0 {
+		return simFail(3, "Unable to create new logical volume with no extents.")
+	}
+	var extra []*simLv
+	if kind == "thin-pool" {
+		// metadata: 64 bytes per 64KiB chunk doubled, at least 2MiB, rounded to extents
+		metaExt := int((max(int64(ext)*simExtent/(64<<10)*64*2, 2<<20) + simExtent - 1) / simExtent)
+		lv.meta = &simLv{name: name + "_tmeta", uuid: n.uuid("lv"), vg: vg, kind: "tmeta", created: lv.created}
+		extra = append(extra, lv.meta)
+		spare := vg.lv("lvol0_pmspare")
+		if spare == nil {
+			spare = &simLv{name: "lvol0_pmspare", uuid: n.uuid("lv"), vg: vg, kind: "pmspare", created: lv.created}
+			extra = append(extra, spare)
+		}
+		need := ext + metaExt + max(metaExt-spare.extents(), 0)
+		if free := vg.extents() - vg.allocated(); need > free {
+			return simFail(5, "Volume group \"%s\" has insufficient free space (%d extents): %d required.", vg.name, free, need)
+		}
+		lv.meta.segs, _ = vg.allocate(metaExt)
+		if grow := metaExt - spare.extents(); grow > 0 {
+			segs, _ := vg.allocate(grow)
+			spare.segs = append(spare.segs, segs...)
+		}
+		lv.meta.dm = n.dmMinors(1)
+		lv.dm = n.dmMinors(3) // tdata, tpool, pool
+	} else {
+		lv.dm = n.dmMinors(1)
+	}
+	if lv.segs, err = vg.allocate(ext); err != nil {
+		return simFail(5, "%s", err)
+	}
+	vg.lvs = append(vg.lvs, lv)
+	for _, e := range extra {
+		if vg.lv(e.name) == nil {
+			vg.lvs = append(vg.lvs, e)
+		}
+	}
+	vg.seqNo++
+	var out strings.Builder
+	if kind == "thin-pool" {
+		out.WriteString("  Thin pool volume with chunk size 64.00 KiB can address at most 15.81 TiB of data.\n")
+	}
+	fmt.Fprintf(&out, "  Logical volume \"%s\" created.\n", name)
+	return out.String(), "", 0
+}
+
+func simLvremove(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("lvremove", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if len(a.pos) == 0 {
+		return simFail(3, "Please enter one or more logical volume paths.")
+	}
+	lvs, stderr, code := n.selectLvs(a.pos, false)
+	if code != 0 {
+		return "", stderr, code
+	}
+	// thin volumes go before their pools
+	slices.SortStableFunc(lvs, func(x, y *simLv) int {
+		isPool := func(lv *simLv) int {
+			if lv.kind == "thin-pool" {
+				return 1
+			}
+			return 0
+		}
+		return isPool(x) - isPool(y)
+	})
+	var out strings.Builder
+	for _, lv := range lvs {
+		vg := lv.vg
+		if vg.lv(lv.name) == nil {
+			continue
+		}
+		if lv.hidden() {
+			return out.String(), fmt.Sprintf("  Can't remove logical volume %s used by a pool.\n", lv.name), 5
+		}
+		if lv.kind == "thin-pool" {
+			for _, t := range vg.lvs {
+				if t.pool == lv && !a.has("yes") && !a.has("force") {
+					return out.String(), fmt.Sprintf("  Removing pool \"%s\" will remove 1 dependent volume(s). Proceed? [y/n]: [n]\n  Logical volume \"%s\" not removed.\n", lv.name, lv.name), 5
+				}
+			}
+			for _, t := range slices.Clone(vg.lvs) {
+				if t.pool == lv {
+					vg.lvs = slices.DeleteFunc(vg.lvs, func(x *simLv) bool { return x == t })
+					fmt.Fprintf(&out, "  Logical volume \"%s\" successfully removed.\n", t.name)
+				}
+			}
+			vg.lvs = slices.DeleteFunc(vg.lvs, func(x *simLv) bool { return x == lv.meta })
+		}
+		vg.lvs = slices.DeleteFunc(vg.lvs, func(x *simLv) bool { return x == lv })
+		if !slices.ContainsFunc(vg.lvs, func(x *simLv) bool { return x.kind == "thin-pool" }) {
+			vg.lvs = slices.DeleteFunc(vg.lvs, func(x *simLv) bool { return x.kind == "pmspare" })
+		}
+		vg.seqNo++
+		fmt.Fprintf(&out, "  Logical volume \"%s\" successfully removed.\n", lv.name)
+	}
+	return out.String(), "", 0
+}
+
+func simLvresize(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("lvresize", args)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if len(a.pos) != 1 || !strings.Contains(a.pos[0], "/") {
+		return simFail(3, "Please provide the logical volume name")
+	}
+	lvs, stderr, code := n.selectLvs(a.pos, true)
+	if code != 0 {
+		return "", stderr, code
+	}
+	lv := lvs[0]
+	full := lv.vg.name + "/" + lv.name
+	cur := int(lv.size() / simExtent)
+	ext, err := lvExtents(a, lv.vg, cur)
+	if err != nil {
+		return simFail(3, "%s", err)
+	}
+	if ext == cur {
+		return simFail(5, "New size (%d extents) matches existing size (%d extents).", ext, cur)
+	}
+	old := lv.size()
+	switch {
+	case lv.kind == "thin":
+		lv.virt = int64(ext) * simExtent
+	case ext > cur:
+		segs, err := lv.vg.allocate(ext - cur)
+		if err != nil {
+			return simFail(5, "%s", err)
+		}
+		lv.segs = append(lv.segs, segs...)
+	case lv.kind == "thin-pool":
+		return simFail(5, "Thin pool volumes %s cannot be reduced in size yet.", full)
+	default:
+		drop := cur - ext
+		for drop > 0 {
+			last := &lv.segs[len(lv.segs)-1]
+			take := min(last.extents, drop)
+			last.extents -= take
+			drop -= take
+			if last.extents == 0 {
+				lv.segs = lv.segs[:len(lv.segs)-1]
+			}
+		}
+	}
+	lv.vg.seqNo++
+	var out strings.Builder
+	fmt.Fprintf(&out, "  Size of logical volume %s changed from %s (%d extents) to %s (%d extents).\n",
+		full, lvmDisplaySize(old, ""), cur, lvmDisplaySize(lv.size(), ""), ext)
+	fmt.Fprintf(&out, "  Logical volume %s successfully resized.\n", full)
+	return out.String(), "", 0
+}
+
+/*  Block devices  */
+
+type simBlk struct {
+	name, kname, path, typ, fstype, uuid, mount, model, serial, wwn string
+	major, minor                                                    int
+	size                                                            int64
+	rota, hotplug                                                   bool
+	children                                                        []*simBlk
+}
+
+// simLsblkColumns are lsblk columns in `-O` order
+var simLsblkColumns = []string{"NAME", "KNAME", "PATH", "MAJ:MIN", "FSTYPE", "UUID", "MOUNTPOINT", "MOUNTPOINTS",
+	"RO", "RM", "HOTPLUG", "ROTA", "SIZE", "TYPE", "MODEL", "SERIAL", "WWN", "PKNAME"}
+
+func (n *Node) blockDevices() []*simBlk {
+	lvBlk := func(lv *simLv, name string, minor int, size int64) *simBlk {
+		dm := dmName(lv.vg.name, name)
+		return &simBlk{name: dm, kname: fmt.Sprintf("dm-%d", minor), path: "/dev/mapper/" + dm, typ: "lvm", major: 253, minor: minor, size: size}
+	}
+	var devs []*simBlk
+	for _, d := range n.disks {
+		b := &simBlk{name: d.Name, kname: d.Name, path: "/dev/" + d.Name, typ: "disk", fstype: d.FsType, mount: d.Mount,
+			model: d.Model, serial: d.Serial, wwn: d.Wwn, major: d.major, minor: d.minor, size: d.Size, rota: d.Rota, hotplug: d.HotPlug}
+		for i, p := range d.Parts {
+			b.children = append(b.children, &simBlk{name: p.Name, kname: p.Name, path: "/dev/" + p.Name, typ: "part",
+				fstype: p.FsType, mount: p.Mount, major: d.major, minor: d.minor + i + 1, size: p.Size, rota: d.Rota})
+		}
+		if d.pv != nil {
+			b.fstype, b.uuid = "LVM2_member", d.pv.uuid
+		}
+		if d.pv != nil && d.pv.vg != nil {
+			for _, lv := range d.pv.vg.lvs {
+				if !slices.ContainsFunc(lv.segs, func(s simSeg) bool { return s.pv == d.pv }) {
+					continue
+				}
+				switch lv.kind {
+				case "linear":
+					b.children = append(b.children, lvBlk(lv, lv.name, lv.dm[0], lv.size()))
+				case "tmeta", "thin-pool":
+					pool := lv
+					if lv.kind == "tmeta" {
+						pool = lv.vg.lvs[slices.IndexFunc(lv.vg.lvs, func(p *simLv) bool { return p.meta == lv })]
+					}
+					tpool := lvBlk(pool, pool.name+"-tpool", pool.dm[1], pool.size())
+					tpool.children = append(tpool.children, lvBlk(pool, pool.name, pool.dm[2], pool.size()))
+					for _, t := range lv.vg.lvs {
+						if t.pool == pool {
+							tpool.children = append(tpool.children, lvBlk(t, t.name, t.dm[0], t.size()))
+						}
+					}
+					var part *simBlk
+					if lv.kind == "tmeta" {
+						part = lvBlk(lv, lv.name, lv.dm[0], lv.size())
+					} else {
+						part = lvBlk(lv, lv.name+"_tdata", lv.dm[0], lv.size())
+					}
+					part.children = []*simBlk{tpool}
+					b.children = append(b.children, part)
+				}
+			}
+		}
+		devs = append(devs, b)
+	}
+	return devs
+}
+
+func simLsblk(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("lsblk", args)
+	if err != nil {
+		return simFail(32, "%s", err)
+	}
+	cols := []string{"NAME", "MAJ:MIN", "RM", "SIZE", "RO", "TYPE", "MOUNTPOINTS"}
+	if a.has("output-all") {
+		cols = simLsblkColumns
+	}
+	if o, ok := a.opts["output"]; ok {
+		if !strings.HasPrefix(o, "+") {
+			cols = nil
+		}
+		for _, c := range strings.Split(strings.TrimPrefix(o, "+"), ",") {
+			c = strings.ToUpper(strings.TrimSpace(c))
+			if !slices.Contains(simLsblkColumns, c) {
+				return simFail(1, "lsblk: unknown column: %s", c)
+			}
+			cols = append(cols, c)
+		}
+	}
+	devs := n.blockDevices()
+	if len(a.pos) > 0 {
+		var sel []*simBlk
+		for _, p := range a.pos {
+			i := slices.IndexFunc(devs, func(b *simBlk) bool { return b.path == p || b.name == p })
+			if i < 0 {
+				return "", fmt.Sprintf("lsblk: %s: not a block device\n", p), 32
+			}
+			sel = append(sel, devs[i])
+		}
+		devs = sel
+	}
+	if a.has("nodeps") {
+		for _, d := range devs {
+			d.children = nil
+		}
+	}
+
+	value := func(b *simBlk, parent *simBlk, col string) any {
+		name := b.name
+		if a.has("paths") {
+			name = b.path
+		}
+		var v any
+		switch col {
+		case "NAME":
+			v = name
+		case "KNAME":
+			v = b.kname
+		case "PATH":
+			v = b.path
+		case "MAJ:MIN":
+			v = fmt.Sprintf("%d:%d", b.major, b.minor)
+		case "FSTYPE":
+			v = b.fstype
+		case "UUID":
+			v = b.uuid
+		case "MOUNTPOINT":
+			v = b.mount
+		case "MOUNTPOINTS":
+			v = []any{nil}
+			if b.mount != "" {
+				v = []any{b.mount}
+			}
+		case "RO":
+			v = false
+		case "RM":
+			v = false
+		case "HOTPLUG":
+			v = b.hotplug
+		case "ROTA":
+			v = b.rota
+		case "SIZE":
+			if a.has("bytes") {
+				v = b.size
+			} else {
+				v = lsblkSize(b.size)
+			}
+		case "TYPE":
+			v = b.typ
+		case "MODEL":
+			v = b.model
+		case "SERIAL":
+			v = b.serial
+		case "WWN":
+			v = b.wwn
+		case "PKNAME":
+			if parent != nil {
+				v = parent.kname
+			} else {
+				v = ""
+			}
+		}
+		if s, ok := v.(string); ok && s == "" {
+			return nil
+		}
+		return v
+	}
+
+	var sb strings.Builder
+	if a.has("json") {
+		var obj func(b, parent *simBlk, indent string) string
+		obj = func(b, parent *simBlk, indent string) string {
+			var kv []string
+			for _, c := range cols {
+				v, _ := json.Marshal(value(b, parent, c))
+				kv = append(kv, fmt.Sprintf("%s   \"%s\": %s", indent, strings.ToLower(c), v))
+			}
+			if len(b.children) > 0 {
+				var children []string
+				for _, ch := range b.children {
+					children = append(children, obj(ch, b, indent+"      "))
+				}
+				kv = append(kv, indent+"   \"children\": [\n"+strings.Join(children, ",\n")+"\n"+indent+"   ]")
+			}
+			return indent + "{\n" + strings.Join(kv, ",\n") + "\n" + indent + "}"
+		}
+		var list []string
+		for _, d := range devs {
+			list = append(list, obj(d, nil, "      "))
+		}
+		return "{\n   \"blockdevices\": [\n" + strings.Join(list, ",\n") + "\n   ]\n}\n", "", 0
+	}
+
+	var rows [][]string
+	var walk func(b, parent *simBlk, prefix string, last bool)
+	walk = func(b, parent *simBlk, prefix string, last bool) {
+		var row []string
+		for _, c := range cols {
+			v := value(b, parent, c)
+			s := ""
+			switch v := v.(type) {
+			case string:
+				s = v
+			case int64:
+				s = strconv.FormatInt(v, 10)
+			case bool:
+				s = "0"
+				if v {
+					s = "1"
+				}
+			case []any:
+				if v[0] != nil {
+					s = v[0].(string)
+				}
+			}
+			if c == "NAME" && parent != nil && !a.has("list") {
+				branch := "├─"
+				if last {
+					branch = "└─"
+				}
+				s = prefix + branch + s
+			}
+			row = append(row, s)
+		}
+		rows = append(rows, row)
+		for i, ch := range b.children {
+			next := prefix
+			if parent != nil {
+				if last {
+					next += "  "
+				} else {
+					next += "│ "
+				}
+			}
+			walk(ch, b, next, i == len(b.children)-1)
+		}
+	}
+	for _, d := range devs {
+		walk(d, nil, "", false)
+	}
+	if !a.has("noheadings") {
+		rows = append([][]string{cols}, rows...)
+	}
+	widths := make([]int, len(cols))
+	for _, row := range rows {
+		for i, s := range row {
+			widths[i] = max(widths[i], len([]rune(s)))
+		}
+	}
+	for _, row := range rows {
+		var line strings.Builder
+		for i, s := range row {
+			pad := strings.Repeat(" ", widths[i]-len([]rune(s)))
+			if slices.Contains([]string{"MAJ:MIN", "RM", "RO", "SIZE", "HOTPLUG", "ROTA"}, cols[i]) {
+				line.WriteString(pad + s + " ")
+			} else {
+				line.WriteString(s + pad + " ")
+			}
+		}
+		sb.WriteString(strings.TrimRight(line.String(), " ") + "\n")
+	}
+	return sb.String(), "", 0
+}
+
+func simBlockdev(n *Node, args []string, _ string) (string, string, int) {
+	a, err := parseSimArgs("blockdev", args)
+	if err != nil || len(a.pos) != 1 {
+		return simFail(1, "Usage: blockdev --getsize64 <device>")
+	}
+	i := slices.IndexFunc(n.blockDevices(), func(b *simBlk) bool { return b.path == a.pos[0] })
+	if i < 0 {
+		return "", fmt.Sprintf("blockdev: cannot open %s: No such file or directory\n", a.pos[0]), 1
+	}
+	return fmt.Sprintf("%d\n", n.blockDevices()[i].size), "", 0
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const soakFinalTests = "^TestFinalizer$"
+
+type SoakIteration struct {
+	N           int       `json:"n"`
+	Start       time.Time `json:"start"`
+	Duration    float64   `json:"duration"`
+	Status      string    `json:"status"`
+	FailedTests []string  `json:"failedTests,omitempty"`
+	Diagnostics string    `json:"diagnostics,omitempty"`
+}
+
+type SoakTestStats struct {
+	Name        string  `json:"name"`
+	Runs        int     `json:"runs"`
+	Failures    int     `json:"failures"`
+	MinDuration float64 `json:"minDuration"`
+	AvgDuration float64 `json:"avgDuration"`
+	MaxDuration float64 `json:"maxDuration"`
+}
+
+type SoakReport struct {
+	Start      time.Time        `json:"start"`
+	Duration   float64          `json:"duration"`
+	StopReason string           `json:"stopReason"`
+	Iterations []*SoakIteration `json:"iterations"`
+	Tests      []*SoakTestStats `json:"tests"`
+}
+
+var SoakGroups []string
+
+// soakGroupSelected reports whether node group runs in soak mode (-soakgroups)
+func soakGroupSelected(label string) bool {
+	return len(SoakGroups) == 0 || slices.Contains(SoakGroups, label)
+}
+
+func (s *SoakReport) addTest(c *ReportCase) {
+	var st *SoakTestStats
+	for _, item := range s.Tests {
+		if item.Name == c.Name {
+			st = item
+		}
+	}
+	if st == nil {
+		st = &SoakTestStats{Name: c.Name, MinDuration: math.MaxFloat64}
+		s.Tests = append(s.Tests, st)
+	}
+
+	st.AvgDuration = (st.AvgDuration*float64(st.Runs) + c.Duration) / float64(st.Runs+1)
+	st.Runs++
+	st.MinDuration = min(st.MinDuration, c.Duration)
+	st.MaxDuration = max(st.MaxDuration, c.Duration)
+	if c.Status == StatusFailed {
+		st.Failures++
+	}
+}
+
+// saveSoakDiagnostics keeps report and cluster state of failed iteration
+func saveSoakDiagnostics(dir string, r *Report) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := r.WriteJSON(filepath.Join(dir, "report.json")); err != nil {
+		return err
+	}
+
+	mx.RLock()
+	cluster := clrCache[":"]
+	mx.RUnlock()
+	if cluster == nil {
+		return nil
+	}
+	return cluster.SaveDiagnostics(dir)
+}
+
+// RunSuite runs tests once or repeats them in soak mode (-soak, -soakiterations).
+// Soak iterations skip TestFinalizer, cleanup is called between iterations
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(util.RunSuite(m, prepareClr))
+//	}
+func RunSuite(m *testing.M, cleanup func()) int {
+	flag.Parse()
+	defer CloseTunnels()
+	defer DeleteDebugPods()
+	if *soakFlag == 0 && *soakIterationsFlag == 0 {
+		return m.Run()
+	}
+
+	for _, item := range strings.Split(*soakGroupsFlag, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			SoakGroups = append(SoakGroups, item)
+		}
+	}
+	run, skip := flag.Lookup("test.run").Value.String(), flag.Lookup("test.skip").Value.String()
+	if skip == "" {
+		_ = flag.Set("test.skip", soakFinalTests)
+	} else {
+		_ = flag.Set("test.skip", skip+"|"+soakFinalTests)
+	}
+
+	soak := &SoakReport{Start: time.Now()}
+	code, failures := 0, 0
+	for n := 1; ; n++ {
+		if *soakIterationsFlag > 0 && n > *soakIterationsFlag {
+			soak.StopReason = fmt.Sprintf("%d iterations done", *soakIterationsFlag)
+			break
+		}
+		if *soakFlag > 0 && time.Since(soak.Start) >= *soakFlag {
+			soak.StopReason = fmt.Sprintf("duration %s reached", *soakFlag)
+			break
+		}
+		if n > 1 && cleanup != nil {
+			cleanup()
+		}
+
+		it := &SoakIteration{N: n, Start: time.Now(), Status: StatusPassed}
+		soak.Iterations = append(soak.Iterations, it)
+		if m.Run() != 0 {
+			it.Status = StatusFailed
+		}
+		TeardownFixtures()
+		r := resetReport()
+		reqMx.Lock()
+		r.Unmet, unmetRequirements = unmetRequirements, []UnmetRequirement{}
+		reqMx.Unlock()
+
+		it.Duration = time.Since(it.Start).Seconds()
+		for _, c := range r.Tests {
+			soak.addTest(c)
+			if c.Status == StatusFailed {
+				it.Status = StatusFailed
+				it.FailedTests = append(it.FailedTests, c.Name)
+			}
+		}
+		if it.Status == StatusPassed {
+			Warnf("Soak iteration %d passed (%.0fs)", n, it.Duration)
+			continue
+		}
+
+		failures++
+		code = 1
+		Errorf("Soak iteration %d failed (%.0fs): %s", n, it.Duration, strings.Join(it.FailedTests, ", "))
+		it.Diagnostics = filepath.Join(*soakDirFlag, fmt.Sprintf("iteration-%03d", n))
+		if err := saveSoakDiagnostics(it.Diagnostics, r); err != nil {
+			Errorf("Can't save soak diagnostics: %s", err.Error())
+		}
+		if failures >= *soakMaxFailuresFlag {
+			soak.StopReason = fmt.Sprintf("%d failed iterations", failures)
+			break
+		}
+	}
+	soak.Duration = time.Since(soak.Start).Seconds()
+
+	passed := len(soak.Iterations) - failures
+	Warnf("Soak stopped (%s): %d iterations, %d passed, %d failed", soak.StopReason, len(soak.Iterations), passed, failures)
+	for _, st := range soak.Tests {
+		Warnf("  %s: %d/%d failed, %.0fs/%.0fs/%.0fs min/avg/max",
+			st.Name, st.Failures, st.Runs, st.MinDuration, st.AvgDuration, st.MaxDuration)
+	}
+	if err := os.MkdirAll(*soakDirFlag, 0755); err == nil {
+		err = writeJSONFile(filepath.Join(*soakDirFlag, "soak.json"), soak)
+		if err != nil {
+			Errorf("Can't write soak statistics: %s", err.Error())
+		}
+	}
+
+	_ = flag.Set("test.run", soakFinalTests)
+	_ = flag.Set("test.skip", skip)
+	if m.Run() != 0 {
+		code = 1
+	}
+	_ = flag.Set("test.run", run)
+	return code
+}
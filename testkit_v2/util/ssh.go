@@ -17,73 +17,85 @@ limitations under the License.
 package integration
 
 import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
 	"fmt"
-	"io"
-	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
 
-	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	terminal "golang.org/x/term"
 )
 
-// generatePrivateKey creates an RSA Private Key of specified byte size
-func generatePrivateKey(bitSize int) (*rsa.PrivateKey, error) {
-	// Private Key generation
-	privateKey, err := rsa.GenerateKey(rand.Reader, bitSize)
-	if err != nil {
-		return nil, err
-	}
-
-	// Validate Private Key
-	err = privateKey.Validate()
-	if err != nil {
-		return nil, err
-	}
+// SSH key types
+const (
+	KeyTypeRSA     = "rsa"
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeECDSA   = "ecdsa"
+)
 
-	Infof("Private Key generated")
-	return privateKey, nil
+// generatePrivateKey creates private key of the type (RSA 4096, Ed25519 or ECDSA P-256)
+func generatePrivateKey(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case KeyTypeRSA, "":
+		privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
+		if err != nil {
+			return nil, err
+		}
+		// Validate Private Key
+		if err = privateKey.Validate(); err != nil {
+			return nil, err
+		}
+		return privateKey, nil
+	case KeyTypeEd25519:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		return privateKey, err
+	case KeyTypeECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	return nil, fmt.Errorf("unknown ssh key type: %s", keyType)
 }
 
-// encodePrivateKeyToPEM encodes Private Key from RSA to PEM format
-func encodePrivateKeyToPEM(privateKey *rsa.PrivateKey) []byte {
-	// Get ASN.1 DER format
-	privateDER := x509.MarshalPKCS1PrivateKey(privateKey)
-
-	// pem.Block
-	privateBlock := pem.Block{
-		Type:    "RSA PRIVATE KEY",
-		Headers: nil,
-		Bytes:   privateDER,
+// encodePrivateKeyToPEM encodes RSA key to PKCS#1 PEM, other keys to OpenSSH PEM
+func encodePrivateKeyToPEM(privateKey crypto.Signer) ([]byte, error) {
+	if rsaKey, ok := privateKey.(*rsa.PrivateKey); ok {
+		privateBlock := pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(rsaKey),
+		}
+		return pem.EncodeToMemory(&privateBlock), nil
 	}
 
-	// Private key in PEM format
-	privatePEM := pem.EncodeToMemory(&privateBlock)
-
-	return privatePEM
+	privateBlock, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(privateBlock), nil
 }
 
-// generatePublicKey take a rsa.PublicKey and return bytes suitable for writing to .pub file
-// returns in the format "ssh-rsa ..."
-func generatePublicKey(privateKey *rsa.PublicKey) ([]byte, error) {
-	publicRsaKey, err := ssh.NewPublicKey(privateKey)
+// generatePublicKey returns bytes suitable for writing to .pub file ("ssh-ed25519 ...")
+func generatePublicKey(publicKey crypto.PublicKey) ([]byte, error) {
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
 	if err != nil {
 		return nil, err
 	}
 
-	pubKeyBytes := ssh.MarshalAuthorizedKey(publicRsaKey)
-
-	Infof("Public key generated")
-	return pubKeyBytes, nil
+	return ssh.MarshalAuthorizedKey(sshPublicKey), nil
 }
 
 // writePemToFile writes keys to a file
@@ -93,54 +105,102 @@ func writeKeyToFile(keyBytes []byte, saveFileTo string) error {
 		return err
 	}
 
-	log.Printf("Key saved to: %s", saveFileTo)
+	Debugf("Key saved to: %s", saveFileTo)
 	return nil
 }
 
-func GenerateRSAKeys(privateFilename string, publicFilename string) {
+// SshKeyNames returns private and public key file names of nested VMs key
+func SshKeyNames(keyType string) (string, string) {
+	if keyType == "" || keyType == KeyTypeRSA {
+		return "id_rsa_test", "id_rsa_test.pub"
+	}
+	return "id_" + keyType + "_test", "id_" + keyType + "_test.pub"
+}
+
+// GenerateSSHKeys creates key pair of the type if private key file does not exist
+func GenerateSSHKeys(keyType, privateFilename, publicFilename string) error {
 	if _, err := os.Stat(privateFilename); err == nil {
-		return
+		return nil
 	}
 
-	Infof("Generate RSA key")
-	bitSize := 4096
-	privateKey, err := generatePrivateKey(bitSize)
+	Infof("Generate %s key", keyType)
+	privateKey, err := generatePrivateKey(keyType)
 	if err != nil {
-		Fatalf(err.Error())
+		return err
 	}
 
-	publicKeyBytes, err := generatePublicKey(&privateKey.PublicKey)
+	publicKeyBytes, err := generatePublicKey(privateKey.Public())
 	if err != nil {
-		Fatalf(err.Error())
+		return err
 	}
 
-	privateKeyBytes := encodePrivateKeyToPEM(privateKey)
-
-	err = writeKeyToFile(privateKeyBytes, privateFilename)
+	privateKeyBytes, err := encodePrivateKeyToPEM(privateKey)
 	if err != nil {
-		Fatalf(err.Error())
+		return err
 	}
 
-	err = writeKeyToFile(publicKeyBytes, publicFilename)
-	if err != nil {
-		Fatalf(err.Error())
+	if err = writeKeyToFile(privateKeyBytes, privateFilename); err != nil {
+		return err
+	}
+	return writeKeyToFile(publicKeyBytes, publicFilename)
+}
+
+func GenerateRSAKeys(privateFilename string, publicFilename string) {
+	if err := GenerateSSHKeys(KeyTypeRSA, privateFilename, publicFilename); err != nil {
+		Fatalf("%s", err.Error())
 	}
 }
 
-func CheckAndGetSSHKeys(dir string, privateKeyName string, pubKeyName string) (sshPubKeyString string) {
-	GenerateRSAKeys(filepath.Join(dir, privateKeyName), filepath.Join(dir, pubKeyName))
+func CheckAndGetSSHKeys(dir, keyType, privateKeyName, pubKeyName string) (sshPubKeyString string) {
+	err := GenerateSSHKeys(keyType, filepath.Join(dir, privateKeyName), filepath.Join(dir, pubKeyName))
+	if err != nil {
+		Fatalf("%s", err.Error())
+	}
 
 	sshPubKey, err := os.ReadFile(filepath.Join(dir, pubKeyName))
 	if err != nil {
-		Fatalf(err.Error())
+		Fatalf("%s", err.Error())
 	}
 
 	return string(sshPubKey)
 }
 
+/*  Authentication  */
+
+var (
+	sshAgentMx     sync.Mutex
+	sshAgentClient agent.ExtendedAgent
+)
+
+// sshAgent returns client of running ssh-agent (SSH_AUTH_SOCK), nil if there is no agent
+func sshAgent() agent.ExtendedAgent {
+	sshAgentMx.Lock()
+	defer sshAgentMx.Unlock()
+	if sshAgentClient != nil || !SshAgent {
+		return sshAgentClient
+	}
+
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil
+	}
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		Debugf("ssh-agent %s: %s", sock, err.Error())
+		return nil
+	}
+	sshAgentClient = agent.NewClient(conn)
+	return sshAgentClient
+}
+
+// interactive reports whether passwords and passphrases can be asked
+func interactive() bool {
+	return !NonInteractive && testing.Verbose()
+}
+
 func readPassword(prompt string) ([]byte, error) {
-	if !testing.Verbose() {
-		panic("Can't read password in not verbose mode")
+	if !interactive() {
+		return nil, errors.New("can't read password in non-interactive mode")
 	}
 
 	fmt.Fprint(os.Stderr, prompt)
@@ -161,209 +221,148 @@ func readPassword(prompt string) ([]byte, error) {
 	return pass, err
 }
 
-func newSshPassConfig(user string) *ssh.ClientConfig {
-	pass, err := readPassword("    Enter ssh password: ")
-	if err != nil {
-		Fatalf("unable to get ssh password: %s", err.Error())
-	}
-
-	return &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(string(pass)),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         20 * time.Second,
-	}
-}
-
-func newSshConfig(user, keyPath string) *ssh.ClientConfig {
-	if keyPath == "" {
-		return newSshPassConfig(user)
-	}
-
+// keySigner reads private key, passphrase is taken from SSH_PASSPHRASE or asked in interactive mode
+func keySigner(keyPath string, agentAuth bool) (ssh.Signer, error) {
 	key, err := os.ReadFile(keyPath)
 	if err != nil {
-		Fatalf("unable to read private key: %s", err.Error())
+		return nil, fmt.Errorf("unable to read private key: %w", err)
 	}
 
 	signer, err := ssh.ParsePrivateKey(key)
-	if err != nil {
-		if err.Error() != "ssh: this private key is passphrase protected" {
-			Fatalf("unable to parse private key: %s", err.Error())
-		}
-
-		// Try to get passphrase from environment variable first
-		var pass []byte
-		if envPass := os.Getenv("SSH_PASSPHRASE"); envPass != "" {
-			pass = []byte(envPass)
-			Debugf("Using SSH passphrase from SSH_PASSPHRASE environment variable")
-		} else {
-			// Try to read from terminal (readPassword handles both stdin and /dev/tty)
-			var readErr error
-			pass, readErr = readPassword("    Enter passphrase for '" + keyPath + "': ")
-			if readErr != nil {
-				Fatalf("SSH key '%s' is passphrase protected. Set SSH_PASSPHRASE environment variable: export SSH_PASSPHRASE='your-passphrase'\nOriginal error: %s", keyPath, readErr.Error())
-			}
-		}
-
-		signer, err = ssh.ParsePrivateKeyWithPassphrase(key, pass)
+	if err == nil {
+		return signer, nil
+	}
+	if !errors.As(err, new(*ssh.PassphraseMissingError)) {
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
+	}
+
+	var pass []byte
+	switch {
+	case os.Getenv("SSH_PASSPHRASE") != "":
+		pass = []byte(os.Getenv("SSH_PASSPHRASE"))
+		Debugf("Using SSH passphrase from SSH_PASSPHRASE environment variable")
+	case agentAuth:
+		Debugf("SSH key '%s' is passphrase protected, use ssh-agent", keyPath)
+		return nil, nil
+	default:
+		// Try to read from terminal (readPassword handles both stdin and /dev/tty)
+		pass, err = readPassword("    Enter passphrase for '" + keyPath + "': ")
 		if err != nil {
-			Fatalf("unable to parse private key: %s", err.Error())
+			return nil, fmt.Errorf("SSH key '%s' is passphrase protected. Set SSH_PASSPHRASE environment variable or add the key to ssh-agent: %w", keyPath, err)
 		}
 	}
 
-	return &ssh.ClientConfig{
-		User: user,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(signer),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         20 * time.Second,
-	}
-}
-
-type sshClient struct {
-	client *ssh.Client
-}
-
-func GetSshClient(user, addr, keyPath string) sshClient {
-	config := newSshConfig(user, keyPath)
-	client, err := ssh.Dial("tcp", addr, config)
+	signer, err = ssh.ParsePrivateKeyWithPassphrase(key, pass)
 	if err != nil {
-		Fatalf("Ssh Dial %s@%s error: %s", user, addr, err.Error())
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
 	}
-
-	return sshClient{client: client}
-}
-
-func (c sshClient) Close() error {
-	return c.client.Close()
+	return signer, nil
 }
 
-func (c sshClient) Upload(localPath string, remotePath string) error {
-	local, err := os.Open(localPath)
-	if err != nil {
-		return err
+// newSshConfig authenticates with key file (if exists), ssh-agent keys and password (interactive mode only)
+func newSshConfig(user, keyPath string) (*ssh.ClientConfig, error) {
+	config := &ssh.ClientConfig{
+		User:            user,
+		HostKeyCallback: hostKeyCallback(),
+		Timeout:         20 * time.Second,
 	}
-	defer local.Close()
 
-	ftp, err := sftp.NewClient(c.client)
-	if err != nil {
-		return err
+	ag := sshAgent()
+	if keyPath != "" {
+		_, err := os.Stat(keyPath)
+		switch {
+		case err == nil:
+			signer, err := keySigner(keyPath, ag != nil)
+			if err != nil {
+				return nil, err
+			}
+			if signer != nil {
+				config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+			}
+		case ag == nil:
+			return nil, fmt.Errorf("unable to read private key: %w", err)
+		}
+	}
+	if ag != nil {
+		config.Auth = append(config.Auth, ssh.PublicKeysCallback(ag.Signers))
+	}
+	if len(config.Auth) > 0 {
+		return config, nil
 	}
-	defer ftp.Close()
 
-	remote, err := ftp.Create(remotePath)
+	pass, err := readPassword("    Enter ssh password for " + user + ": ")
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("no ssh key or agent for %s, unable to get ssh password: %w", user, err)
 	}
-	defer remote.Close()
+	config.Auth = append(config.Auth, ssh.Password(string(pass)))
+	return config, nil
+}
 
-	_, err = io.Copy(remote, local)
-	return err
+// sshClient is a handle of pooled SSH connection (see ssh_pool.go)
+type sshClient struct {
+	conn    *sshConn
+	node    string // node name for command audit
+	cluster string
 }
 
-func (c sshClient) Download(remotePath string, localPath string) error {
-	local, err := os.Create(localPath)
-	if err != nil {
-		return err
+func GetSshClient(user, addr, keyPath string) sshClient {
+	conn, err := sshPool.conn(nil, user, addr, keyPath, nil)
+	if err == nil {
+		_, err = conn.get()
 	}
-	defer local.Close()
-
-	ftp, err := sftp.NewClient(c.client)
 	if err != nil {
-		return err
+		Fatalf("Ssh Dial %s@%s error: %s", user, addr, err.Error())
 	}
-	defer ftp.Close()
 
-	remote, err := ftp.Open(remotePath)
-	if err != nil {
-		return err
-	}
-	defer remote.Close()
+	conn.clients.Add(1)
+	return sshClient{conn: conn}
+}
 
-	if _, err = io.Copy(local, remote); err != nil {
-		return err
+// Close releases the client. Pooled connection stays open for other clients
+func (c sshClient) Close() error {
+	c.conn.clients.Add(-1)
+	if !c.conn.pooled {
+		c.conn.close()
 	}
-
-	return local.Sync()
+	return nil
 }
 
+// Dial connects to addr through the client (retries during SshDialTimeout)
 func (c sshClient) Dial(n, addr string) (net.Conn, error) {
-	for i := 0; ; i++ {
-		conn, err := c.client.Dial(n, addr)
-		if err == nil {
-			return conn, nil
-		}
-
-		if i >= retries {
-			Fatalf("Fwd Dial '%s' error: %s", addr, err.Error())
-		}
-		time.Sleep(10 * time.Second)
-	}
+	return c.conn.dial(n, addr)
 }
 
 func (c sshClient) GetFwdClient(user, addr, keyPath string) sshClient {
-	conn, _ := c.Dial("tcp", addr)
-
-	config := newSshConfig(user, keyPath)
-	ncc, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	client, err := c.getFwdClient(user, addr, keyPath, nil)
 	if err != nil {
-		Fatalf("NewClientConn '%s@%s' error: %s", user, addr, err.Error())
+		Fatalf("%s", err.Error())
 	}
-
-	return sshClient{client: ssh.NewClient(ncc, chans, reqs)}
+	return client
 }
 
-func (c sshClient) NewTunnel(lAddr, rAddr string) {
-	listener, err := net.Listen("tcp", lAddr)
+// getFwdClient connects to addr through c, hostKey overrides host key verification if set
+func (c sshClient) getFwdClient(user, addr, keyPath string, hostKey ssh.HostKeyCallback) (sshClient, error) {
+	conn, err := sshPool.conn(c.conn, user, addr, keyPath, hostKey)
+	if err == nil {
+		_, err = conn.get()
+	}
 	if err != nil {
-		Fatalf("New tunnel Listen error: %s", err.Error())
+		return sshClient{}, fmt.Errorf("NewClientConn '%s@%s' error: %w", user, addr, err)
 	}
-	defer listener.Close()
-
-	for {
-		local, err := listener.Accept()
-		if err != nil {
-			Fatalf("Accept listener error: %s", err.Error())
-		}
-
-		go func() {
-			remote, err := c.Dial("tcp", rAddr)
-			if err != nil {
-				Fatalf("Accept tcp connection error: %s", err.Error())
-			}
-
-			done := make(chan struct{})
 
-			go func() {
-				_, _ = io.Copy(local, remote)
-				done <- struct{}{}
-			}()
-
-			go func() {
-				_, _ = io.Copy(remote, local)
-				done <- struct{}{}
-			}()
-
-			<-done
-			_ = remote.Close()
-			_ = local.Close()
-		}()
-	}
+	conn.clients.Add(1)
+	return sshClient{conn: conn}, nil
 }
 
+// Exec runs command and returns combined output
 func (c sshClient) Exec(cmd string) (string, error) {
-	sess, err := c.client.NewSession()
-	if err != nil {
-		return "", err
-	}
-
-	defer sess.Close()
-
-	out, err := sess.CombinedOutput(cmd)
-	return string(out), err
+	var combined bytes.Buffer
+	mx := &sync.Mutex{}
+	_, err := c.Run(context.Background(), cmd, ExecOpts{
+		Stdout: lockedWriter{mx, &combined},
+		Stderr: lockedWriter{mx, &combined},
+	})
+	return combined.String(), err
 }
 
 func (c sshClient) ExecFatal(cmd string) string {
@@ -0,0 +1,192 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ExecOpts are options of remote command run
+type ExecOpts struct {
+	Stdin   io.Reader
+	Stdout  io.Writer // streams stdout (also collected in ExecResult)
+	Stderr  io.Writer // streams stderr (also collected in ExecResult)
+	Env     map[string]string
+	Sudo    bool          // run as root (sudo -n -H)
+	Timeout time.Duration // remote command is run under timeout(1), session is killed a bit later
+	Redact  bool          // output is secret (kubeconfig, keys), it is not saved in audit transcript and timeline
+}
+
+// ExecResult is result of remote command. ExitCode is -1 if command didn't finish (transport error, cancel)
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+}
+
+// Output returns stdout and stderr
+func (r ExecResult) Output() string {
+	return r.Stdout + r.Stderr
+}
+
+// command wraps cmd with env, sudo and timeout
+func (o ExecOpts) command(cmd string) string {
+	if len(o.Env) == 0 && !o.Sudo && o.Timeout == 0 {
+		return cmd
+	}
+
+	args := []string{}
+	if o.Sudo {
+		args = append(args, "sudo", "-n", "-H")
+	}
+	if o.Timeout > 0 {
+		args = append(args, "timeout", "-k", "10", fmt.Sprintf("%d", int(o.Timeout.Seconds()+0.5)))
+	}
+	if len(o.Env) > 0 {
+		args = append(args, "env")
+		keys := make([]string, 0, len(o.Env))
+		for k := range o.Env {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			args = append(args, shellQuote(k+"="+o.Env[k]))
+		}
+	}
+	return strings.Join(args, " ") + " sh -c " + shellQuote(cmd)
+}
+
+// Run runs command. Error is *ssh.ExitError for non-zero exit code, other errors are transport ones,
+// timeout or ctx cancel (remote command is killed)
+//
+//	res, err := client.Run(ctx, "cat /root/.kube/config", util.ExecOpts{Sudo: true, Timeout: time.Minute, Redact: true})
+func (c sshClient) Run(ctx context.Context, cmd string, opts ExecOpts) (res ExecResult, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout+15*time.Second)
+		defer cancel()
+	}
+
+	cmd = opts.command(cmd)
+	rec := &CommandRecord{Node: c.node, Host: c.conn.addr, Transport: TransportSsh, Command: cmd, Start: time.Now(), Redacted: opts.Redact, cluster: c.cluster}
+	res.ExitCode = -1
+	var stdout, stderr bytes.Buffer
+	mx := &sync.Mutex{}
+	defer func() {
+		mx.Lock()
+		res.Stdout, res.Stderr = stdout.String(), stderr.String()
+		mx.Unlock()
+		res.Duration = time.Since(rec.Start)
+	}()
+
+	sess, err := c.conn.session()
+	if err != nil {
+		auditCommand(rec, err)
+		return res, err
+	}
+	defer c.conn.closeSession(sess)
+
+	sess.Stdin = opts.Stdin
+	sess.Stdout, sess.Stderr = lockedWriter{mx, &stdout}, lockedWriter{mx, &stderr}
+	if opts.Stdout != nil {
+		sess.Stdout = io.MultiWriter(sess.Stdout, opts.Stdout)
+	}
+	if opts.Stderr != nil {
+		sess.Stderr = io.MultiWriter(sess.Stderr, opts.Stderr)
+	}
+
+	if err = sess.Start(cmd); err != nil {
+		auditCommand(rec, err)
+		return res, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- sess.Wait() }()
+
+	select {
+	case err = <-done:
+		res.ExitCode = exitCode(err)
+		if res.ExitCode == 124 && opts.Timeout > 0 {
+			err = fmt.Errorf("timeout %s: %w", opts.Timeout, err)
+		}
+	case <-ctx.Done():
+		_ = sess.Signal(ssh.SIGKILL)
+		_ = sess.Close()
+		err = fmt.Errorf("command killed: %w", ctx.Err())
+	}
+
+	mx.Lock()
+	rec.Stdout, rec.Stderr = stdout.String(), stderr.String()
+	mx.Unlock()
+	auditCommand(rec, err)
+	return res, err
+}
+
+type lockedWriter struct {
+	mx *sync.Mutex
+	w  io.Writer
+}
+
+func (w lockedWriter) Write(p []byte) (int, error) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	return w.w.Write(p)
+}
+
+// lineWriter calls log for each written line
+type lineWriter struct {
+	mx  sync.Mutex
+	buf []byte
+	log func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.log(strings.TrimRight(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush logs rest of output without line end
+func (w *lineWriter) Flush() {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if len(w.buf) > 0 {
+		w.log(string(w.buf))
+		w.buf = nil
+	}
+}
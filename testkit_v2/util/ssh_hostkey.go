@@ -0,0 +1,137 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Host key verification modes (-hostkeys)
+const (
+	HostKeyStrict   = "strict"   // keys from known_hosts (-knownhosts) and keys pinned during the run
+	HostKeyTofu     = "tofu"     // trust on first use: unknown keys are recorded into run-scoped known_hosts
+	HostKeyInsecure = "insecure" // no verification
+)
+
+var (
+	HostKeyMode   = HostKeyTofu
+	KnownHosts    = filepath.Join(os.Getenv("HOME"), ".ssh", "known_hosts")
+	RunKnownHosts = filepath.Join(os.TempDir(), "sds-e2e-known_hosts-"+RunID)
+
+	hostKeys = hostKeyStore{keys: map[string]ssh.PublicKey{}}
+)
+
+// hostKeyStore keeps host keys trusted during the run (recorded on first use or pinned)
+type hostKeyStore struct {
+	mx   sync.Mutex
+	keys map[string]ssh.PublicKey
+}
+
+func hostKeyCallback() ssh.HostKeyCallback {
+	if HostKeyMode == HostKeyInsecure {
+		return ssh.InsecureIgnoreHostKey()
+	}
+	return hostKeys.check
+}
+
+func (s *hostKeyStore) check(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	host := knownhosts.Normalize(hostname)
+
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if known, ok := s.keys[host]; ok {
+		if bytes.Equal(known.Marshal(), key.Marshal()) {
+			return nil
+		}
+		return fmt.Errorf("host key mismatch for %s: got %s, pinned %s (machine was replaced?)",
+			host, ssh.FingerprintSHA256(key), ssh.FingerprintSHA256(known))
+	}
+
+	if _, err := os.Stat(KnownHosts); err == nil {
+		check, err := knownhosts.New(KnownHosts)
+		if err != nil {
+			return fmt.Errorf("known_hosts %s: %w", KnownHosts, err)
+		}
+		err = check(hostname, remote, key)
+		keyErr := &knownhosts.KeyError{}
+		if err == nil || !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+			return err
+		}
+	}
+
+	if HostKeyMode == HostKeyStrict {
+		return fmt.Errorf("unknown host key for %s: %s %s (add it to %s)", host, key.Type(), ssh.FingerprintSHA256(key), KnownHosts)
+	}
+
+	Infof("Trust host key on first use %s: %s %s", host, key.Type(), ssh.FingerprintSHA256(key))
+	return s.save(host, key)
+}
+
+// save stores key to memory and run known_hosts. Caller holds s.mx
+func (s *hostKeyStore) save(host string, key ssh.PublicKey) error {
+	s.keys[host] = key
+	f, err := os.OpenFile(RunKnownHosts, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{host}, key))
+	return err
+}
+
+// PinHostKey trusts key of the host for the rest of the run (replaces previous one)
+func PinHostKey(addr string, key ssh.PublicKey) error {
+	host := knownhosts.Normalize(addr)
+	Debugf("Pin host key %s: %s %s", host, key.Type(), ssh.FingerprintSHA256(key))
+
+	hostKeys.mx.Lock()
+	defer hostKeys.mx.Unlock()
+	return hostKeys.save(host, key)
+}
+
+// pinVmHostKey waits for cloud-init on freshly created VM and pins the host key it presents then
+func (c sshClient) pinVmHostKey(user, addr, keyPath string) error {
+	client, err := c.getFwdClient(user, addr, keyPath, ssh.InsecureIgnoreHostKey())
+	if err != nil {
+		return err
+	}
+	out, err := client.Exec("cloud-init status --wait")
+	_ = client.Close()
+	if err != nil && exitCode(err) != 2 { // 2 - finished with recoverable errors
+		return fmt.Errorf("cloud-init on %s: %w\n%s", addr, err, out)
+	}
+
+	var key ssh.PublicKey
+	client, err = c.getFwdClient(user, addr, keyPath, func(_ string, _ net.Addr, k ssh.PublicKey) error {
+		key = k
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_ = client.Close()
+	return PinHostKey(addr, key)
+}
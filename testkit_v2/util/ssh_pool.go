@@ -0,0 +1,375 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// SSH connections are pooled per user, address and hop chain. Clients share one connection,
+// it is checked with keepalives and reconnected (with the whole hop chain) on next use after drop
+
+var (
+	SshKeepAlive   = 15 * time.Second
+	SshDialTimeout = 15 * time.Minute // retries of dial through jump host (target may be booting)
+
+	sshPool = sshManager{conns: map[string]*sshConn{}}
+)
+
+type sshManager struct {
+	mx    sync.Mutex
+	conns map[string]*sshConn
+}
+
+// sshConn is connection to addr, directly or through parent (jump host)
+type sshConn struct {
+	key    string // user@addr chain
+	id     string // pool key: key chain with key paths
+	addr   string
+	parent *sshConn
+	config *ssh.ClientConfig
+	pooled bool
+
+	mx       sync.Mutex
+	client   *ssh.Client
+	dialing  chan struct{} // closed when dial in progress finishes
+	dialErr  error
+	stop     chan struct{} // stops keepalive
+	connects int
+	sessions atomic.Int64
+	clients  atomic.Int64
+}
+
+// conn returns pooled connection, hostKey overrides host key verification (such connection is not pooled)
+func (m *sshManager) conn(parent *sshConn, user, addr, keyPath string, hostKey ssh.HostKeyCallback) (*sshConn, error) {
+	key := user + "@" + addr
+	id := key + " " + keyPath
+	if parent != nil {
+		key = parent.key + " > " + key
+		id = parent.id + " > " + id
+	}
+
+	if hostKey == nil {
+		m.mx.Lock()
+		c, ok := m.conns[id]
+		m.mx.Unlock()
+		if ok {
+			return c, nil
+		}
+	}
+
+	// key files, agent and passphrase prompt are read without pool lock
+	config, err := newSshConfig(user, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	c := &sshConn{key: key, id: id, addr: addr, parent: parent, config: config, pooled: hostKey == nil}
+	if hostKey != nil {
+		c.config.HostKeyCallback = hostKey
+		return c, nil
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if pooled, ok := m.conns[id]; ok {
+		return pooled, nil
+	}
+	m.conns[id] = c
+	return c, nil
+}
+
+// get returns connected client, connects if needed. Dial (up to SshDialTimeout through jump host)
+// runs without lock, concurrent callers wait for it
+func (c *sshConn) get() (*ssh.Client, error) {
+	c.mx.Lock()
+	if c.client != nil {
+		defer c.mx.Unlock()
+		return c.client, nil
+	}
+	if dialing := c.dialing; dialing != nil {
+		c.mx.Unlock()
+		<-dialing
+		c.mx.Lock()
+		defer c.mx.Unlock()
+		if c.client != nil {
+			return c.client, nil
+		}
+		return nil, c.dialErr
+	}
+	dialing := make(chan struct{})
+	c.dialing = dialing
+	c.mx.Unlock()
+
+	client, err := c.connect()
+
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.dialing, c.dialErr = nil, err
+	close(dialing)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.connects > 0 {
+		Infof("SSH %s reconnected", c.key)
+	}
+	c.connects++
+	c.client = client
+	go func() {
+		_ = client.Wait()
+		c.drop(client)
+	}()
+	if c.pooled && c.stop == nil {
+		c.stop = make(chan struct{})
+		go c.keepAlive(c.stop)
+	}
+	return client, nil
+}
+
+func (c *sshConn) connect() (*ssh.Client, error) {
+	var client *ssh.Client
+	if c.parent == nil {
+		var err error
+		if client, err = ssh.Dial("tcp", c.addr, c.config); err != nil {
+			return nil, err
+		}
+	} else {
+		conn, err := c.parent.dial("tcp", c.addr)
+		if err != nil {
+			return nil, err
+		}
+		ncc, chans, reqs, err := ssh.NewClientConn(conn, c.addr, c.config)
+		if err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		client = ssh.NewClient(ncc, chans, reqs)
+	}
+
+	if ag := sshAgent(); ag != nil && SshAgentForward {
+		if err := agent.ForwardToAgent(client, ag); err != nil {
+			Warnf("SSH %s agent forwarding: %s", c.key, err.Error())
+		}
+	}
+	return client, nil
+}
+
+// drop closes client, next get reconnects
+func (c *sshConn) drop(client *ssh.Client) {
+	c.mx.Lock()
+	if c.client == client {
+		c.client = nil
+		Debugf("SSH %s disconnected", c.key)
+	}
+	c.mx.Unlock()
+	_ = client.Close()
+}
+
+// keepAlive checks connection until stop is closed (close)
+func (c *sshConn) keepAlive(stop chan struct{}) {
+	ticker := time.NewTicker(SshKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		c.mx.Lock()
+		client := c.client
+		c.mx.Unlock()
+		if client != nil && !sshAlive(client) {
+			Warnf("SSH %s keepalive failed", c.key)
+			c.drop(client)
+		}
+	}
+}
+
+func sshAlive(client *ssh.Client) bool {
+	res := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		res <- err
+	}()
+	select {
+	case err := <-res:
+		return err == nil
+	case <-time.After(SshKeepAlive):
+		return false
+	}
+}
+
+// open runs f with connected client, reconnects and runs it again if connection was dead
+func (c *sshConn) open(f func(*ssh.Client) error) error {
+	client, err := c.get()
+	if err != nil {
+		return err
+	}
+	if err = f(client); err == nil || sshAlive(client) {
+		return err
+	}
+
+	c.drop(client)
+	if client, err = c.get(); err != nil {
+		return err
+	}
+	return f(client)
+}
+
+func (c *sshConn) session() (*ssh.Session, error) {
+	var sess *ssh.Session
+	err := c.open(func(client *ssh.Client) (err error) {
+		sess, err = client.NewSession()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sshAgent() != nil && SshAgentForward {
+		if err := agent.RequestAgentForwarding(sess); err != nil {
+			Debugf("SSH %s agent forwarding: %s", c.key, err.Error())
+		}
+	}
+	c.sessions.Add(1)
+	return sess, nil
+}
+
+func (c *sshConn) closeSession(sess *ssh.Session) {
+	_ = sess.Close()
+	c.sessions.Add(-1)
+}
+
+func (c *sshConn) sftp() (*sftp.Client, error) {
+	var ftp *sftp.Client
+	err := c.open(func(client *ssh.Client) (err error) {
+		ftp, err = sftp.NewClient(client)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.sessions.Add(1)
+	return ftp, nil
+}
+
+func (c *sshConn) closeSftp(ftp *sftp.Client) {
+	_ = ftp.Close()
+	c.sessions.Add(-1)
+}
+
+// dial connects to addr through the connection, retries during SshDialTimeout
+func (c *sshConn) dial(n, addr string) (net.Conn, error) {
+	var conn net.Conn
+	err := WaitFor(context.Background(), SshDialTimeout, func(context.Context) (err error) {
+		conn, err = c.dialOnce(n, addr)
+		return err
+	})
+	return conn, err
+}
+
+func (c *sshConn) dialOnce(n, addr string) (net.Conn, error) {
+	var conn net.Conn
+	err := c.open(func(client *ssh.Client) (err error) {
+		conn, err = client.Dial(n, addr)
+		return err
+	})
+	return conn, err
+}
+
+// close drops connection and stops keepalive, next get connects again
+func (c *sshConn) close() {
+	c.mx.Lock()
+	client := c.client
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.mx.Unlock()
+	if client != nil {
+		c.drop(client)
+	}
+}
+
+// CloseSshPool closes pooled connections and stops their keepalives. Called by the last test (TestFinalizer)
+func CloseSshPool() {
+	sshPool.mx.Lock()
+	conns := make([]*sshConn, 0, len(sshPool.conns))
+	for _, c := range sshPool.conns {
+		conns = append(conns, c)
+	}
+	sshPool.mx.Unlock()
+
+	// nested connections first, they go through parents
+	slices.SortFunc(conns, func(a, b *sshConn) int { return len(b.id) - len(a.id) })
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+/*  Stats  */
+
+type SshConnStats struct {
+	Conn         string `json:"conn"` // user@addr chain
+	Connected    bool   `json:"connected"`
+	Reconnects   int    `json:"reconnects"`
+	OpenSessions int64  `json:"openSessions"`
+	Clients      int64  `json:"clients"`
+}
+
+type SshStats struct {
+	Reconnects   int            `json:"reconnects"`
+	OpenSessions int64          `json:"openSessions"`
+	Conns        []SshConnStats `json:"conns"`
+}
+
+// SshPoolStats returns stats of pooled SSH connections
+func SshPoolStats() SshStats {
+	sshPool.mx.Lock()
+	conns := make([]*sshConn, 0, len(sshPool.conns))
+	for _, c := range sshPool.conns {
+		conns = append(conns, c)
+	}
+	sshPool.mx.Unlock()
+	slices.SortFunc(conns, func(a, b *sshConn) int { return strings.Compare(a.key, b.key) })
+
+	stats := SshStats{Conns: []SshConnStats{}}
+	for _, c := range conns {
+		c.mx.Lock()
+		s := SshConnStats{
+			Conn:         c.key,
+			Connected:    c.client != nil,
+			Reconnects:   max(c.connects-1, 0),
+			OpenSessions: c.sessions.Load(),
+			Clients:      c.clients.Load(),
+		}
+		c.mx.Unlock()
+		stats.Reconnects += s.Reconnects
+		stats.OpenSessions += s.OpenSessions
+		stats.Conns = append(stats.Conns, s)
+	}
+	return stats
+}
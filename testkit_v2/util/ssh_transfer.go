@@ -0,0 +1,428 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// SyncOpts are options of directory transfer
+type SyncOpts struct {
+	Tar        bool // transfer as tar stream (faster for many small files), requires tar on remote host
+	Sudo       bool // run remote tar as root (Tar only)
+	SkipVerify bool // don't check sha256 (files changing during transfer, e.g. logs)
+}
+
+// Upload copies local file to remote path: parent dirs are created, file mode is kept,
+// file is written to temp file and renamed after sha256 check
+func (c sshClient) Upload(localPath, remotePath string) error {
+	ftp, err := c.conn.sftp()
+	if err != nil {
+		return err
+	}
+	defer c.conn.closeSftp(ftp)
+	return c.uploadFile(ftp, localPath, remotePath, true)
+}
+
+// UploadFiles copies local files to remote dir with one SFTP session
+func (c sshClient) UploadFiles(remoteDir string, localPaths ...string) error {
+	ftp, err := c.conn.sftp()
+	if err != nil {
+		return err
+	}
+	defer c.conn.closeSftp(ftp)
+	for _, p := range localPaths {
+		if err := c.uploadFile(ftp, p, path.Join(remoteDir, filepath.Base(p)), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Download copies remote file to local path: parent dirs are created, file mode is kept,
+// file is written to temp file and renamed after sha256 check
+func (c sshClient) Download(remotePath, localPath string) error {
+	ftp, err := c.conn.sftp()
+	if err != nil {
+		return err
+	}
+	defer c.conn.closeSftp(ftp)
+	return c.downloadFile(ftp, remotePath, localPath, true)
+}
+
+// UploadDir copies local dir to remote dir recursively with file modes
+func (c sshClient) UploadDir(localDir, remoteDir string, opts SyncOpts) error {
+	if opts.Tar {
+		return c.uploadTar(context.Background(), localDir, remoteDir, opts)
+	}
+
+	ftp, err := c.conn.sftp()
+	if err != nil {
+		return err
+	}
+	defer c.conn.closeSftp(ftp)
+
+	return filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(localDir, p)
+		remotePath := path.Join(remoteDir, filepath.ToSlash(rel))
+		switch {
+		case d.IsDir():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if err := ftp.MkdirAll(remotePath); err != nil {
+				return fmt.Errorf("mkdir %s: %w", remotePath, err)
+			}
+			return ftp.Chmod(remotePath, info.Mode().Perm())
+		case d.Type().IsRegular():
+			return c.uploadFile(ftp, p, remotePath, !opts.SkipVerify)
+		}
+		Debugf("Upload: skip %s (%s)", p, d.Type())
+		return nil
+	})
+}
+
+// DownloadDir copies remote dir to local dir recursively with file modes
+func (c sshClient) DownloadDir(remoteDir, localDir string, opts SyncOpts) error {
+	if opts.Tar {
+		return c.downloadTar(context.Background(), remoteDir, []string{"."}, localDir, opts)
+	}
+
+	ftp, err := c.conn.sftp()
+	if err != nil {
+		return err
+	}
+	defer c.conn.closeSftp(ftp)
+
+	walker := ftp.Walk(remoteDir)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(strings.TrimPrefix(walker.Path(), remoteDir), "/")
+		localPath := filepath.Join(localDir, filepath.FromSlash(rel))
+		info := walker.Stat()
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(localPath, 0755); err != nil {
+				return err
+			}
+			if err := os.Chmod(localPath, info.Mode().Perm()|0700); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if err := c.downloadFile(ftp, walker.Path(), localPath, !opts.SkipVerify); err != nil {
+				return err
+			}
+		default:
+			Debugf("Download: skip %s (%s)", walker.Path(), info.Mode().Type())
+		}
+	}
+	return nil
+}
+
+func (c sshClient) uploadFile(ftp *sftp.Client, localPath, remotePath string, verify bool) error {
+	local, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer local.Close()
+	info, err := local.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := ftp.MkdirAll(path.Dir(remotePath)); err != nil {
+		return fmt.Errorf("mkdir %s: %w", path.Dir(remotePath), err)
+	}
+	tmp := remotePath + ".tmp-" + RandString(6)
+	err = func() error {
+		remote, err := ftp.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return err
+		}
+		defer remote.Close()
+
+		hash := sha256.New()
+		if _, err = io.Copy(remote, io.TeeReader(local, hash)); err != nil {
+			return err
+		}
+		if err = remote.Close(); err != nil {
+			return err
+		}
+		if err = ftp.Chmod(tmp, info.Mode().Perm()); err != nil {
+			return err
+		}
+		if verify {
+			return c.verifySha256(tmp, hex.EncodeToString(hash.Sum(nil)))
+		}
+		return nil
+	}()
+	if err == nil {
+		err = ftp.PosixRename(tmp, remotePath)
+	}
+	if err != nil {
+		_ = ftp.Remove(tmp)
+		return fmt.Errorf("upload %s to %s: %w", localPath, remotePath, err)
+	}
+	return nil
+}
+
+func (c sshClient) downloadFile(ftp *sftp.Client, remotePath, localPath string, verify bool) error {
+	remote, err := ftp.Open(remotePath)
+	if err != nil {
+		return err
+	}
+	defer remote.Close()
+	info, err := remote.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return err
+	}
+	local, err := os.CreateTemp(filepath.Dir(localPath), filepath.Base(localPath)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(local.Name())
+	defer local.Close()
+
+	hash := sha256.New()
+	if _, err = io.Copy(local, io.TeeReader(remote, hash)); err != nil {
+		return fmt.Errorf("download %s: %w", remotePath, err)
+	}
+	if err = local.Sync(); err != nil {
+		return err
+	}
+	if err = local.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if verify {
+		if err = c.verifySha256(remotePath, hex.EncodeToString(hash.Sum(nil))); err != nil {
+			return fmt.Errorf("download %s: %w", remotePath, err)
+		}
+	}
+	if err = local.Close(); err != nil {
+		return err
+	}
+	return os.Rename(local.Name(), localPath)
+}
+
+// verifySha256 compares sha256 of remote file with sum
+func (c sshClient) verifySha256(remotePath, sum string) error {
+	res, err := c.Run(context.Background(), "sha256sum "+shellQuote(remotePath), ExecOpts{})
+	if err != nil {
+		return fmt.Errorf("sha256sum %s: %w: %s", remotePath, err, res.Stderr)
+	}
+	remoteSum, _, _ := strings.Cut(res.Stdout, " ")
+	if remoteSum != sum {
+		return fmt.Errorf("sha256 mismatch of %s: %s != %s", remotePath, remoteSum, sum)
+	}
+	return nil
+}
+
+/*  Tar stream  */
+
+// uploadTar packs local dir to tar stream extracted on remote host, then checks sha256 of files
+func (c sshClient) uploadTar(ctx context.Context, localDir, remoteDir string, opts SyncOpts) error {
+	pr, pw := io.Pipe()
+	sums := map[string]string{}
+	go func() {
+		pw.CloseWithError(writeTar(pw, localDir, sums))
+	}()
+
+	cmd := fmt.Sprintf("mkdir -p %[1]s && tar -x -p --no-same-owner -C %[1]s", shellQuote(remoteDir))
+	res, err := c.Run(ctx, cmd, ExecOpts{Stdin: pr, Sudo: opts.Sudo})
+	_ = pr.Close()
+	if err != nil {
+		return fmt.Errorf("upload %s to %s: %w: %s", localDir, remoteDir, err, res.Stderr)
+	}
+	if opts.SkipVerify || len(sums) == 0 {
+		return nil
+	}
+
+	cmd = fmt.Sprintf("cd %s && sha256sum --quiet -c -", shellQuote(remoteDir))
+	res, err = c.Run(ctx, cmd, ExecOpts{Stdin: strings.NewReader(sha256List(sums)), Sudo: opts.Sudo})
+	if err != nil {
+		return fmt.Errorf("upload %s to %s: sha256 check: %w: %s", localDir, remoteDir, err, res.Output())
+	}
+	return nil
+}
+
+// downloadTar extracts tar stream of remote dir members to local dir, then checks sha256 of files
+func (c sshClient) downloadTar(ctx context.Context, remoteDir string, members []string, localDir string, opts SyncOpts) error {
+	quoted := make([]string, len(members))
+	for i, m := range members {
+		quoted[i] = shellQuote(m)
+	}
+	cmd := fmt.Sprintf("tar -c --ignore-failed-read -C %s %s", shellQuote(remoteDir), strings.Join(quoted, " "))
+
+	pr, pw := io.Pipe()
+	sums := map[string]string{}
+	done := make(chan error, 1)
+	go func() {
+		err := readTar(pr, localDir, sums)
+		_, _ = io.Copy(io.Discard, pr)
+		done <- err
+	}()
+	res, err := c.Run(ctx, cmd, ExecOpts{Stdout: pw, Sudo: opts.Sudo, Redact: true})
+	_ = pw.Close()
+	if tarErr := <-done; err == nil {
+		err = tarErr
+	}
+	// tar exits with 1 if some files changed or were missing (--ignore-failed-read)
+	if err != nil && res.ExitCode != 1 {
+		return fmt.Errorf("download %s from %s: %w: %s", strings.Join(members, " "), remoteDir, err, res.Stderr)
+	}
+	if opts.SkipVerify || len(sums) == 0 {
+		return nil
+	}
+
+	cmd = fmt.Sprintf("cd %s && sha256sum --quiet -c -", shellQuote(remoteDir))
+	res, err = c.Run(ctx, cmd, ExecOpts{Stdin: strings.NewReader(sha256List(sums)), Sudo: opts.Sudo})
+	if err != nil {
+		return fmt.Errorf("download %s: sha256 check: %w: %s", remoteDir, err, res.Output())
+	}
+	return nil
+}
+
+// sha256List returns sums in sha256sum format
+func sha256List(sums map[string]string) string {
+	var list strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(sums)) {
+		fmt.Fprintf(&list, "%s  %s\n", sums[name], name)
+	}
+	return list.String()
+}
+
+// writeTar writes regular files and dirs of dir to tar stream, sums gets sha256 of files
+func writeTar(w io.Writer, dir string, sums map[string]string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		hdr.Uname, hdr.Gname = "", ""
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		hash := sha256.New()
+		if _, err := io.Copy(tw, io.TeeReader(f, hash)); err != nil {
+			return err
+		}
+		sums["./"+hdr.Name] = hex.EncodeToString(hash.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// readTar extracts regular files and dirs of tar stream to dir, sums gets sha256 of files
+func readTar(r io.Reader, dir string, sums map[string]string) error {
+	tr := tar.NewReader(bufio.NewReader(r))
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := path.Clean("/" + hdr.Name)[1:]
+		if name == "" {
+			continue
+		}
+		localPath := filepath.Join(dir, filepath.FromSlash(name))
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(localPath, 0755); err != nil {
+				return err
+			}
+			if err := os.Chmod(localPath, hdr.FileInfo().Mode().Perm()|0700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+			if err != nil {
+				return err
+			}
+			hash := sha256.New()
+			_, err = io.Copy(f, io.TeeReader(tr, hash))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return err
+			}
+			sums[hdr.Name] = hex.EncodeToString(hash.Sum(nil))
+		default:
+			Debugf("Download: skip %s (tar type %c)", hdr.Name, hdr.Typeflag)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	tunnels   []*Tunnel
+	tunnelsMx sync.Mutex
+)
+
+// Tunnel forwards local TCP port to remote address through SSH client.
+// Local port 0 allocates ephemeral port, see Port after Start
+//
+//	tun := client.NewTunnel("127.0.0.1:0", "127.0.0.1:6443")
+//	if err := tun.Start(); err != nil { ... }
+//	defer tun.Close()
+//	err := tun.Ready(ctx, time.Minute)
+type Tunnel struct {
+	LocalAddr  string
+	RemoteAddr string
+
+	client   sshClient
+	listener net.Listener
+	mx       sync.Mutex
+	conns    map[net.Conn]struct{}
+	err      error
+	closed   bool
+	done     chan struct{}
+}
+
+// NewTunnel creates tunnel from lAddr to rAddr (not started)
+func (c sshClient) NewTunnel(lAddr, rAddr string) *Tunnel {
+	return &Tunnel{LocalAddr: lAddr, RemoteAddr: rAddr, client: c, conns: map[net.Conn]struct{}{}, done: make(chan struct{})}
+}
+
+// StartTunnel creates and starts tunnel
+func (c sshClient) StartTunnel(lAddr, rAddr string) (*Tunnel, error) {
+	t := c.NewTunnel(lAddr, rAddr)
+	return t, t.Start()
+}
+
+// Start listens local address and forwards accepted connections in background
+func (t *Tunnel) Start() error {
+	listener, err := net.Listen("tcp", t.LocalAddr)
+	if err != nil {
+		return fmt.Errorf("tunnel %s listen: %w", t.LocalAddr, err)
+	}
+	t.listener = listener
+	t.LocalAddr = listener.Addr().String()
+	Debugf("Tunnel %s -> %s started", t.LocalAddr, t.RemoteAddr)
+
+	tunnelsMx.Lock()
+	tunnels = append(tunnels, t)
+	tunnelsMx.Unlock()
+
+	go t.serve()
+	return nil
+}
+
+// Port returns local port (allocated one for ephemeral port)
+func (t *Tunnel) Port() string {
+	_, port, _ := net.SplitHostPort(t.LocalAddr)
+	return port
+}
+
+// Ready waits until remote address is reachable through the tunnel
+func (t *Tunnel) Ready(ctx context.Context, timeout time.Duration) error {
+	if t.listener == nil {
+		return fmt.Errorf("tunnel %s -> %s not started", t.LocalAddr, t.RemoteAddr)
+	}
+	return WaitFor(ctx, timeout, func(context.Context) error {
+		if err := t.Err(); err != nil {
+			return err
+		}
+		conn, err := t.client.conn.dialOnce("tcp", t.RemoteAddr)
+		if err != nil {
+			return fmt.Errorf("tunnel %s -> %s: %w", t.LocalAddr, t.RemoteAddr, err)
+		}
+		return conn.Close()
+	})
+}
+
+// Err returns error that stopped the tunnel
+func (t *Tunnel) Err() error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.err
+}
+
+// Close stops listening and closes forwarded connections
+func (t *Tunnel) Close() error {
+	t.mx.Lock()
+	if t.closed || t.listener == nil {
+		t.mx.Unlock()
+		return nil
+	}
+	t.closed = true
+	err := t.listener.Close()
+	for conn := range t.conns {
+		_ = conn.Close()
+	}
+	t.mx.Unlock()
+
+	<-t.done
+	Debugf("Tunnel %s -> %s closed", t.LocalAddr, t.RemoteAddr)
+	return err
+}
+
+// CloseTunnels closes all started tunnels
+func CloseTunnels() {
+	tunnelsMx.Lock()
+	list := tunnels
+	tunnels = nil
+	tunnelsMx.Unlock()
+
+	for _, t := range list {
+		_ = t.Close()
+	}
+}
+
+// startApiTunnel starts tunnel to cluster API, allocated ephemeral port (port "0") is stored to port
+func startApiTunnel(client sshClient, port *string, rAddr string) *Tunnel {
+	t, err := client.StartTunnel("127.0.0.1:"+*port, rAddr)
+	if err != nil {
+		Fatalf("%s", err.Error())
+	}
+	*port = t.Port()
+	return t
+}
+
+func (t *Tunnel) serve() {
+	defer close(t.done)
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			t.mx.Lock()
+			if !t.closed {
+				t.err = fmt.Errorf("tunnel %s accept: %w", t.LocalAddr, err)
+				Errorf("%s", t.err.Error())
+			}
+			t.mx.Unlock()
+			return
+		}
+		if !t.track(local) {
+			_ = local.Close()
+			return
+		}
+		go t.forward(local)
+	}
+}
+
+// track registers forwarded connection, false if tunnel is closed
+func (t *Tunnel) track(conn net.Conn) bool {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.closed {
+		return false
+	}
+	t.conns[conn] = struct{}{}
+	return true
+}
+
+func (t *Tunnel) untrack(conn net.Conn) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	delete(t.conns, conn)
+	_ = conn.Close()
+}
+
+func (t *Tunnel) forward(local net.Conn) {
+	defer t.untrack(local)
+
+	remote, err := t.client.conn.dialOnce("tcp", t.RemoteAddr)
+	if err != nil {
+		Errorf("Tunnel %s -> %s dial: %s", t.LocalAddr, t.RemoteAddr, err.Error())
+		return
+	}
+	if !t.track(remote) {
+		_ = remote.Close()
+		return
+	}
+	defer t.untrack(remote)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(local, remote)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(remote, local)
+		done <- struct{}{}
+	}()
+	<-done
+}
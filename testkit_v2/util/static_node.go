@@ -0,0 +1,402 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	policyapi "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	ctrlrtclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const NodeGroupLabel = "node.deckhouse.io/group"
+
+var (
+	StaticInstanceTimeout = 20 * time.Minute // bootstrap or cleaning of static node
+	DrainTimeout          = 5 * time.Minute
+
+	nodeGroupCountMx sync.Mutex // parallel node tests change count of the same NodeGroup
+)
+
+/*  Static Node Group  */
+
+// StaticNodeGroupCount returns spec.staticInstances.count of NodeGroup
+func (cluster *KCluster) StaticNodeGroupCount(ngName string) (int, error) {
+	ng, err := cluster.dyClient.Resource(nodeGroupResource).Get(cluster.ctx, ngName, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+	count, found, err := unstructured.NestedInt64(ng.Object, "spec", "staticInstances", "count")
+	if err != nil || !found {
+		return 0, fmt.Errorf("NodeGroup %s has no staticInstances.count", ngName)
+	}
+	return int(count), nil
+}
+
+func (cluster *KCluster) setStaticNodeGroupCount(ngName string, count int) error {
+	nodeGroupCountMx.Lock()
+	defer nodeGroupCountMx.Unlock()
+	return cluster.patchStaticNodeGroupCount(ngName, count)
+}
+
+// addStaticNodeGroupCount changes count of NodeGroup by delta
+func (cluster *KCluster) addStaticNodeGroupCount(ngName string, delta int) error {
+	nodeGroupCountMx.Lock()
+	defer nodeGroupCountMx.Unlock()
+	count, err := cluster.StaticNodeGroupCount(ngName)
+	if err != nil {
+		return err
+	}
+	return cluster.patchStaticNodeGroupCount(ngName, count+delta)
+}
+
+func (cluster *KCluster) patchStaticNodeGroupCount(ngName string, count int) error {
+	patch := fmt.Sprintf(`{"spec":{"staticInstances":{"count":%d}}}`, count)
+	_, err := cluster.dyClient.Resource(nodeGroupResource).
+		Patch(cluster.ctx, ngName, apitypes.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("scale NodeGroup %s to %d: %w", ngName, count, err)
+	}
+	cluster.Infof("NodeGroup %s: staticInstances.count %d", ngName, count)
+	return nil
+}
+
+// ScaleStaticNodeGroup sets count of static NodeGroup and waits for count Ready nodes in it
+func (cluster *KCluster) ScaleStaticNodeGroup(ngName string, count int) error {
+	if err := cluster.setStaticNodeGroupCount(ngName, count); err != nil {
+		return err
+	}
+	return WaitFor(cluster.ctx, StaticInstanceTimeout, func(ctx context.Context) error {
+		nodes, err := cluster.goClient.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: NodeGroupLabel + "=" + ngName})
+		if err != nil {
+			return err
+		}
+		ready := 0
+		for _, node := range nodes.Items {
+			if nodeReady(&node) {
+				ready++
+			}
+		}
+		if len(nodes.Items) != count || ready != count {
+			return fmt.Errorf("NodeGroup %s nodes: %d, ready %d, want %d", ngName, len(nodes.Items), ready, count)
+		}
+		return nil
+	})
+}
+
+/*  Static Instance lifecycle  */
+
+func (cluster *KCluster) GetStaticInstance(name string) (*StaticInstance, error) {
+	si := &StaticInstance{}
+	if err := cluster.controllerRuntimeClient.Get(cluster.ctx, ctrlrtclient.ObjectKey{Name: name}, si); err != nil {
+		return nil, err
+	}
+	return si, nil
+}
+
+// NodeStaticInstance returns StaticInstance bootstrapped as the node
+func (cluster *KCluster) NodeStaticInstance(nName string) (*StaticInstance, error) {
+	sis, err := cluster.ListStaticInstance()
+	if err != nil {
+		return nil, err
+	}
+	for _, si := range sis {
+		if si.Status.NodeRef != nil && si.Status.NodeRef.Name == nName {
+			return &si, nil
+		}
+	}
+	return nil, fmt.Errorf("no StaticInstance of node %s", nName)
+}
+
+func staticInstancePhase(si *StaticInstance) StaticInstanceStatusCurrentStatusPhase {
+	if si.Status.CurrentStatus == nil {
+		return ""
+	}
+	return si.Status.CurrentStatus.Phase
+}
+
+// WaitStaticInstancePhase waits for StaticInstance in one of phases and logs phase transitions
+func (cluster *KCluster) WaitStaticInstancePhase(name string, phases ...StaticInstanceStatusCurrentStatusPhase) (*StaticInstance, error) {
+	var si *StaticInstance
+	last := StaticInstanceStatusCurrentStatusPhase("-")
+	err := WaitFor(cluster.ctx, StaticInstanceTimeout, func(context.Context) error {
+		var err error
+		si, err = cluster.GetStaticInstance(name)
+		if err != nil {
+			return err
+		}
+		phase := staticInstancePhase(si)
+		if phase != last {
+			cluster.Debugf("StaticInstance %s: %s -> %s", name, last, phase)
+			last = phase
+		}
+		if slices.Contains(phases, phase) {
+			return nil
+		}
+		return fmt.Errorf("StaticInstance %s phase %q, want %v", name, phase, phases)
+	})
+	return si, err
+}
+
+// WaitStaticInstanceDeleted waits for StaticInstance cleaning and deletion
+func (cluster *KCluster) WaitStaticInstanceDeleted(name string) error {
+	return WaitFor(cluster.ctx, StaticInstanceTimeout, func(context.Context) error {
+		si, err := cluster.GetStaticInstance(name)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("StaticInstance %s is not deleted, phase %q", name, staticInstancePhase(si))
+	})
+}
+
+/*  Node drain  */
+
+func nodeReady(node *coreapi.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == coreapi.NodeReady {
+			return c.Status == coreapi.ConditionTrue
+		}
+	}
+	return false
+}
+
+// CordonNode marks node (un)schedulable
+func (cluster *KCluster) CordonNode(nName string, unschedulable bool) error {
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+	_, err := cluster.goClient.CoreV1().Nodes().Patch(cluster.ctx, nName, apitypes.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// drainable is pod to evict on drain: not DaemonSet and not static (mirror) pod
+func drainable(pod *coreapi.Pod) bool {
+	if _, mirror := pod.Annotations[coreapi.MirrorPodAnnotationKey]; mirror {
+		return false
+	}
+	if pod.Status.Phase == coreapi.PodSucceeded || pod.Status.Phase == coreapi.PodFailed {
+		return false
+	}
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
+
+// DrainNode cordons node and evicts its pods (except DaemonSet and static pods), PodDisruptionBudgets are respected
+func (cluster *KCluster) DrainNode(nName string) error {
+	if err := cluster.CordonNode(nName, true); err != nil {
+		return fmt.Errorf("cordon %s: %w", nName, err)
+	}
+	cluster.Infof("Draining node %s", nName)
+	return WaitFor(cluster.ctx, DrainTimeout, func(ctx context.Context) error {
+		pods, err := cluster.goClient.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "spec.nodeName=" + nName})
+		if err != nil {
+			return err
+		}
+		var left []string
+		for _, pod := range pods.Items {
+			if !drainable(&pod) {
+				continue
+			}
+			left = append(left, pod.Namespace+"/"+pod.Name)
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			err := cluster.goClient.CoreV1().Pods(pod.Namespace).EvictV1(ctx, &policyapi.Eviction{
+				ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+			})
+			if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsTooManyRequests(err) {
+				return err
+			}
+		}
+		if len(left) > 0 {
+			return fmt.Errorf("node %s pods: %s", nName, strings.Join(left, ", "))
+		}
+		return nil
+	})
+}
+
+func (cluster *KCluster) waitNodeDeleted(nName string) error {
+	return WaitFor(cluster.ctx, StaticInstanceTimeout, func(ctx context.Context) error {
+		_, err := cluster.goClient.CoreV1().Nodes().Get(ctx, nName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("node %s is not deleted", nName)
+	})
+}
+
+func (cluster *KCluster) waitNodeReady(nName string) error {
+	return WaitFor(cluster.ctx, time.Duration(NodesReadyTimeout)*time.Second, func(ctx context.Context) error {
+		node, err := cluster.goClient.CoreV1().Nodes().Get(ctx, nName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if !nodeReady(node) {
+			return fmt.Errorf("node %s is not Ready", nName)
+		}
+		return nil
+	})
+}
+
+/*  Remove, re-add, replace  */
+
+// RemovedStaticNode is static node removed from cluster, to re-add it
+type RemovedStaticNode struct {
+	Node      string
+	NodeGroup string
+	Instance  *StaticInstance
+	Devices   []string // BlockDevice paths wiped before re-add, nil keeps disks as is
+}
+
+// RemoveStaticNode drains static node, deletes its StaticInstance and then decreases NodeGroup count,
+// so CAPS removes this node and not another one. Node is cleaned up by CAPS (Running -> Cleaning)
+// and deleted from cluster
+//
+//	removed, err := cluster.RemoveStaticNode(nName)
+//	...check LVGs, BlockDevices of node
+//	nName, err = cluster.ReAddStaticNode(removed)
+func (cluster *KCluster) RemoveStaticNode(nName string) (*RemovedStaticNode, error) {
+	node, err := cluster.goClient.CoreV1().Nodes().Get(cluster.ctx, nName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ngName := node.Labels[NodeGroupLabel]
+	if ngName == "" {
+		return nil, fmt.Errorf("node %s has no %s label", nName, NodeGroupLabel)
+	}
+	si, err := cluster.NodeStaticInstance(nName)
+	if err != nil {
+		return nil, err
+	}
+	bds, err := cluster.ListBD(BdFilter{Node: nName})
+	if err != nil {
+		return nil, err
+	}
+	removed := &RemovedStaticNode{Node: nName, NodeGroup: ngName, Instance: si}
+	for _, bd := range bds {
+		removed.Devices = append(removed.Devices, bd.Status.Path)
+	}
+
+	if err := cluster.DrainNode(nName); err != nil {
+		return nil, err
+	}
+	cluster.Infof("Removing static node %s (StaticInstance %s, %s)", nName, si.Name, si.Spec.Address)
+	if err := cluster.DeleteStaticInstance(si.Name); err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err := cluster.WaitStaticInstanceDeleted(si.Name); err != nil {
+		return nil, err
+	}
+	if err := cluster.waitNodeDeleted(nName); err != nil {
+		return nil, err
+	}
+	if err := cluster.addStaticNodeGroupCount(ngName, -1); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// ReAddStaticNode wipes devices of removed node, creates StaticInstance like removed one,
+// increases count of its NodeGroup and waits for bootstrapped Ready node. Returns node name
+func (cluster *KCluster) ReAddStaticNode(removed *RemovedStaticNode) (string, error) {
+	si := removed.Instance
+	role := si.Labels["node-role"]
+	if role == "" || si.Spec.CredentialsRef == nil {
+		return "", fmt.Errorf("StaticInstance %s: no node-role label or credentials", si.Name)
+	}
+	ngName := removed.NodeGroup
+
+	if len(removed.Devices) > 0 {
+		cluster.Infof("Wiping %v of static node %s", removed.Devices, si.Spec.Address)
+		if err := WipeStaticNode(si.Spec.Address, removed.Devices...); err != nil {
+			return "", err
+		}
+	}
+	cluster.Infof("Adding static node %s (StaticInstance %s)", si.Spec.Address, si.Name)
+	if err := cluster.EnsureStaticInstance(si.Name, role, si.Spec.Address, si.Spec.CredentialsRef.Name); err != nil {
+		return "", err
+	}
+	if err := cluster.addStaticNodeGroupCount(ngName, 1); err != nil {
+		return "", err
+	}
+	si, err := cluster.WaitStaticInstancePhase(si.Name, StaticInstanceStatusCurrentStatusPhaseRunning)
+	if err != nil {
+		return "", err
+	}
+	if si.Status.NodeRef == nil {
+		return "", fmt.Errorf("StaticInstance %s is Running without node", si.Name)
+	}
+	nName := si.Status.NodeRef.Name
+	return nName, cluster.waitNodeReady(nName)
+}
+
+// ReplaceStaticNode removes static node and bootstraps fresh machine with ip in its place
+func (cluster *KCluster) ReplaceStaticNode(nName, ip string) (string, error) {
+	removed, err := cluster.RemoveStaticNode(nName)
+	if err != nil {
+		return "", err
+	}
+	// name of createStaticInstances: si-<name>-<ip hash>
+	si := removed.Instance
+	si.Name = strings.TrimSuffix(si.Name, hashMd5(si.Spec.Address)[:8]) + hashMd5(ip)[:8]
+	si.Spec.Address = ip
+	removed.Devices = nil // fresh machine
+	return cluster.ReAddStaticNode(removed)
+}
+
+// WipeStaticNode removes LVM and FS signatures from devices of node removed from cluster (by SSH to ip),
+// so the node comes back with clean disks
+func WipeStaticNode(ip string, devices ...string) error {
+	if len(devices) == 0 {
+		return errors.New("no devices to wipe")
+	}
+	client, err := NestedSshClient.getFwdClient(NestedSshUser, ip+":22", NestedSshKey, nil)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	quoted := make([]string, len(devices))
+	for i, d := range devices {
+		quoted[i] = shellQuote(d)
+	}
+	cmd := "if command -v vgchange >/dev/null; then vgchange -an; fi; wipefs -a -f " + strings.Join(quoted, " ")
+	res, err := client.Run(context.Background(), cmd, ExecOpts{Sudo: true})
+	if err != nil {
+		return fmt.Errorf("wipe %s %v: %w (%s)", ip, devices, err, strings.TrimSpace(res.Stderr))
+	}
+	return nil
+}
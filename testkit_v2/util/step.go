@@ -0,0 +1,149 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	stepNames = map[string]string{}   // "Test/step name" -> subtest name
+	stepDeps  = map[string][]string{} // step subtest name -> prerequisite subtest names
+	stepDone  = map[string]bool{}     // step subtest name -> step function returned (no Fatal/Skip)
+	stepsMx   sync.Mutex
+)
+
+// Step runs test step as subtest. Step is skipped if its prerequisite steps failed.
+// Node tests of the step (RunTestGroupNodes) depend on the same node tests of prerequisites
+//
+//	util.Step(t, "create", testCreate)
+//	util.Step(t, "resize", testResize, "create")
+func Step(t *testing.T, name string, f func(t *testing.T), deps ...string) bool {
+	parent := t.Name()
+	return t.Run(name, func(t *testing.T) {
+		rc := Track(t)
+
+		stepsMx.Lock()
+		stepNames[parent+"/"+name] = t.Name()
+		prereqs := make([]string, 0, len(deps))
+		unknown := []string{}
+		for _, dep := range deps {
+			if full, ok := stepNames[parent+"/"+dep]; ok {
+				prereqs = append(prereqs, full)
+			} else {
+				unknown = append(unknown, dep)
+			}
+		}
+		stepDeps[t.Name()] = prereqs
+		stepsMx.Unlock()
+
+		rc.setDependsOn(prereqs)
+		if len(unknown) > 0 {
+			t.Fatalf("unknown prerequisite steps: %s", strings.Join(unknown, ", "))
+		}
+		for _, p := range prereqs {
+			if status := stepStatus(p); status != StatusPassed {
+				msg := fmt.Sprintf("prerequisite %s %s", p, status)
+				logf(TestLogger(t), slog.LevelWarn, "%s skipped: %s", t.Name(), msg)
+				rc.setSkipped(msg)
+				t.Skip(msg)
+			}
+		}
+
+		f(t)
+
+		stepsMx.Lock()
+		stepDone[t.Name()] = true
+		stepsMx.Unlock()
+	})
+}
+
+// stepStatus returns own status of prerequisite step. Failed node tests don't fail the step
+// if step function completed, node tests of dependent step check the same node tests of prerequisite
+func stepStatus(name string) string {
+	stepsMx.Lock()
+	done := stepDone[name]
+	stepsMx.Unlock()
+
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	c, ok := report.cases[name]
+	switch {
+	case !ok:
+		return "not run"
+	case c.Status == StatusFailed && done && hasNodeCases(name, StatusFailed):
+		return StatusPassed
+	}
+	return c.Status
+}
+
+// hasNodeCases reports whether step ran node tests (with one of statuses). Must be called with reportMx locked
+func hasNodeCases(name string, statuses ...string) bool {
+	for cName, c := range report.cases {
+		if c.Kind == caseNode && strings.HasPrefix(cName, name+"/") &&
+			(len(statuses) == 0 || slices.Contains(statuses, c.Status)) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodePrerequisites returns prerequisite node tests of node test and reason to skip it
+func nodePrerequisites(name string) (deps []string, skip string) {
+	stepsMx.Lock()
+	step, prereqs := name, []string(nil)
+	for {
+		if p, ok := stepDeps[step]; ok {
+			prereqs = p
+			break
+		}
+		i := strings.LastIndex(step, "/")
+		if i < 0 {
+			break
+		}
+		step = step[:i]
+	}
+	stepsMx.Unlock()
+	if len(prereqs) == 0 {
+		return nil, ""
+	}
+
+	suffix := name[len(step):]
+	reportMx.Lock()
+	defer reportMx.Unlock()
+	for _, p := range prereqs {
+		if !hasNodeCases(p) {
+			continue
+		}
+		dep := p + suffix
+		deps = append(deps, dep)
+		c, ok := report.cases[dep]
+		switch {
+		case skip != "":
+		case !ok:
+			skip = fmt.Sprintf("prerequisite %s not run", dep)
+		case c.Status != StatusPassed:
+			skip = fmt.Sprintf("prerequisite %s %s", dep, c.Status)
+		}
+	}
+	return deps, skip
+}
@@ -0,0 +1,383 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	coreapi "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	ctrlrtclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+const (
+	TimelineTest    = "test"
+	TimelineAPI     = "api"
+	TimelineCommand = "command"
+	TimelineEvent   = "event"
+
+	timelineOutputLen = 2000
+)
+
+// TimelineRecord is one entry of test run timeline
+type TimelineRecord struct {
+	Time     time.Time `json:"time"`
+	Kind     string    `json:"kind"`
+	Cluster  string    `json:"cluster,omitempty"`
+	Test     string    `json:"test,omitempty"`
+	Node     string    `json:"node,omitempty"`
+	Action   string    `json:"action"`
+	Object   string    `json:"object,omitempty"`
+	Message  string    `json:"message,omitempty"`
+	Duration float64   `json:"duration,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+var (
+	timeline   []TimelineRecord
+	timelineMx sync.Mutex
+
+	// event objects related to tests besides test namespace objects
+	timelineEventKinds = []string{"LVMVolumeGroup", "BlockDevice", "LVMLogicalVolume", "PersistentVolume", "Node"}
+)
+
+func timelineEnabled() bool {
+	return *timelineFlag != ""
+}
+
+// RecordTimeline adds record to timeline (-timeline)
+func RecordTimeline(rec TimelineRecord) {
+	if !timelineEnabled() {
+		return
+	}
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	if len(rec.Message) > timelineOutputLen {
+		rec.Message = rec.Message[:timelineOutputLen] + "..."
+	}
+
+	timelineMx.Lock()
+	defer timelineMx.Unlock()
+	timeline = append(timeline, rec)
+}
+
+func timelineError(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+/*  API changes  */
+
+func objectRef(cl ctrlrtclient.Client, obj ctrlrtclient.Object) string {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := cl.GroupVersionKindFor(obj); err == nil {
+		kind = gvk.Kind
+	}
+	if obj.GetNamespace() == "" {
+		return kind + "/" + obj.GetName()
+	}
+	return kind + "/" + obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// timelineClient records objects created, updated and deleted through controller-runtime client
+func timelineClient(cl ctrlrtclient.WithWatch, cluster string) ctrlrtclient.WithWatch {
+	record := func(action string, obj ctrlrtclient.Object, start time.Time, err error) error {
+		RecordTimeline(TimelineRecord{
+			Time:     start,
+			Kind:     TimelineAPI,
+			Cluster:  cluster,
+			Action:   action,
+			Object:   objectRef(cl, obj),
+			Duration: time.Since(start).Seconds(),
+			Error:    timelineError(err),
+		})
+		return err
+	}
+
+	return interceptor.NewClient(cl, interceptor.Funcs{
+		Create: func(ctx context.Context, c ctrlrtclient.WithWatch, obj ctrlrtclient.Object, opts ...ctrlrtclient.CreateOption) error {
+			start := time.Now()
+			return record("create", obj, start, c.Create(ctx, obj, opts...))
+		},
+		Update: func(ctx context.Context, c ctrlrtclient.WithWatch, obj ctrlrtclient.Object, opts ...ctrlrtclient.UpdateOption) error {
+			start := time.Now()
+			return record("update", obj, start, c.Update(ctx, obj, opts...))
+		},
+		Patch: func(ctx context.Context, c ctrlrtclient.WithWatch, obj ctrlrtclient.Object, patch ctrlrtclient.Patch, opts ...ctrlrtclient.PatchOption) error {
+			start := time.Now()
+			return record("patch", obj, start, c.Patch(ctx, obj, patch, opts...))
+		},
+		Delete: func(ctx context.Context, c ctrlrtclient.WithWatch, obj ctrlrtclient.Object, opts ...ctrlrtclient.DeleteOption) error {
+			start := time.Now()
+			return record("delete", obj, start, c.Delete(ctx, obj, opts...))
+		},
+		DeleteAllOf: func(ctx context.Context, c ctrlrtclient.WithWatch, obj ctrlrtclient.Object, opts ...ctrlrtclient.DeleteAllOfOption) error {
+			start := time.Now()
+			return record("deleteAllOf", obj, start, c.DeleteAllOf(ctx, obj, opts...))
+		},
+		SubResourceUpdate: func(ctx context.Context, c ctrlrtclient.Client, sub string, obj ctrlrtclient.Object, opts ...ctrlrtclient.SubResourceUpdateOption) error {
+			start := time.Now()
+			return record("update "+sub, obj, start, c.SubResource(sub).Update(ctx, obj, opts...))
+		},
+		SubResourcePatch: func(ctx context.Context, c ctrlrtclient.Client, sub string, obj ctrlrtclient.Object, patch ctrlrtclient.Patch, opts ...ctrlrtclient.SubResourcePatchOption) error {
+			start := time.Now()
+			return record("patch "+sub, obj, start, c.SubResource(sub).Patch(ctx, obj, patch, opts...))
+		},
+	})
+}
+
+// timelineTransport records changes made through go and dynamic clients (pods, exec, NodeGroup patches)
+type timelineTransport struct {
+	rt      http.RoundTripper
+	cluster string
+}
+
+var timelineMethods = map[string]string{
+	http.MethodPost:   "create",
+	http.MethodPut:    "update",
+	http.MethodPatch:  "patch",
+	http.MethodDelete: "delete",
+}
+
+func (t timelineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	action, ok := timelineMethods[req.Method]
+	if !ok || !timelineEnabled() {
+		return t.rt.RoundTrip(req)
+	}
+
+	start := time.Now()
+	resp, err := t.rt.RoundTrip(req)
+	errMsg := timelineError(err)
+	if err == nil && resp.StatusCode >= 400 {
+		errMsg = resp.Status
+	}
+	ref, sub := apiPathRef(req.URL.Path)
+	if sub != "" {
+		action += " " + sub
+	}
+	RecordTimeline(TimelineRecord{
+		Time:     start,
+		Kind:     TimelineAPI,
+		Cluster:  t.cluster,
+		Action:   action,
+		Object:   ref,
+		Duration: time.Since(start).Seconds(),
+		Error:    errMsg,
+	})
+	return resp, err
+}
+
+// apiPathRef returns object reference (resource/namespace/name) and subresource of API path
+// /api/v1/namespaces/ns/pods/name/exec, /apis/group/version/resource/name
+func apiPathRef(path string) (ref, sub string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) > 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) > 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return path, ""
+	}
+	ns := ""
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		ns, parts = parts[1], parts[2:]
+	}
+	if len(parts) > 2 {
+		sub = strings.Join(parts[2:], "/")
+	}
+	ref = parts[0]
+	if ns != "" {
+		ref += "/" + ns
+	}
+	if len(parts) > 1 {
+		ref += "/" + parts[1]
+	}
+	return ref, sub
+}
+
+// timelineConfig returns copy of config with transport recording changes in timeline
+func timelineConfig(cfg *rest.Config, cluster string) *rest.Config {
+	c := rest.CopyConfig(cfg)
+	c.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return timelineTransport{rt: rt, cluster: cluster}
+	})
+	return c
+}
+
+/*  Events  */
+
+func timelineEventRelated(ev *coreapi.Event) bool {
+	obj := ev.InvolvedObject
+	return obj.Namespace == TestNS ||
+		slices.Contains(diagnosticsNamespaces, obj.Namespace) ||
+		slices.Contains(timelineEventKinds, obj.Kind)
+}
+
+// watchEvents records Kubernetes events of test related objects until cluster context is done.
+// Watch is resumed from the last resourceVersion, events seen before (UID and resourceVersion) are skipped
+func (cluster *KCluster) watchEvents() {
+	since := time.Now()
+	resourceVersion := ""
+	seen := map[string]string{} // event UID -> resourceVersion
+	for cluster.ctx.Err() == nil {
+		w, err := cluster.goClient.CoreV1().Events("").Watch(cluster.ctx, metav1.ListOptions{
+			ResourceVersion:     resourceVersion,
+			AllowWatchBookmarks: true,
+		})
+		if err != nil {
+			cluster.Debugf("Events watch: %s", err.Error())
+			if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+				resourceVersion = ""
+			}
+			time.Sleep(5 * time.Second)
+			continue
+		}
+
+		for item := range w.ResultChan() {
+			if item.Type == watch.Error {
+				// expired resourceVersion: start from current state, old events are filtered by time and UID
+				if err := apierrors.FromObject(item.Object); apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+					resourceVersion = ""
+				}
+				break
+			}
+			ev, ok := item.Object.(*coreapi.Event)
+			if !ok {
+				continue
+			}
+			resourceVersion = ev.ResourceVersion
+			if item.Type == watch.Deleted || item.Type == watch.Bookmark || !timelineEventRelated(ev) {
+				continue
+			}
+			if seen[string(ev.UID)] == ev.ResourceVersion {
+				continue
+			}
+			seen[string(ev.UID)] = ev.ResourceVersion
+			evTime := ev.LastTimestamp.Time
+			if evTime.IsZero() {
+				evTime = ev.EventTime.Time
+			}
+			if evTime.IsZero() {
+				evTime = time.Now()
+			}
+			if evTime.Before(since) {
+				continue
+			}
+
+			obj := ev.InvolvedObject
+			ref := obj.Kind + "/" + obj.Name
+			if obj.Namespace != "" {
+				ref = obj.Kind + "/" + obj.Namespace + "/" + obj.Name
+			}
+			RecordTimeline(TimelineRecord{
+				Time:    evTime,
+				Kind:    TimelineEvent,
+				Cluster: cluster.label,
+				Node:    ev.Source.Host,
+				Action:  ev.Type + " " + ev.Reason,
+				Object:  ref,
+				Message: ev.Message,
+			})
+		}
+		w.Stop()
+	}
+}
+
+/*  Export  */
+
+//go:embed timeline.html.tpl
+var timelineHtmlTpl string
+
+// Timeline returns timeline records ordered by time
+func Timeline() []TimelineRecord {
+	timelineMx.Lock()
+	defer timelineMx.Unlock()
+
+	records := slices.Clone(timeline)
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records
+}
+
+// WriteTimelineHTML writes timeline as self-contained HTML page
+func WriteTimelineHTML(path string, records []TimelineRecord) error {
+	tpl, err := template.New("timeline").Funcs(template.FuncMap{
+		"offset": func(t time.Time) string {
+			return fmt.Sprintf("%.1fs", t.Sub(startTime).Seconds())
+		},
+		"clock": func(t time.Time) string {
+			return t.Format("15:04:05.000")
+		},
+	}).Parse(timelineHtmlTpl)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return tpl.Execute(f, map[string]any{
+		"RunID":     RunID,
+		"Namespace": TestNS,
+		"Start":     startTime.Format(time.RFC3339),
+		"Kinds":     []string{TimelineTest, TimelineAPI, TimelineCommand, TimelineEvent},
+		"Records":   records,
+	})
+}
+
+// FlushTimeline writes timeline to -timeline JSON file and HTML page next to it
+func FlushTimeline() {
+	if !timelineEnabled() {
+		return
+	}
+
+	records := Timeline()
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err == nil {
+		err = os.WriteFile(*timelineFlag, data, 0644)
+	}
+	if err != nil {
+		Errorf("Can't write timeline: %s", err.Error())
+	}
+
+	htmlPath := strings.TrimSuffix(*timelineFlag, filepath.Ext(*timelineFlag)) + ".html"
+	if err := WriteTimelineHTML(htmlPath, records); err != nil {
+		Errorf("Can't write timeline HTML: %s", err.Error())
+	}
+}
@@ -0,0 +1,48 @@
+<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Timeline {{.RunID}}</title>
+<style>
+  body { font-family: sans-serif; font-size: 13px; margin: 16px; }
+  table { border-collapse: collapse; width: 100%; }
+  th, td { text-align: left; padding: 3px 6px; border-bottom: 1px solid #eee; vertical-align: top; }
+  th { position: sticky; top: 0; background: #fafafa; }
+  td.msg { white-space: pre-wrap; font-family: monospace; max-width: 60em; }
+  .kind { border-radius: 3px; padding: 0 4px; color: #fff; }
+  .test { background: #3b6fb6; } .api { background: #6c4fb0; } .command { background: #2e8b57; } .event { background: #b8860b; }
+  tr.error td { background: #fdecea; }
+  .filters { margin-bottom: 8px; }
+</style>
+</head>
+<body>
+<h3>Run {{.RunID}}, namespace {{.Namespace}}, started {{.Start}}</h3>
+<div class="filters">
+  {{range .Kinds}}<label><input type="checkbox" checked data-kind="{{.}}"> {{.}}</label> {{end}}
+  <input id="search" placeholder="filter" size="40">
+</div>
+<table>
+<tr><th>+time</th><th>clock</th><th>kind</th><th>action</th><th>object</th><th>node</th><th>test</th><th>duration</th><th>message</th></tr>
+{{range .Records}}
+<tr data-kind="{{.Kind}}"{{if .Error}} class="error"{{end}}>
+  <td>{{offset .Time}}</td><td>{{clock .Time}}</td><td><span class="kind {{.Kind}}">{{.Kind}}</span></td>
+  <td>{{.Action}}</td><td>{{.Object}}</td><td>{{.Node}}</td><td>{{.Test}}</td>
+  <td>{{if .Duration}}{{printf "%.2fs" .Duration}}{{end}}</td>
+  <td class="msg">{{.Message}}{{if .Error}}
+{{.Error}}{{end}}</td>
+</tr>
+{{end}}
+</table>
+<script>
+  function apply() {
+    var kinds = {};
+    document.querySelectorAll('input[data-kind]').forEach(function(c) { kinds[c.dataset.kind] = c.checked; });
+    var q = document.getElementById('search').value.toLowerCase();
+    document.querySelectorAll('tr[data-kind]').forEach(function(r) {
+      r.style.display = kinds[r.dataset.kind] && r.textContent.toLowerCase().indexOf(q) >= 0 ? '' : 'none';
+    });
+  }
+  document.querySelectorAll('input').forEach(function(i) { i.addEventListener('input', apply); });
+</script>
+</body>
+</html>
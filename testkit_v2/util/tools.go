@@ -21,7 +21,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"math/rand"
-	"time"
+	"strings"
 )
 
 func hashMd5(in string) string {
@@ -33,35 +33,6 @@ func base64Encode(in string) string {
 	return base64.StdEncoding.EncodeToString([]byte(in))
 }
 
-var lastLog = time.Now()
-
-func RetrySec(sec int, f func() error) error {
-	latency := time.Duration((sec + 15) / 7)
-	if latency > 15 {
-		latency = 15
-	}
-
-	start, lastMsg := time.Now(), ""
-	for i := 0; ; i++ {
-		err := f()
-		if err == nil {
-			return nil
-		}
-		if int(time.Since(start).Seconds()) > sec {
-			Warnf("Retry %ds: %s", sec, err.Error())
-			return err
-		}
-		if (time.Since(lastLog) > 10*time.Second && lastMsg != err.Error()) ||
-			time.Since(lastLog) > 2*time.Minute {
-			Debugf("Waiting... %s", err.Error())
-			lastLog, lastMsg = time.Now(), err.Error()
-		}
-		time.Sleep(latency * time.Second)
-	}
-
-	return nil
-}
-
 const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
 
 func RandString(n int) string {
@@ -71,3 +42,12 @@ func RandString(n int) string {
 	}
 	return string(b)
 }
+
+// tailLines returns last n lines of text
+func tailLines(text string, n int) string {
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Condition is checked by Wait until it returns nil
+type Condition func(ctx context.Context) error
+
+// Backoff is delay between attempts: Initial, multiplied by Factor after each attempt up to Cap, +-Jitter part
+type Backoff struct {
+	Initial time.Duration
+	Factor  float64
+	Jitter  float64
+	Cap     time.Duration
+}
+
+var DefaultBackoff = Backoff{Initial: time.Second, Factor: 1.5, Jitter: 0.1, Cap: 10 * time.Second}
+
+func (b Backoff) delay(attempt int) time.Duration {
+	if b.Initial <= 0 {
+		b = DefaultBackoff
+	}
+	d := float64(b.Initial)
+	for i := 1; i < attempt && (b.Cap <= 0 || d < float64(b.Cap)); i++ {
+		d *= max(b.Factor, 1)
+	}
+	if b.Cap > 0 && d > float64(b.Cap) {
+		d = float64(b.Cap)
+	}
+	if b.Jitter > 0 {
+		d += d * b.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+// Wait retries condition. First attempt runs immediately, next ones after Backoff delay
+//
+//	res, err := util.Wait{Timeout: time.Minute, AttemptTimeout: 10 * time.Second}.Until(ctx, cond)
+type Wait struct {
+	Timeout        time.Duration // 0 - until ctx is done
+	AttemptTimeout time.Duration // context timeout of each attempt, 0 - no timeout
+	Backoff        Backoff       // DefaultBackoff if not set
+}
+
+// WaitResult describes finished wait
+type WaitResult struct {
+	Attempts int
+	Elapsed  time.Duration
+	Errors   []string // distinct errors in order of appearance
+}
+
+func (r *WaitResult) addError(err error) {
+	msg := err.Error()
+	for _, e := range r.Errors {
+		if e == msg {
+			return
+		}
+	}
+	r.Errors = append(r.Errors, msg)
+}
+
+// Until checks condition until it passes, Timeout exceeded or ctx is done
+func (w Wait) Until(ctx context.Context, cond Condition) (WaitResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+
+	log := LoggerFrom(ctx)
+	start, res := time.Now(), WaitResult{}
+	lastLog, lastMsg := start, ""
+	for {
+		res.Attempts++
+		err := w.attempt(ctx, cond)
+		res.Elapsed = time.Since(start)
+		if err == nil {
+			return res, nil
+		}
+		res.addError(err)
+
+		if (time.Since(lastLog) > 10*time.Second && lastMsg != err.Error()) || time.Since(lastLog) > 2*time.Minute {
+			logf(log, slog.LevelDebug, "Waiting... %s", err.Error())
+			lastLog, lastMsg = time.Now(), err.Error()
+		}
+
+		timer := time.NewTimer(w.Backoff.delay(res.Attempts))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			res.Elapsed = time.Since(start)
+			if w.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return res, fmt.Errorf("timeout %s after %d attempts: %w", w.Timeout, res.Attempts, err)
+			}
+			return res, fmt.Errorf("%w after %d attempts: %w", ctx.Err(), res.Attempts, err)
+		case <-timer.C:
+		}
+	}
+}
+
+func (w Wait) attempt(ctx context.Context, cond Condition) error {
+	if w.AttemptTimeout <= 0 {
+		return cond(ctx)
+	}
+	actx, cancel := context.WithTimeout(ctx, w.AttemptTimeout)
+	defer cancel()
+	err := cond(actx)
+	if err == nil && actx.Err() != nil {
+		err = fmt.Errorf("attempt timeout %s", w.AttemptTimeout)
+	}
+	return err
+}
+
+// WaitFor checks condition until it passes or timeout, DefaultBackoff between attempts
+func WaitFor(ctx context.Context, timeout time.Duration, cond Condition) error {
+	_, err := Wait{Timeout: timeout}.Until(ctx, cond)
+	return err
+}
+
+/*  Combinators  */
+
+// All passes when all conditions pass. Errors of failed conditions are joined
+func All(conds ...Condition) Condition {
+	return func(ctx context.Context) error {
+		var errs []error
+		for _, cond := range conds {
+			if err := cond(ctx); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// Any passes when one of conditions passes
+func Any(conds ...Condition) Condition {
+	return func(ctx context.Context) error {
+		errs := make([]string, 0, len(conds))
+		for _, cond := range conds {
+			err := cond(ctx)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf("none passed: %s", strings.Join(errs, "; "))
+	}
+}
+
+// StableFor passes when condition passes continuously for the duration (all checks in between pass)
+func StableFor(d time.Duration, cond Condition) Condition {
+	var mx sync.Mutex
+	var since time.Time
+	return func(ctx context.Context) error {
+		err := cond(ctx)
+
+		mx.Lock()
+		defer mx.Unlock()
+		if err != nil {
+			since = time.Time{}
+			return err
+		}
+		if since.IsZero() {
+			since = time.Now()
+		}
+		if stable := time.Since(since); stable < d {
+			return fmt.Errorf("stable for %s of %s", stable.Round(time.Second), d)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,261 @@
+/*
+Copyright 2025 Flant JSC
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	errA = errors.New("a")
+	errB = errors.New("b")
+)
+
+// seqCond returns results in order, the last one is repeated
+func seqCond(results ...error) Condition {
+	var mx sync.Mutex
+	i := 0
+	return func(context.Context) error {
+		mx.Lock()
+		defer mx.Unlock()
+		err := results[min(i, len(results)-1)]
+		i++
+		return err
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		backoff  Backoff
+		attempt  int
+		min, max time.Duration
+	}{
+		{"initial", Backoff{Initial: 100 * time.Millisecond, Factor: 2}, 1, 100 * time.Millisecond, 100 * time.Millisecond},
+		{"factor", Backoff{Initial: 100 * time.Millisecond, Factor: 2}, 3, 400 * time.Millisecond, 400 * time.Millisecond},
+		{"factor below 1", Backoff{Initial: 100 * time.Millisecond, Factor: 0.5}, 5, 100 * time.Millisecond, 100 * time.Millisecond},
+		{"cap", Backoff{Initial: 100 * time.Millisecond, Factor: 2, Cap: time.Second}, 20, time.Second, time.Second},
+		{"jitter", Backoff{Initial: time.Second, Factor: 2, Jitter: 0.1}, 1, 900 * time.Millisecond, 1100 * time.Millisecond},
+		{"jitter over cap", Backoff{Initial: time.Second, Factor: 2, Jitter: 0.2, Cap: 2 * time.Second}, 10, 1600 * time.Millisecond, 2400 * time.Millisecond},
+		{"default", Backoff{}, 1, 900 * time.Millisecond, 1100 * time.Millisecond},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			delays := map[time.Duration]bool{}
+			for range 100 {
+				d := c.backoff.delay(c.attempt)
+				if d < c.min || d > c.max {
+					t.Fatalf("delay %s not in [%s, %s]", d, c.min, c.max)
+				}
+				delays[d] = true
+			}
+			if jitter := c.backoff.Jitter > 0 || c.backoff.Initial == 0; jitter != (len(delays) > 1) {
+				t.Errorf("%d distinct delays of 100, jitter %t", len(delays), jitter)
+			}
+		})
+	}
+}
+
+func TestWaitUntil(t *testing.T) {
+	fast := Backoff{Initial: time.Millisecond, Factor: 1}
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, c := range []struct {
+		name     string
+		wait     Wait
+		ctx      context.Context
+		cond     Condition
+		attempts int      // exact number of attempts, 0 - any
+		errors   []string // WaitResult.Errors
+		err      string   // error substring, "" - passed
+		is       []error  // error wraps
+	}{
+		{
+			name:     "first attempt immediately",
+			wait:     Wait{Timeout: 5 * time.Second, Backoff: Backoff{Initial: time.Hour}},
+			cond:     seqCond(nil),
+			attempts: 1,
+		},
+		{
+			name:     "pass after retries",
+			wait:     Wait{Timeout: 5 * time.Second, Backoff: fast},
+			cond:     seqCond(errA, errA, nil),
+			attempts: 3,
+			errors:   []string{"a"},
+		},
+		{
+			name:     "distinct errors",
+			wait:     Wait{Timeout: 5 * time.Second, Backoff: fast},
+			cond:     seqCond(errA, errB, errA, errB, nil),
+			attempts: 5,
+			errors:   []string{"a", "b"},
+		},
+		{
+			name:   "timeout",
+			wait:   Wait{Timeout: 50 * time.Millisecond, Backoff: fast},
+			cond:   seqCond(errA, errB),
+			errors: []string{"a", "b"},
+			err:    "timeout 50ms after",
+			is:     []error{errB},
+		},
+		{
+			name: "attempt timeout with error",
+			wait: Wait{Timeout: 5 * time.Second, AttemptTimeout: 10 * time.Millisecond, Backoff: fast},
+			cond: func() Condition {
+				first := true
+				return func(ctx context.Context) error {
+					if first {
+						first = false
+						<-ctx.Done()
+						return ctx.Err()
+					}
+					return nil
+				}
+			}(),
+			attempts: 2,
+			errors:   []string{context.DeadlineExceeded.Error()},
+		},
+		{
+			name: "attempt timeout ignored by condition",
+			wait: Wait{Timeout: 50 * time.Millisecond, AttemptTimeout: 10 * time.Millisecond, Backoff: fast},
+			cond: func(ctx context.Context) error {
+				<-ctx.Done()
+				return nil
+			},
+			errors: []string{"attempt timeout 10ms"},
+			err:    "timeout 50ms after",
+		},
+		{
+			name:     "context canceled",
+			wait:     Wait{Timeout: 5 * time.Second, Backoff: fast},
+			ctx:      canceled,
+			cond:     seqCond(errA),
+			attempts: 1,
+			errors:   []string{"a"},
+			err:      "after 1 attempts: a",
+			is:       []error{context.Canceled, errA},
+		},
+		{
+			name:     "context canceled without timeout",
+			wait:     Wait{Backoff: Backoff{Initial: time.Hour}},
+			ctx:      canceled,
+			cond:     seqCond(errA),
+			attempts: 1,
+			errors:   []string{"a"},
+			is:       []error{context.Canceled},
+			err:      "context canceled",
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			ctx := c.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			res, err := c.wait.Until(ctx, c.cond)
+
+			switch {
+			case c.err == "" && err != nil:
+				t.Errorf("unexpected error: %s", err.Error())
+			case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+				t.Errorf("error %v, expected %q", err, c.err)
+			}
+			for _, target := range c.is {
+				if !errors.Is(err, target) {
+					t.Errorf("error %v does not wrap %v", err, target)
+				}
+			}
+			if c.attempts > 0 && res.Attempts != c.attempts {
+				t.Errorf("%d attempts, expected %d", res.Attempts, c.attempts)
+			}
+			if !slices.Equal(res.Errors, c.errors) {
+				t.Errorf("errors %q, expected %q", res.Errors, c.errors)
+			}
+			if c.wait.Timeout > 0 && res.Elapsed > c.wait.Timeout+time.Second {
+				t.Errorf("elapsed %s over timeout %s", res.Elapsed, c.wait.Timeout)
+			}
+		})
+	}
+}
+
+func TestWaitCombinators(t *testing.T) {
+	ctx := context.Background()
+	for _, c := range []struct {
+		name string
+		cond Condition
+		err  string  // error substring, "" - passed
+		is   []error // error wraps
+	}{
+		{"all passed", All(seqCond(nil), seqCond(nil)), "", nil},
+		{"all with failed", All(seqCond(nil), seqCond(errA)), "a", []error{errA}},
+		{"all errors joined", All(seqCond(errA), seqCond(nil), seqCond(errB)), "a\nb", []error{errA, errB}},
+		{"all empty", All(), "", nil},
+		{"any passed", Any(seqCond(errA), seqCond(nil)), "", nil},
+		{"any failed", Any(seqCond(errA), seqCond(errB)), "none passed: a; b", nil},
+		{"any empty", Any(), "none passed", nil},
+		{"stable not yet", StableFor(time.Hour, seqCond(nil)), "stable for 0s of 1h0m0s", nil},
+		{"stable failed", StableFor(time.Hour, seqCond(errA)), "a", []error{errA}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.cond(ctx)
+			switch {
+			case c.err == "" && err != nil:
+				t.Errorf("unexpected error: %s", err.Error())
+			case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+				t.Errorf("error %v, expected %q", err, c.err)
+			}
+			for _, target := range c.is {
+				if !errors.Is(err, target) {
+					t.Errorf("error %v does not wrap %v", err, target)
+				}
+			}
+		})
+	}
+}
+
+func TestWaitStableFor(t *testing.T) {
+	ctx := context.Background()
+
+	cond := StableFor(30*time.Millisecond, seqCond(nil, nil, errA, nil, nil))
+	if err := cond(ctx); err == nil {
+		t.Fatal("stable at first check")
+	}
+	time.Sleep(40 * time.Millisecond)
+	if err := cond(ctx); err != nil {
+		t.Fatalf("not stable after duration: %s", err.Error())
+	}
+	if err := cond(ctx); !errors.Is(err, errA) {
+		t.Fatalf("failed check: %v", err)
+	}
+	if err := cond(ctx); err == nil {
+		t.Fatal("stable right after failed check")
+	}
+
+	res, err := Wait{Timeout: 5 * time.Second, Backoff: Backoff{Initial: 5 * time.Millisecond, Factor: 1}}.
+		Until(ctx, StableFor(30*time.Millisecond, seqCond(nil)))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if res.Attempts < 2 || res.Elapsed < 30*time.Millisecond {
+		t.Errorf("passed after %d attempts in %s", res.Attempts, res.Elapsed)
+	}
+}